@@ -0,0 +1,32 @@
+// fakeupstream levanta un servidor HTTP local que imita a Notion, Zabbix y Ubersmith
+// con respuestas grabadas (ver internal/fakeupstream/fixtures), para probar el pipeline completo sin tocar las APIs reales.
+//
+// Uso:
+//
+//	go run ./cmd/fakeupstream -addr :8089
+//
+//	NOTION_API_URL=http://localhost:8089/notion/v1 \
+//	ZABBIX_URL=http://localhost:8089/zabbix/api_jsonrpc.php \
+//	UBERSMITH_URL=http://localhost:8089/ubersmith/api/2.0/ \
+//	DRY_RUN=true go run ./cmd/worker -cid 157591
+package main
+
+import (
+	"flag"
+	"gpon-sync/internal/fakeupstream"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":8089", "Dirección donde escuchar")
+	flag.Parse()
+
+	upstreams, err := fakeupstream.New()
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+
+	log.Printf("🧪 Upstreams falsos escuchando en %s (%d circuitos de prueba)", *addr, len(upstreams.Circuits))
+	log.Fatal(http.ListenAndServe(*addr, upstreams.Mux()))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// checkTimeout es el tiempo máximo de cada verificación de -check
+const checkTimeout = 20 * time.Second
+
+// pinger es un sistema externo que se puede verificar sin efectos secundarios
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// runChecks verifica DB, Zabbix, Notion y Ubersmith e imprime una línea por sistema.
+// dbErr es el error de conexión inicial a la DB (si lo hubo, db es nil).
+// Retorna exitFatal si alguna verificación falla.
+func runChecks(db pinger, dbErr error, zabbix, notion, ubersmith pinger) int {
+	checks := []struct {
+		name string
+		p    pinger
+	}{
+		{"DB", db},
+		{"Zabbix", zabbix},
+		{"Notion", notion},
+		{"Ubersmith", ubersmith},
+	}
+
+	code := exitOK
+	for _, c := range checks {
+		start := time.Now()
+		var err error
+		if c.name == "DB" && dbErr != nil {
+			err = dbErr
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+			err = c.p.Ping(ctx)
+			cancel()
+		}
+
+		if err != nil {
+			log.Printf("❌ %-10s FALLO: %v", c.name, err)
+			code = exitFatal
+			continue
+		}
+		log.Printf("✅ %-10s OK (%s)", c.name, time.Since(start).Round(time.Millisecond))
+	}
+	return code
+}
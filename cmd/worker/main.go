@@ -2,13 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"gpon-sync/internal/adapters/notion"
 	"gpon-sync/internal/adapters/postgres"
 	"gpon-sync/internal/adapters/ubersmith"
+	"gpon-sync/internal/adapters/webhook"
 	"gpon-sync/internal/adapters/zabbix"
+	"gpon-sync/internal/app"
+	"gpon-sync/internal/audit"
 	"gpon-sync/internal/config"
 	"gpon-sync/internal/core"
+	"gpon-sync/internal/deadletter"
+	"gpon-sync/internal/httpclient"
+	"gpon-sync/internal/ratelimit"
+	"gpon-sync/internal/redact"
+	"gpon-sync/internal/scheduler"
+	"gpon-sync/internal/status"
+	"gpon-sync/internal/version"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -16,156 +30,514 @@ import (
 	"time"
 )
 
+// Códigos de salida del proceso (relevantes en modo ejecución única)
+const (
+	exitOK            = 0 // Ejecución limpia
+	exitCircuitErrors = 1 // Ejecución completa, pero con errores en algunos circuitos
+	exitFatal         = 2 // Error fatal de arranque (DB, autenticación Zabbix, etc.)
+)
+
 func main() {
+	once := flag.Bool("once", false, "Ejecuta un solo ciclo de sincronización y termina (equivale a RUN_ONCE=true)")
+	exportCSV := flag.String("export", "", "Enriquece todos los circuitos, escribe el CSV en esta ruta y termina sin tocar la DB (equivale a EXPORT_CSV)")
+	singleCID := flag.String("cid", "", "Sincroniza solo este CID (sin consultar la lista de la DB), muestra el detalle de cada etapa y termina")
+	retryDeadLetter := flag.Bool("retry-deadletter", false, "Procesa solo los circuitos en dead-letter (requiere DEADLETTER_FILE)")
+	check := flag.Bool("check", false, "Verifica la conexión y las credenciales de DB, Zabbix, Notion y Ubersmith y termina (no procesa circuitos)")
+	showVersion := flag.Bool("version", false, "Muestra la versión, el commit y la fecha del build y termina")
+	flag.Parse()
+
+	// -version no necesita configuración: funciona aunque falten variables de entorno
+	if *showVersion {
+		fmt.Println("gpon-sync " + version.String())
+		os.Exit(exitOK)
+	}
+
 	// 1. Configuración
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("[FATAL] %v", err)
+		os.Exit(exitFatal)
+	}
+
+	// Ningún secreto de la configuración debe llegar a los logs (ej: errores que incluyen la URL o el DSN)
+	redact.Register(cfg.Secrets()...)
+	redact.SetPolicy(redact.Policy{MaskUsernames: cfg.MaskUsernames, Reveal: cfg.MaskReveal})
+	log.SetOutput(redact.Writer(os.Stderr))
+	if cfg.ConfigDump {
+		log.Println("⚙️  Configuración efectiva (CONFIG_DUMP=true):")
+		for _, line := range cfg.Dump() {
+			log.Printf("   %s", line)
+		}
+	}
+	if *once {
+		cfg.RunOnce = true
+	}
+	if *exportCSV != "" {
+		cfg.ExportCSV = *exportCSV
+	}
 
 	// 2. Adaptadores
-	dbRepo, err := postgres.NewPostgresRepo(cfg.DatabaseURL)
-	if err != nil {
-		log.Fatalf("Fallo DB: %v", err)
+	dbRepo, err := postgres.NewPostgresRepo(cfg.DatabaseURL, postgres.Options{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		PingTimeout:     cfg.DBPingTimeout,
+		WriteRetries:    cfg.DBWriteRetries,
+
+		InsertMissing:      cfg.InsertMissing,
+		InsertExtraColumns: cfg.InsertExtraColumns,
+		Columns:            cfg.WriteColumns(),
+		Schema: postgres.Schema{
+			Table:         cfg.DBTable,
+			CID:           cfg.ColCID,
+			RxPower:       cfg.ColRxPower,
+			StatusGpon:    cfg.ColStatusGpon,
+			PPPoEUsername: cfg.ColPPPoEUsername,
+			PPPoEPassword: cfg.ColPPPoEPassword,
+		},
+		SkipSchemaCheck: !cfg.DBSchemaCheck,
+	})
+	// En modo -check un fallo de la DB se reporta junto a los demás sistemas
+	dbErr := err
+	if err != nil && !*check {
+		log.Printf("[FATAL] Fallo DB (%s): %v", redact.DSN(cfg.DatabaseURL), err)
+		os.Exit(exitFatal)
 	}
 
-	notionClient := notion.NewNotionAdapter(cfg.NotionKey, cfg.NotionDBID)
-	zabbixClient := zabbix.NewZabbixAdapter(cfg.ZabbixURL, cfg.ZabbixUser, cfg.ZabbixPass)
-	ubersmithClient := ubersmith.NewUbersmithAdapter(cfg.UbersmithURL, cfg.UbersmithUser, cfg.UbersmithPass)
+	// Un solo límite para todos los clientes: acota las conexiones simultáneas del proceso
+	inFlight := httpclient.NewInFlightLimit(cfg.MaxInflightHTTP)
+
+	notionHTTP := mustHTTPClient(httpclient.Options{
+		Name:                "Notion",
+		ProxyURL:            cfg.OutboundProxy,
+		Debug:               cfg.HTTPDebug,
+		UserAgent:           cfg.UserAgent,
+		InFlight:            inFlight,
+		MaxIdleConns:        cfg.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.HTTPIdleConnTimeout,
+	})
+	notionClient := notion.NewNotionAdapter(cfg.NotionKey, cfg.NotionDBIDs, notionHTTP,
+		ratelimit.New(cfg.NotionRPS, cfg.NotionBurst), notion.Options{
+			BaseURL: cfg.NotionAPIURL,
+			Version: cfg.NotionVersion,
+			Properties: notion.Properties{
+				OLT:         cfg.NotionOLTProp,
+				Description: cfg.NotionDescProp,
+				ONT:         cfg.NotionONTProps,
+				VLAN:        cfg.NotionVLANProp,
+			},
+			CIDFormats: cfg.NotionCIDFormats,
+			BatchSize:  cfg.NotionBatchSize,
+			Verbose:    cfg.Verbose,
+		})
+
+	zabbixHTTP := mustHTTPClient(httpclient.Options{
+		Name:                "Zabbix",
+		InsecureSkipVerify:  cfg.ZabbixInsecureSkipVerify,
+		CACertPath:          cfg.ZabbixCACert,
+		ProxyURL:            cfg.OutboundProxy,
+		Debug:               cfg.HTTPDebug,
+		UserAgent:           cfg.UserAgent,
+		InFlight:            inFlight,
+		MaxIdleConns:        cfg.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.HTTPIdleConnTimeout,
+	})
+	zabbixClient := zabbix.NewZabbixAdapter(cfg.ZabbixURL, cfg.ZabbixUser, cfg.ZabbixPass, zabbixHTTP,
+		ratelimit.New(cfg.ZabbixRPS, 1), zabbix.Options{
+			Keys: zabbix.KeyTemplates{
+				Status: cfg.StatusKeyTemplate,
+				Power:  cfg.PowerKeyTemplate,
+			},
+			CheckHost:    cfg.ZabbixHostCheck,
+			StatusLabels: cfg.GponStatusMap,
+			APIToken:     cfg.ZabbixAPIToken,
+			AuthMode:     cfg.ZabbixAuthMode,
+			PowerTag:     cfg.ZabbixPowerTag,
+			MaxValueAge:  cfg.ZabbixMaxValueAge,
+			Verbose:      cfg.Verbose,
+			PowerFormat: &zabbix.PowerFormat{
+				Unit:        cfg.RxPowerUnit,
+				Precision:   cfg.RxPowerPrecision,
+				ZeroAsEmpty: cfg.RxPowerZeroAsEmpty,
+			},
+		})
+
+	ubersmithHTTP := mustHTTPClient(httpclient.Options{
+		Name:                "Ubersmith",
+		Timeout:             30 * time.Second, // Las búsquedas de custom fields pueden ser lentas
+		InsecureSkipVerify:  cfg.UbersmithInsecureSkipVerify,
+		CACertPath:          cfg.UbersmithCACert,
+		ProxyURL:            cfg.OutboundProxy,
+		Debug:               cfg.HTTPDebug,
+		UserAgent:           cfg.UserAgent,
+		InFlight:            inFlight,
+		MaxIdleConns:        cfg.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.HTTPIdleConnTimeout,
+	})
+	ubersmithClient := ubersmith.NewUbersmithAdapter(cfg.UbersmithURL, cfg.UbersmithUser, cfg.UbersmithPass, ubersmithHTTP,
+		ratelimit.New(cfg.UbersmithRPS, 1), ubersmith.Options{
+			RequiredFields: cfg.UbersmithRequiredFields,
+			UserFields:     cfg.UbersmithUserFields,
+			PassFields:     cfg.UbersmithPassFields,
+			VLANFields:     cfg.UbersmithVLANFields,
+			Methods:        ubersmith.ParseMethods(cfg.UbersmithMethods),
+			FieldCacheTTL:  cfg.UbersmithFieldCacheTTL,
+			HeuristicMatch: cfg.UbersmithHeuristicMatch,
+		})
+
+	// Modo verificación: probar cada conexión y salir
+	if *check {
+		os.Exit(runChecks(dbRepo, dbErr, zabbixClient, notionClient, ubersmithClient))
+	}
 
 	// 3. Core
 	pool := core.NewWorkerPool(cfg.WorkerCount, notionClient, zabbixClient, ubersmithClient)
+	pool.SetVLANRange(core.VLANRange{Min: cfg.VLANMin, Max: cfg.VLANMax})
+	pool.SetVLANSources(vlanSources(cfg.VLANSource))
+	pool.SetONTPolicy(core.ONTPolicy(cfg.ONTPolicy))
+	if cfg.ZabbixProblemCheck {
+		pool.SetProblemCheck(cfg.ZabbixProblemSeverity)
+	}
+	pool.SetRxPowerRange(core.RxPowerRange{Min: cfg.RxPowerMin, Max: cfg.RxPowerMax})
+	pool.SetOrdered(cfg.OrderedResults)
+	pool.SetQueueBuffer(cfg.QueueBuffer)
+	pool.SetNetworkPrefetch(cfg.NotionBatchQuery)
+	pool.SetKeepUnresolved(cfg.ZabbixOnlyResolved)
+	pool.SetNotionAuthFatal(cfg.NotionAuthFailFast)
+	pool.SetCallGrace(drainTimeout(cfg.ShutdownGrace))
+	pool.SetVerbose(cfg.Verbose)
+	pool.SetStageBudget(core.StageBudget{Total: cfg.CircuitTimeout, Shares: cfg.CircuitTimeoutShares})
+	pool.SetStageEnabled(core.StageNotion, cfg.EnableNotion)
+	pool.SetStageEnabled(core.StageUbersmith, cfg.EnableUbersmith)
+	pool.SetStageEnabled(core.StageZabbix, cfg.EnableZabbix)
+	if !cfg.EnableNotion || !cfg.EnableUbersmith || !cfg.EnableZabbix {
+		log.Printf("⚙️  Sincronización parcial: se escriben solo las columnas %s", strings.Join(cfg.WriteColumns(), ", "))
+	}
+
+	// Sin Zabbix no hay que autenticarse en cada ciclo (interfaz nil, no un puntero nil)
+	var zabbixAuth app.ZabbixAuthenticator
+	if cfg.EnableZabbix {
+		zabbixAuth = zabbixClient
+	}
 
 	// 4. Configurar canal para señales de interrupción
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	// 5. Configurar ticker para ejecución cada 10 minutos
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-
 	// Contexto para controlar la ejecución
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	if cfg.PersistentWorkers {
+		pool.Start(ctx)
+	}
+
+	// Apagado ordenado: ante una señal dejamos de tomar circuitos nuevos, pero los que están en curso
+	// terminan y el batch pendiente se guarda. Si no alcanza el tiempo de gracia, se fuerza la salida.
+	go func() {
+		<-sigChan
+		log.Printf("\n🛑 Señal de interrupción recibida. Terminando circuitos en curso (máximo %s)...", cfg.ShutdownGrace)
+		cancel()
+		time.AfterFunc(cfg.ShutdownGrace, func() {
+			log.Printf("[FATAL] El apagado superó SHUTDOWN_GRACE (%s). Forzando salida", cfg.ShutdownGrace)
+			os.Exit(exitFatal)
+		})
+	}()
+
+	syncApp := app.NewApp(dbRepo, pool, zabbixAuth, app.Options{
+		BatchSize:    cfg.BatchSize,
+		DryRun:       cfg.DryRun,
+		DryRunOutput: cfg.DryRunOutput,
+		DrainTimeout: drainTimeout(cfg.ShutdownGrace),
+		Allowlist:    cfg.CircuitAllowlist,
+		Denylist:     cfg.CircuitDenylist,
+		ChangeFields: cfg.ChangeFields(),
+
+		RetryDeadLetter: *retryDeadLetter,
+		InsertMissing:   cfg.InsertMissing,
+		ProgressEvery:   cfg.ProgressEvery,
+		MaxRunDuration:  cfg.MaxRunDuration,
+		Verbose:         cfg.Verbose,
+		LogCoverage:     cfg.LogCoverage,
+		PriorityCIDs:    cfg.PriorityCIDs,
+		PriorityOLTs:    cfg.PriorityOLTs,
+	})
+
+	// Auditoría: una línea JSON por circuito escrito, separada del log operativo
+	if cfg.AuditLog != "" {
+		auditLog, err := audit.Open(cfg.AuditLog)
+		if err != nil {
+			log.Printf("[FATAL] %v", err)
+			os.Exit(exitFatal)
+		}
+		defer auditLog.Close()
+		syncApp.SetAudit(auditLog)
+	}
+
+	// Sink externo: cada resultado se publica además de (o en lugar de) escribirse en la DB
+	if cfg.ResultSinkEnabled {
+		sinkHTTP := mustHTTPClient(httpclient.Options{
+			Name:                "Webhook",
+			ProxyURL:            cfg.OutboundProxy,
+			Debug:               cfg.HTTPDebug,
+			UserAgent:           cfg.UserAgent,
+			InFlight:            inFlight,
+			MaxIdleConns:        cfg.HTTPMaxIdleConns,
+			MaxIdleConnsPerHost: cfg.HTTPMaxIdleConnsPerHost,
+			IdleConnTimeout:     cfg.HTTPIdleConnTimeout,
+		})
+		syncApp.SetSink(webhook.NewWebhookSink(cfg.ResultSinkURL, sinkHTTP, webhook.Options{
+			Token: cfg.ResultSinkToken,
+		}), cfg.ResultSinkOnly)
+		if cfg.ResultSinkOnly {
+			log.Printf("📤 RESULT_SINK_ONLY: los resultados se publican en %s y NO se escriben en la DB", cfg.ResultSinkURL)
+		}
+	}
+
+	// Dead-letter: circuitos que fallan ciclo tras ciclo se omiten hasta reprocesarlos a mano
+	var deadLetters *deadletter.Store
+	if cfg.DeadLetterFile != "" {
+		deadLetters, err = deadletter.Open(cfg.DeadLetterFile, cfg.DeadLetterThreshold)
+		if err != nil {
+			log.Printf("[FATAL] %v", err)
+			os.Exit(exitFatal)
+		}
+		syncApp.SetDeadLetter(deadLetters)
+	} else if *retryDeadLetter {
+		log.Printf("[FATAL] -retry-deadletter requiere DEADLETTER_FILE")
+		os.Exit(exitFatal)
+	}
 
-	// Función para ejecutar el proceso
-	runProcess := func() {
+	syncApp.AddRunCache("notion", notionClient)
+	if cfg.UbersmithHeuristicMatch {
+		syncApp.AddRunCache("ubersmith_fields", ubersmithClient)
+	}
+
+	// Estado del último ciclo (se conserva entre reinicios si STATUS_FILE está definido)
+	tracker, err := status.Open(cfg.StatusFile)
+	if err != nil {
+		log.Printf("[FATAL] %v", err)
+		os.Exit(exitFatal)
+	}
+
+	// Función para ejecutar el proceso. Retorna el código de salida que corresponde al ciclo.
+	runProcess := func() int {
 		log.Println("\n" + strings.Repeat("=", 60))
 		log.Println("🚀 Iniciando proceso de sincronización...")
 		log.Println(strings.Repeat("=", 60))
 
-		// Autenticación de Zabbix (reautenticar cada vez por si expira el token)
-		log.Println("Autenticando con Zabbix...")
-		if err := zabbixClient.Authenticate(); err != nil {
-			log.Printf("[ERROR] Error autenticando con Zabbix: %v", err)
-			return
+		if err := tracker.Begin(); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+		summary, err := syncApp.RunOnce(ctx)
+		if errors.Is(err, app.ErrRunInProgress) {
+			log.Printf("[WARN] ⏭️  Ejecución omitida: %v", err)
+			return exitOK
+		}
+		if err := tracker.Finish(status.Result{
+			Processed:   summary.Processed,
+			Success:     summary.Success,
+			Errors:      summary.Errors,
+			Interrupted: summary.Interrupted,
+			Err:         err,
+		}); err != nil {
+			log.Printf("[WARN] %v", err)
 		}
-		log.Println("✅ Autenticación con Zabbix exitosa")
-
-		// Obtener circuitos
-		log.Println("Obteniendo circuitos...")
-		circuits, err := dbRepo.FetchPendingCircuits()
 		if err != nil {
-			log.Printf("[ERROR] Error obteniendo circuitos: %v", err)
-			return
+			log.Printf("[ERROR] %v", err)
+			return exitFatal
 		}
 
-		if len(circuits) == 0 {
-			log.Println("⚠️  No hay circuitos pendientes para procesar")
-			return
+		logSummary(summary)
+		if deadLetters != nil {
+			logDeadLetters(deadLetters.Dead())
+		}
+		if summary.Interrupted {
+			log.Println("🛑 Proceso interrumpido: se guardó lo procesado hasta el momento")
+		} else {
+			log.Println("✅ Proceso completado")
 		}
 
-		log.Printf("Procesando %d circuitos...", len(circuits))
-		resultsCh := pool.Run(circuits)
+		if summary.HasErrors() {
+			return exitCircuitErrors
+		}
+		return exitOK
+	}
 
-		// Acumulador para Batch Update
-		var batch []core.EnrichedData
-		batchSize := 100
+	// Modo CID único: depuración de un circuito puntual (respeta DRY_RUN)
+	if *singleCID != "" {
+		summary, err := syncApp.RunCircuit(ctx, *singleCID)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			os.Exit(exitFatal)
+		}
+		logSummary(summary)
+		if summary.HasErrors() {
+			os.Exit(exitCircuitErrors)
+		}
+		os.Exit(exitOK)
+	}
 
-		// Contador para seguimiento
-		processedCount := 0
-		successCount := 0
-		errorCount := 0
+	// Modo export: enriquecer, escribir el CSV y salir (no se escribe en la DB)
+	if cfg.ExportCSV != "" {
+		log.Printf("🎯 Exportando resultados enriquecidos a %s", cfg.ExportCSV)
+		summary, err := syncApp.Export(ctx, cfg.ExportCSV)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			os.Exit(exitFatal)
+		}
+		logSummary(summary)
+		if summary.HasErrors() {
+			os.Exit(exitCircuitErrors)
+		}
+		os.Exit(exitOK)
+	}
+
+	// Modo ejecución única: un ciclo y salimos con el código correspondiente
+	if cfg.RunOnce {
+		log.Printf("🎯 Iniciando worker de sincronización GPON %s (ejecución única)", version.String())
+		code := runProcess()
+		log.Printf("🏁 Ejecución única finalizada (código de salida %d)", code)
+		os.Exit(code)
+	}
 
-		for res := range resultsCh {
-			processedCount++
+	// 5. Loop principal: primera ejecución inmediata, luego cada SYNC_INTERVAL (+ SYNC_JITTER)
+	log.Printf("🎯 Iniciando worker de sincronización GPON %s", version.String())
+	if cfg.SyncJitter > 0 {
+		log.Printf("📅 Ejecución automática cada %s (+ hasta %s de jitter)", cfg.SyncInterval, cfg.SyncJitter)
+	} else {
+		log.Printf("📅 Ejecución automática cada %s", cfg.SyncInterval)
+	}
 
-			// Log detallado para cada instancia
-			log.Printf("\n=== INSTANCIA %d: CID=%s ===", processedCount, res.CircuitID)
+	sched := scheduler.New(cfg.SyncInterval, cfg.SyncJitter)
 
-			if res.Error != nil {
-				errorCount++
-				log.Printf("[ERROR] CID %s: %v", res.CircuitID, res.Error)
-				log.Printf("[DETALLE] PPPoEUser=%s, StatusGpon=%s, RxPower=%s",
-					res.PPPoEUsername, res.StatusGpon, res.RxPower)
-			} else {
-				successCount++
-				log.Printf("[OK] CID %s procesado exitosamente", res.CircuitID)
-				log.Printf("[DETALLE] PPPoEUser=%s, StatusGpon=%s, RxPower=%s",
-					res.PPPoEUsername, res.StatusGpon, res.RxPower)
+	// Endpoint /status: último ciclo y próxima ejecución en JSON
+	if cfg.StatusAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /status", tracker.Handler(sched.NextRun))
+		srv := &http.Server{Addr: cfg.StatusAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("[ERROR] Servidor de /status: %v", err)
 			}
+		}()
+		defer srv.Close()
+		log.Printf("📊 Estado disponible en http://%s/status", cfg.StatusAddr)
+	}
 
-			batch = append(batch, res)
-
-			if len(batch) >= batchSize {
-				if cfg.DryRun {
-					log.Printf("[DRY-RUN] Se actualizaría batch de %d items (NO se guardó)", len(batch))
-					for _, item := range batch {
-						log.Printf("[DRY-RUN]   CID=%s → RxPower=%s, StatusGpon=%s, PPPoEUser=%s",
-							item.CircuitID, item.RxPower, item.StatusGpon, item.PPPoEUsername)
-					}
-				} else {
-					if err := dbRepo.UpdateCircuitBatch(batch); err != nil {
-						log.Printf("[CRITICAL] Fallo al guardar batch: %v", err)
-					} else {
-						log.Printf("✅ Batch guardado en DB (%d items)", len(batch))
-					}
-				}
-				batch = nil
-			}
+	sched.SetMaxBackoff(cfg.SyncBackoffMax)
+	sched.Start(ctx, func() error {
+		code := runProcess()
+		if ctx.Err() == nil {
+			log.Printf("⏰ Esperando próxima ejecución\n")
 		}
+		// Solo un fallo del ciclo completo (DB, autenticación) activa el backoff,
+		// no los errores de circuitos individuales
+		if code == exitFatal {
+			return errors.New("error fatal del ciclo")
+		}
+		return nil
+	})
+	log.Println("✅ Worker detenido correctamente")
+}
 
-		// Guardar remanentes
-		if len(batch) > 0 {
-			if cfg.DryRun {
-				log.Printf("[DRY-RUN] Se actualizaría batch final de %d items (NO se guardó)", len(batch))
-				for _, item := range batch {
-					log.Printf("[DRY-RUN]   CID=%s → RxPower=%s, StatusGpon=%s, PPPoEUser=%s",
-						item.CircuitID, item.RxPower, item.StatusGpon, item.PPPoEUsername)
-				}
-			} else {
-				if err := dbRepo.UpdateCircuitBatch(batch); err != nil {
-					log.Printf("[CRITICAL] Fallo al guardar batch final: %v", err)
-				} else {
-					log.Printf("✅ Batch final guardado en DB (%d items)", len(batch))
-				}
+// logSummary imprime el resumen de un ciclo de sincronización
+func logSummary(s app.RunSummary) {
+	log.Printf("\n=== RESUMEN ===")
+	log.Printf("Total procesados: %d", s.Processed)
+	log.Printf("Exitosos: %d", s.Success)
+	log.Printf("Con errores: %d", s.Errors)
+	for _, stage := range []core.Stage{core.StageNotion, core.StageUbersmith, core.StageZabbix} {
+		if n := s.ErrorsBySource[stage]; n > 0 {
+			log.Printf("  Errores %s: %d", stage, n)
+		}
+		if n := s.WarningsBySource[stage]; n > 0 {
+			log.Printf("  Advertencias %s: %d", stage, n)
+		}
+	}
+	if len(s.Coverage) > 0 {
+		log.Printf("Cobertura de datos:")
+		for _, c := range core.Coverages {
+			log.Printf("  %s: %d", coverageLabels[c], s.Coverage[c])
+		}
+	}
+	if s.Stats != nil {
+		log.Printf("Duración del enriquecimiento: %s (circuitos con errores parciales: %d)",
+			s.Stats.Duration().Round(time.Millisecond), s.Stats.PartialFailures())
+		for _, stage := range []core.Stage{core.StageNotion, core.StageUbersmith, core.StageZabbix} {
+			if n := s.Stats.Calls(stage); n > 0 {
+				log.Printf("  Latencia promedio %s: %s (%d llamadas)", stage, s.Stats.AvgLatency(stage).Round(time.Millisecond), n)
 			}
 		}
+	}
+	for name, c := range s.Caches {
+		if c.Hits+c.Misses > 0 {
+			log.Printf("Cache %s: %d aciertos, %d consultas", name, c.Hits, c.Misses)
+		}
+	}
+	if s.WriteFailures > 0 {
+		log.Printf("Batches no guardados: %d", s.WriteFailures)
+	}
+	if s.Changed > 0 || s.Unchanged > 0 {
+		log.Printf("Con cambios (escritos): %d, sin cambios (omitidos): %d", s.Changed, s.Unchanged)
+	}
+	if s.Denied > 0 {
+		log.Printf("Omitidos por denylist: %d", s.Denied)
+	}
+	if s.DeadLettered > 0 {
+		log.Printf("Omitidos por dead-letter: %d", s.DeadLettered)
+	}
+	if s.Unmatched > 0 {
+		log.Printf("%d circuitos no tenían fila en la DB", s.Unmatched)
+	}
+	if s.Published > 0 || s.PublishFailed > 0 {
+		log.Printf("Publicados en el sink: %d, fallidos: %d", s.Published, s.PublishFailed)
+	}
+}
+
+// coverageLabels son los nombres de las categorías de cobertura en el resumen
+var coverageLabels = map[core.Coverage]string{
+	core.CoverageNotionNotFound: "No encontrados en Notion",
+	core.CoverageNoUbersmith:    "Sin datos de Ubersmith",
+	core.CoverageNoZabbix:       "Sin datos de Zabbix",
+	core.CoverageFull:           "Enriquecidos por completo",
+}
 
-		log.Printf("\n=== RESUMEN ===")
-		log.Printf("Total procesados: %d", processedCount)
-		log.Printf("Exitosos: %d", successCount)
-		log.Printf("Con errores: %d", errorCount)
-		log.Println("✅ Proceso completado")
-		log.Printf("⏰ Esperando próxima ejecución\n")
+// logDeadLetters lista los circuitos en dead-letter para corregirlos a mano
+func logDeadLetters(dead []deadletter.Entry) {
+	if len(dead) == 0 {
+		return
 	}
+	log.Printf("🪦 %d circuitos en dead-letter:", len(dead))
+	for _, e := range dead {
+		log.Printf("  CID %s: %d fallos seguidos, último %s: %s",
+			e.CID, e.Failures, e.LastFailure.Format(time.RFC3339), e.LastError)
+	}
+}
 
-	// Ejecutar inmediatamente al inicio
-	log.Println("🎯 Iniciando worker de sincronización GPON")
-	log.Println("📅 Ejecución automática cada 10 minutos")
-	log.Printf("⏰ Primera ejecución inmediata, luego cada 10 minutos\n")
-	runProcess()
+// drainTimeout es la parte del plazo de apagado para los circuitos en curso:
+// dos tercios, el resto queda para guardar el último batch
+func drainTimeout(grace time.Duration) time.Duration {
+	return grace * 2 / 3
+}
 
-	// Loop principal: ejecutar cada 10 minutos
-	for {
-		select {
-		case <-ticker.C:
-			runProcess()
-		case <-sigChan:
-			log.Println("\n🛑 Señal de interrupción recibida. Cerrando gracefully...")
-			cancel()
-			log.Println("✅ Worker detenido correctamente")
-			return
-		case <-ctx.Done():
-			log.Println("✅ Contexto cancelado. Cerrando...")
-			return
-		}
+// vlanSources convierte VLAN_SOURCE (ya validada) en las etapas del worker pool
+func vlanSources(names []string) []core.Stage {
+	sources := make([]core.Stage, len(names))
+	for i, name := range names {
+		sources[i] = core.Stage(name)
+	}
+	return sources
+}
+
+// mustHTTPClient crea un cliente HTTP de adaptador o termina el proceso si la configuración es inválida
+func mustHTTPClient(opts httpclient.Options) *http.Client {
+	client, err := httpclient.New(opts)
+	if err != nil {
+		log.Printf("[FATAL] %v", err)
+		os.Exit(exitFatal)
 	}
+	return client
 }
@@ -0,0 +1,137 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"gpon-sync/internal/core"
+)
+
+// Límites de la búsqueda en lote (NOTION_BATCH_QUERY)
+const (
+	DefaultBatchSize = 50  // CIDs por consulta
+	MaxBatchSize     = 100 // Condiciones permitidas por Notion en un filtro compuesto
+	batchPageSize    = 100 // Filas por página de resultados (máximo de la API)
+)
+
+// GetNetworkInfoBatch busca varios CIDs con filtros "or" de condiciones "contains" (uno por lote
+// de hasta BatchSize CIDs, con paginación) y guarda en la cache del ciclo los que encuentra,
+// para que GetNetworkInfo los resuelva sin consultar Notion.
+//
+// Cada fila devuelta se asigna a cada CID del lote con el mismo criterio que la búsqueda
+// individual (ScoreDescription): una fila puede corresponder a varios CIDs y un CID puede
+// tener varias filas candidatas (ej: "fx-150-..." al buscar "15"), se queda con la mejor.
+// Los CIDs que no aparecen no se cachean como inexistentes: GetNetworkInfo los busca uno
+// por uno con todos los formatos (ej: NOTION_CID_FORMATS con ceros de relleno).
+func (n *NotionAdapter) GetNetworkInfoBatch(ctx context.Context, cids []string) (map[string]core.NetworkInfo, error) {
+	found := make(map[string]core.NetworkInfo)
+	pending := cids
+	for _, databaseID := range n.databaseIDs {
+		var missing []string
+		for _, chunk := range chunkCIDs(pending, n.batchSize) {
+			pages, err := n.queryBatch(ctx, databaseID, chunk)
+			if err != nil {
+				return found, fmt.Errorf("base %s: %w", databaseID, err)
+			}
+			matched := matchPages(pages, chunk, n.props.Description)
+			for cid, page := range matched {
+				// Una página con datos faltantes no se cachea (igual que en GetNetworkInfo):
+				// la búsqueda individual devuelve el error con su detalle
+				if info, err := n.networkInfo(page); err == nil {
+					n.store(cid, info, nil)
+					found[cid] = info
+				}
+			}
+			for _, cid := range chunk {
+				if matched[cid] == nil {
+					missing = append(missing, cid)
+				}
+			}
+		}
+		if pending = missing; len(pending) == 0 {
+			break
+		}
+	}
+	return found, nil
+}
+
+// chunkCIDs divide los CIDs en lotes de hasta size
+func chunkCIDs(cids []string, size int) [][]string {
+	if size <= 0 || size > MaxBatchSize {
+		size = MaxBatchSize
+	}
+	var chunks [][]string
+	for start := 0; start < len(cids); start += size {
+		end := min(start+size, len(cids))
+		chunks = append(chunks, cids[start:end])
+	}
+	return chunks
+}
+
+// matchPages asigna a cada CID la fila cuya Description mejor le corresponde (ver BestMatch).
+// Los CIDs sin ninguna fila que les corresponda no aparecen en el resultado.
+func matchPages(pages []notionPage, cids []string, descProp string) map[string]*notionPage {
+	descriptions := make([]string, len(pages))
+	for i, p := range pages {
+		descriptions[i] = propertyText(p.Properties[descProp])
+	}
+	matched := make(map[string]*notionPage)
+	for _, cid := range cids {
+		if best := BestMatch(descriptions, cid); best >= 0 {
+			matched[cid] = &pages[best]
+		}
+	}
+	return matched
+}
+
+// queryBatch trae todas las filas que contienen alguno de los CIDs. Description puede ser
+// title o rich_text: si Notion rechaza el filtro de title (400 validation_error) se repite
+// con rich_text. Cualquier otro error (key inválida, caída, cancelación) se devuelve tal cual.
+func (n *NotionAdapter) queryBatch(ctx context.Context, databaseID string, cids []string) ([]notionPage, error) {
+	pages, err := n.queryBatchKind(ctx, databaseID, cids, "title")
+	if isValidationError(err) {
+		pages, err = n.queryBatchKind(ctx, databaseID, cids, "rich_text")
+	}
+	return pages, err
+}
+
+func (n *NotionAdapter) queryBatchKind(ctx context.Context, databaseID string, cids []string, kind string) ([]notionPage, error) {
+	conditions := make([]map[string]interface{}, len(cids))
+	for i, cid := range cids {
+		conditions[i] = map[string]interface{}{
+			"property": n.props.Description,
+			kind:       map[string]string{"contains": cid},
+		}
+	}
+
+	var pages []notionPage
+	cursor := ""
+	for {
+		body := map[string]interface{}{
+			"filter":    map[string]interface{}{"or": conditions},
+			"page_size": batchPageSize,
+		}
+		if cursor != "" {
+			body["start_cursor"] = cursor
+		}
+		result, err := n.queryNotion(ctx, databaseID, body)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, result.Results...)
+		if !result.HasMore || result.NextCursor == "" {
+			return pages, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// store guarda un resultado definitivo en la cache del ciclo
+func (n *NotionAdapter) store(cid string, info core.NetworkInfo, err error) {
+	n.cacheMu.Lock()
+	defer n.cacheMu.Unlock()
+	if n.cache == nil {
+		n.cache = make(map[string]cachedLookup)
+	}
+	n.cache[cid] = cachedLookup{info: info, err: err}
+}
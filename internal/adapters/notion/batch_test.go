@@ -0,0 +1,244 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gpon-sync/internal/core"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestChunkCIDs(t *testing.T) {
+	cids := make([]string, 230)
+	for i := range cids {
+		cids[i] = fmt.Sprint(1000 + i)
+	}
+	tests := []struct {
+		name string
+		size int
+		want []int // largo de cada lote
+	}{
+		{"lotes de 50", 50, []int{50, 50, 50, 50, 30}},
+		{"sin tamaño usa el máximo", 0, []int{100, 100, 30}},
+		{"sobre el máximo de Notion", 500, []int{100, 100, 30}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkCIDs(cids, tt.size)
+			var got []int
+			seen := 0
+			for _, c := range chunks {
+				got = append(got, len(c))
+				for _, cid := range c {
+					if cid != cids[seen] {
+						t.Fatalf("el lote perdió el orden o repitió CIDs en %s", cid)
+					}
+					seen++
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("lotes = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+	if chunks := chunkCIDs(nil, 50); len(chunks) != 0 {
+		t.Errorf("sin CIDs no debería haber lotes: %v", chunks)
+	}
+}
+
+// testPage arma una fila de Notion con la descripción y la OLT dadas
+func testPage(id, description, olt string) notionPage {
+	var page notionPage
+	raw := fmt.Sprintf(`{"id":%q,"properties":{
+		"Description":{"type":"title","title":[{"plain_text":%q}]},
+		"OLT":{"type":"select","select":{"name":%q}},
+		"":{"type":"rich_text","rich_text":[{"plain_text":"0/1/1"}]}}}`, id, description, olt)
+	if err := json.Unmarshal([]byte(raw), &page); err != nil {
+		panic(err)
+	}
+	return page
+}
+
+func TestMatchPagesResolvesAmbiguousCIDs(t *testing.T) {
+	pages := []notionPage{
+		testPage("p-150", "fx-150-Otro", "OLT-A"),
+		testPage("p-1500", "fx-1500-Otro", "OLT-B"),
+		testPage("p-15", "fx-15-Cliente", "OLT-C"),
+		testPage("p-ref", "enlace 15 / 77 respaldo", "OLT-D"),
+	}
+	got := matchPages(pages, []string{"15", "150", "1500", "77", "16"}, "Description")
+
+	want := map[string]string{"15": "p-15", "150": "p-150", "1500": "p-1500", "77": "p-ref"}
+	for cid, id := range want {
+		if page := got[cid]; page == nil || page.ID != id {
+			t.Errorf("CID %s → %v, se esperaba %s", cid, page, id)
+		}
+	}
+	// "fx-150-..." y "fx-1500-..." contienen "15" pero no le corresponden; 16 no tiene fila
+	if page, ok := got["16"]; ok {
+		t.Errorf("CID 16 → %s, no se esperaba fila", page.ID)
+	}
+	if len(got) != len(want) {
+		t.Errorf("se asignaron %d CIDs, se esperaban %d", len(got), len(want))
+	}
+}
+
+// batchServer responde los filtros "or" con las filas cuya descripción contiene alguno de los
+// textos buscados, paginando de a pageSize. Registra cuántas condiciones trae cada consulta.
+type batchServer struct {
+	pages    []notionPage
+	pageSize int
+
+	mu         sync.Mutex
+	conditions []int
+}
+
+func (s *batchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Filter struct {
+			Or []struct {
+				Title map[string]string `json:"title"`
+			} `json:"or"`
+			Title map[string]string `json:"title"`
+		} `json:"filter"`
+		StartCursor string `json:"start_cursor"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	searched := []string{body.Filter.Title["contains"]}
+	if len(body.Filter.Or) > 0 {
+		searched = searched[:0]
+		for _, c := range body.Filter.Or {
+			searched = append(searched, c.Title["contains"])
+		}
+	}
+	s.mu.Lock()
+	s.conditions = append(s.conditions, len(body.Filter.Or))
+	s.mu.Unlock()
+
+	var matches []notionPage
+	for _, p := range s.pages {
+		desc := propertyText(p.Properties["Description"])
+		for _, text := range searched {
+			if text != "" && strings.Contains(desc, text) {
+				matches = append(matches, p)
+				break
+			}
+		}
+	}
+	start := 0
+	fmt.Sscan(body.StartCursor, &start)
+	end := min(start+s.pageSize, len(matches))
+	resp := notionQueryResp{Results: matches[start:end]}
+	if end < len(matches) {
+		resp.HasMore, resp.NextCursor = true, fmt.Sprint(end)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func TestGetNetworkInfoBatch(t *testing.T) {
+	var cids []string
+	srv := &batchServer{pageSize: 10}
+	for i := 0; i < 120; i++ {
+		cid := fmt.Sprint(2000 + i)
+		cids = append(cids, cid)
+		// Un CID de cada cuatro no está en Notion
+		if i%4 != 3 {
+			srv.pages = append(srv.pages, testPage("page-"+cid, "fx-"+cid+"-Cliente", "OLT-"+cid))
+		}
+	}
+	n := newTestAdapter(t, srv.ServeHTTP, Options{BatchSize: 50})
+
+	found, err := n.GetNetworkInfoBatch(context.Background(), cids)
+	if err != nil {
+		t.Fatalf("GetNetworkInfoBatch: %v", err)
+	}
+	if len(found) != 90 {
+		t.Errorf("se encontraron %d CIDs, se esperaban 90", len(found))
+	}
+	for cid, info := range found {
+		if info.PageID != "page-"+cid || info.OLT != "OLT-"+cid {
+			t.Errorf("CID %s → página %s, OLT %s", cid, info.PageID, info.OLT)
+		}
+	}
+	// Lotes de 50, 50 y 20 condiciones: los dos primeros traen 4 páginas de filas cada uno y el último 2
+	if got := fmt.Sprint(srv.conditions); got != "[50 50 50 50 50 50 50 50 20 20]" {
+		t.Errorf("condiciones por consulta = %s", got)
+	}
+
+	// Los encontrados quedan en la cache del ciclo; los faltantes se buscan uno por uno
+	requests := len(srv.conditions)
+	if info, err := n.GetNetworkInfo(context.Background(), "2000"); err != nil || info.PageID != "page-2000" {
+		t.Errorf("GetNetworkInfo(2000) = %+v, %v", info, err)
+	}
+	if len(srv.conditions) != requests {
+		t.Error("un CID encontrado en lote no debería volver a consultarse")
+	}
+	if _, err := n.GetNetworkInfo(context.Background(), "2003"); err == nil {
+		t.Error("se esperaba ErrCircuitNotFound para un CID que no está en Notion")
+	}
+	if len(srv.conditions) == requests {
+		t.Error("un CID no encontrado en lote debería buscarse individualmente")
+	}
+}
+
+func TestQueryBatchFallsBackOnlyOnValidationError(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		code      string
+		wantKinds string // filtros enviados, en orden
+		wantErr   bool
+		wantAuth  bool
+	}{
+		{"title rechazado", http.StatusBadRequest, "validation_error", "title,rich_text", false, false},
+		{"key inválida", http.StatusUnauthorized, "unauthorized", "title", true, true},
+		{"base no compartida", http.StatusForbidden, "restricted_resource", "title", true, true},
+		{"base inexistente", http.StatusNotFound, "object_not_found", "title", true, false},
+		{"otro 400", http.StatusBadRequest, "invalid_json", "title", true, false},
+		{"Notion caído", http.StatusServiceUnavailable, "service_unavailable", "title,title,title", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var kinds []string
+			n := newTestAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Filter struct {
+						Or []map[string]interface{} `json:"or"`
+					} `json:"filter"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				kind := "rich_text"
+				if _, ok := body.Filter.Or[0]["title"]; ok {
+					kind = "title"
+				}
+				mu.Lock()
+				kinds = append(kinds, kind)
+				mu.Unlock()
+
+				if kind == "title" {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(tt.status)
+					fmt.Fprintf(w, `{"object":"error","code":%q,"message":"rechazado"}`, tt.code)
+					return
+				}
+				_, _ = w.Write([]byte(`{"object":"list","results":[],"has_more":false}`))
+			}, Options{})
+
+			_, err := n.queryBatch(context.Background(), "db-1", []string{"157591", "157592"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if errors.Is(err, core.ErrNotionAuth) != tt.wantAuth {
+				t.Errorf("error = %v, ErrNotionAuth esperado: %v", err, tt.wantAuth)
+			}
+			if got := strings.Join(kinds, ","); got != tt.wantKinds {
+				t.Errorf("filtros enviados = %s, se esperaba %s", got, tt.wantKinds)
+			}
+		})
+	}
+}
@@ -2,30 +2,194 @@ package notion
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"gpon-sync/internal/core"
+	"gpon-sync/internal/ratelimit"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 type NotionAdapter struct {
-	apiKey     string
-	databaseID string
-	client     *http.Client
-	// Rate limiter: Notion permite ~3 requests por segundo
-	lastRequest time.Time
-	mu          sync.Mutex
+	apiKey      string
+	databaseIDs []string // Bases de Notion en orden de búsqueda (ej: una por región)
+	client      *http.Client
+	// Rate limiter (token bucket): Notion permite ~3 requests por segundo.
+	// Es compartido por todas las bases porque consumen la misma cuota de la API.
+	limiter *ratelimit.Limiter
+	baseURL string     // URL base de la API (ej: https://api.notion.com/v1)
+	version string     // Header Notion-Version de todas las requests
+	props   Properties // Nombres de las columnas de la base
+
+	// Formatos adicionales del CID en Description ("{cid}" se reemplaza por el CID)
+	cidFormats []string
+
+	// CIDs por consulta en GetNetworkInfoBatch
+	batchSize int
+
+	// Logs [DEBUG] por CID
+	verbose bool
+
+	// Cache por ciclo de las búsquedas por CID (ver ResetCache)
+	cacheMu sync.Mutex
+	cache   map[string]cachedLookup
+	hits    int
+	misses  int
 }
 
-func NewNotionAdapter(apiKey, databaseID string) *NotionAdapter {
+// cachedLookup es el resultado de una búsqueda ya resuelta en el ciclo actual
+type cachedLookup struct {
+	info core.NetworkInfo
+	err  error
+}
+
+// ErrCircuitNotFound indica que el CID no está en ninguna de las bases configuradas
+var ErrCircuitNotFound error = &core.DataError{Err: errors.New("circuit not found in notion")}
+
+// DefaultVersion es la versión de la API de Notion con la que se probó el adaptador
+const DefaultVersion = "2022-06-28"
+
+// DefaultBaseURL es la URL de la API pública de Notion
+const DefaultBaseURL = "https://api.notion.com/v1"
+
+// Properties son los nombres de las columnas de la base de Notion
+type Properties struct {
+	OLT         string   // Hostname de la OLT (select, rich_text o title)
+	Description string   // Columna donde se busca el CID (title o rich_text)
+	ONT         []string // Candidatas para el ONT ID, en orden (la primera que exista)
+	VLAN        string   // Columna opcional con la VLAN (vacío = la base no la tiene)
+}
+
+// DefaultProperties son los nombres de la base original.
+// La columna </> llega con nombre vacío "" en la respuesta real; "</>" queda como alternativa.
+func DefaultProperties() Properties {
+	return Properties{
+		OLT:         "OLT",
+		Description: "Description",
+		ONT:         []string{"", "</>"},
+	}
+}
+
+// Options contiene los ajustes opcionales del adaptador
+type Options struct {
+	BaseURL    string     // URL base de la API; vacío = DefaultBaseURL (otra URL solo para pruebas, ej: cmd/fakeupstream)
+	Version    string     // Header Notion-Version; vacío = DefaultVersion
+	Properties Properties // Campos vacíos = DefaultProperties
+
+	// Formatos del CID que se prueban después de los de fábrica (fx-CID-, fxCID, fx-CID),
+	// ej: "fx-0{cid}-" o "MIA-{cid}". "{cid}" se reemplaza por el CID.
+	CIDFormats []string
+
+	// CIDs por consulta en GetNetworkInfoBatch (0 = DefaultBatchSize; máximo MaxBatchSize)
+	BatchSize int
+
+	// Logs [DEBUG] por CID (base y formato con que se encontró)
+	Verbose bool
+}
+
+// NewNotionAdapter crea el adaptador. client viene de httpclient.New (timeout, proxy).
+func NewNotionAdapter(apiKey string, databaseIDs []string, client *http.Client, limiter *ratelimit.Limiter, opts Options) *NotionAdapter {
+	if opts.Version == "" {
+		opts.Version = DefaultVersion
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	defaults := DefaultProperties()
+	if opts.Properties.OLT == "" {
+		opts.Properties.OLT = defaults.OLT
+	}
+	if opts.Properties.Description == "" {
+		opts.Properties.Description = defaults.Description
+	}
+	if len(opts.Properties.ONT) == 0 {
+		opts.Properties.ONT = defaults.ONT
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
 	return &NotionAdapter{
 		apiKey:      apiKey,
-		databaseID:  databaseID,
-		client:      &http.Client{Timeout: 10 * time.Second},
-		lastRequest: time.Time{},
+		databaseIDs: databaseIDs,
+		client:      client,
+		limiter:     limiter,
+		baseURL:     strings.TrimSuffix(opts.BaseURL, "/"),
+		version:     opts.Version,
+		props:       opts.Properties,
+		cidFormats:  opts.CIDFormats,
+		batchSize:   opts.BatchSize,
+		verbose:     opts.Verbose,
+	}
+}
+
+// CIDPlaceholder es el marcador del CID en los formatos de NOTION_CID_FORMATS
+const CIDPlaceholder = "{cid}"
+
+// searchFormats devuelve los textos a buscar en Description, en orden: los de fábrica y luego los configurados
+func (n *NotionAdapter) searchFormats(circuitID string) []string {
+	formats := []string{
+		fmt.Sprintf("fx-%s-", circuitID), // fx-CID-nombre
+		fmt.Sprintf("fx%s", circuitID),   // fxCID
+		fmt.Sprintf("fx-%s", circuitID),  // fx-CID
+	}
+	for _, f := range n.cidFormats {
+		formats = append(formats, strings.ReplaceAll(f, CIDPlaceholder, circuitID))
 	}
+	return formats
+}
+
+// notionError es el cuerpo de las respuestas de error de Notion
+type notionError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validationError es un 400 validation_error de Notion (ej: un filtro de title sobre una
+// propiedad rich_text): el único error que justifica repetir la consulta con otro filtro
+type validationError struct {
+	detail string
+}
+
+func (e *validationError) Error() string {
+	return e.detail
+}
+
+// isValidationError indica si Notion rechazó el filtro (ver validationError)
+func isValidationError(err error) bool {
+	var ve *validationError
+	return errors.As(err, &ve)
+}
+
+// apiError construye el error de una respuesta no exitosa. Si Notion rechaza la versión
+// de la API (ej: una versión deprecada) se loguea qué configurar.
+func (n *NotionAdapter) apiError(resp *http.Response) error {
+	var body notionError
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+
+	if resp.StatusCode == http.StatusBadRequest &&
+		(strings.Contains(strings.ToLower(body.Message), "notion-version") || strings.Contains(body.Code, "version")) {
+		log.Printf("[ERROR] Notion rechazó la versión de API %q: %s. Actualizar NOTION_VERSION a una versión soportada", n.version, body.Message)
+	}
+
+	detail := fmt.Sprintf("notion api error: %d", resp.StatusCode)
+	if body.Message != "" {
+		detail = fmt.Sprintf("notion api error: %d (%s: %s)", resp.StatusCode, body.Code, body.Message)
+	}
+	// Una key inválida o una base no compartida con la integración afecta a todos los circuitos
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: %s", core.ErrNotionAuth, detail)
+	}
+	if resp.StatusCode == http.StatusBadRequest && body.Code == "validation_error" {
+		return &validationError{detail: detail}
+	}
+	return errors.New(detail)
 }
 
 // Estructuras internas para parsear la respuesta compleja de Notion
@@ -43,48 +207,69 @@ type notionProperty struct {
 	Select *struct {
 		Name string `json:"name"`
 	} `json:"select,omitempty"`
+	// Para Number (ej: ONT ID guardado como número)
+	Number *float64 `json:"number,omitempty"`
+	// Para Formula (ej: OLT calculada a partir de otras columnas)
+	Formula *notionFormula `json:"formula,omitempty"`
 }
 
-type notionQueryResp struct {
-	Results []struct {
-		Properties map[string]notionProperty `json:"properties"`
-	} `json:"results"`
+// notionFormula es el resultado de una propiedad formula: solo uno de los campos viene según Type
+type notionFormula struct {
+	Type    string   `json:"type"` // string, number, boolean o date
+	String  *string  `json:"string,omitempty"`
+	Number  *float64 `json:"number,omitempty"`
+	Boolean *bool    `json:"boolean,omitempty"`
 }
 
-// rateLimit espera el tiempo necesario para respetar el rate limit de Notion
-// Notion permite ~3 requests por segundo, así que esperamos al menos 350ms entre requests
-func (n *NotionAdapter) rateLimit() {
-	n.mu.Lock()
-	defer n.mu.Unlock()
+type notionQueryResp struct {
+	Results    []notionPage `json:"results"`
+	HasMore    bool         `json:"has_more"`
+	NextCursor string       `json:"next_cursor"`
+}
 
-	// Calcular tiempo desde la última request
-	elapsed := time.Since(n.lastRequest)
-	minInterval := 350 * time.Millisecond // ~3 requests por segundo
+// notionPage es una fila de la base
+type notionPage struct {
+	ID         string                    `json:"id"`
+	Properties map[string]notionProperty `json:"properties"`
+}
 
-	if elapsed < minInterval {
-		waitTime := minInterval - elapsed
-		time.Sleep(waitTime)
+// sleepCtx espera la duración indicada o hasta que el contexto se cancele
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	n.lastRequest = time.Now()
+// retryableStatus indica si vale la pena reintentar una respuesta: 429 (rate limit)
+// o un error transitorio del servidor (500-504). El resto de los 4xx falla de inmediato.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || (code >= 500 && code <= 504)
 }
 
 // queryNotion busca en Notion usando un filtro específico
-// Implementa retry con backoff exponencial para manejar errores 429
-func (n *NotionAdapter) queryNotion(filter map[string]interface{}) (*notionQueryResp, error) {
+// Implementa retry con backoff exponencial para manejar errores 429 y 5xx transitorios
+func (n *NotionAdapter) queryNotion(ctx context.Context, databaseID string, filter map[string]interface{}) (*notionQueryResp, error) {
 	maxRetries := 3
 	baseDelay := 1 * time.Second
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		// Rate limiting: esperar antes de cada request
-		n.rateLimit()
+		// Rate limiting: esperar un token antes de cada request.
+		// Varios workers pueden avanzar en paralelo hasta el RPS permitido.
+		if err := n.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
 
-		url := fmt.Sprintf("https://api.notion.com/v1/databases/%s/query", n.databaseID)
+		url := fmt.Sprintf("%s/databases/%s/query", n.baseURL, databaseID)
 
 		jsonData, _ := json.Marshal(filter)
-		req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 		req.Header.Set("Authorization", "Bearer "+n.apiKey)
-		req.Header.Set("Notion-Version", "2022-06-28")
+		req.Header.Set("Notion-Version", n.version)
 		req.Header.Set("Content-Type", "application/json")
 
 		resp, err := n.client.Do(req)
@@ -92,38 +277,37 @@ func (n *NotionAdapter) queryNotion(filter map[string]interface{}) (*notionQuery
 			return nil, err
 		}
 
-		// Si es 429 (Too Many Requests), esperar y reintentar
-		if resp.StatusCode == 429 {
+		// Si es 429 (Too Many Requests) o un 5xx transitorio, esperar y reintentar
+		if retryableStatus(resp.StatusCode) {
 			// Leer el header Retry-After si está disponible
 			retryAfter := resp.Header.Get("Retry-After")
 			resp.Body.Close() // Cerrar el body antes de esperar
 
+			// Si es el último intento, retornar error sin esperar (el body ya se cerró arriba)
+			if attempt == maxRetries-1 {
+				return nil, fmt.Errorf("notion api error: %d (max retries exceeded)", resp.StatusCode)
+			}
+
+			// Backoff exponencial: 1s, 2s, 4s
+			delay := baseDelay * time.Duration(1<<uint(attempt))
 			if retryAfter != "" {
 				// Retry-After viene como número de segundos (string)
+				// Si no se puede parsear, se mantiene el backoff exponencial
 				if retrySeconds, err := strconv.Atoi(retryAfter); err == nil {
-					time.Sleep(time.Duration(retrySeconds) * time.Second)
-				} else {
-					// Si no se puede parsear, usar backoff exponencial
-					delay := baseDelay * time.Duration(1<<uint(attempt))
-					time.Sleep(delay)
+					delay = time.Duration(retrySeconds) * time.Second
 				}
-			} else {
-				// Backoff exponencial: 1s, 2s, 4s
-				delay := baseDelay * time.Duration(1<<uint(attempt))
-				time.Sleep(delay)
 			}
-
-			// Si no es el último intento, continuar
-			if attempt < maxRetries-1 {
-				continue
+			log.Printf("[WARN] Notion respondió %d, reintento %d/%d en %s", resp.StatusCode, attempt+1, maxRetries-1, delay)
+			if err := sleepCtx(ctx, delay); err != nil {
+				return nil, err
 			}
-			// Si es el último intento, retornar error (el body ya se cerró arriba)
-			return nil, fmt.Errorf("notion api error: 429 (max retries exceeded)")
+			continue
 		}
 
 		if resp.StatusCode != 200 {
+			err := n.apiError(resp)
 			resp.Body.Close()
-			return nil, fmt.Errorf("notion api error: %d", resp.StatusCode)
+			return nil, err
 		}
 
 		var result notionQueryResp
@@ -140,136 +324,280 @@ func (n *NotionAdapter) queryNotion(filter map[string]interface{}) (*notionQuery
 	return nil, fmt.Errorf("notion api error: max retries exceeded")
 }
 
-// GetCredentials: Obtiene las credenciales del circuito
-func (n *NotionAdapter) GetNetworkInfo(circuitID string) (string, string, error) {
+// Ping hace una consulta mínima (una fila) a cada base configurada para verificar la API key y el acceso
+func (n *NotionAdapter) Ping(ctx context.Context) error {
+	for _, dbID := range n.databaseIDs {
+		if _, err := n.queryNotion(ctx, dbID, map[string]interface{}{"page_size": 1}); err != nil {
+			return fmt.Errorf("base %s: %w", dbID, err)
+		}
+	}
+	return nil
+}
+
+// ResetCache descarta las búsquedas del ciclo anterior (los datos de Notion pueden haber cambiado)
+func (n *NotionAdapter) ResetCache() {
+	n.cacheMu.Lock()
+	defer n.cacheMu.Unlock()
+	n.cache = make(map[string]cachedLookup)
+	n.hits, n.misses = 0, 0
+}
+
+// CacheStats devuelve los aciertos y fallos de la cache en el ciclo actual
+func (n *NotionAdapter) CacheStats() (hits, misses int) {
+	n.cacheMu.Lock()
+	defer n.cacheMu.Unlock()
+	return n.hits, n.misses
+}
+
+// GetNetworkInfo: Obtiene OLT, ONT ID y la página de Notion de la que salieron,
+// usando la cache del ciclo si ya se buscó.
+// Solo se cachean resultados definitivos (encontrado o no encontrado), no errores de la API.
+// Si la página se encontró pero le faltan datos, se devuelve la página junto con el error.
+func (n *NotionAdapter) GetNetworkInfo(ctx context.Context, circuitID string) (core.NetworkInfo, error) {
+	n.cacheMu.Lock()
+	if entry, ok := n.cache[circuitID]; ok {
+		n.hits++
+		n.cacheMu.Unlock()
+		return entry.info, entry.err
+	}
+	n.misses++
+	n.cacheMu.Unlock()
+
+	info, err := n.lookupNetworkInfo(ctx, circuitID)
+	if err == nil || errors.Is(err, ErrCircuitNotFound) {
+		n.store(circuitID, info, err)
+	}
+	return info, err
+}
+
+// lookupNetworkInfo consulta Notion sin cache.
+// Se buscan las bases configuradas en orden y se usa la primera que tenga el circuito.
+func (n *NotionAdapter) lookupNetworkInfo(ctx context.Context, circuitID string) (core.NetworkInfo, error) {
+	var best *notionPage
+	for _, databaseID := range n.databaseIDs {
+		result, err := n.searchDatabase(ctx, databaseID, circuitID)
+		if err != nil {
+			return core.NetworkInfo{}, err
+		}
+
+		// El filtro "contains" puede devolver varios candidatos (ej: 150 al buscar 15): elegimos el mejor
+		if best = n.bestResult(result, circuitID); best != nil {
+			if n.verbose && len(n.databaseIDs) > 1 {
+				log.Printf("[DEBUG] CID %s encontrado en la base de Notion %s", circuitID, databaseID)
+			}
+			break
+		}
+	}
+
+	if best == nil {
+		return core.NetworkInfo{}, ErrCircuitNotFound
+	}
+	return n.networkInfo(best)
+}
+
+// networkInfo arma el resultado a partir de la página elegida
+func (n *NotionAdapter) networkInfo(best *notionPage) (core.NetworkInfo, error) {
+	info := core.NetworkInfo{
+		PageID:      best.ID,
+		Description: propertyText(best.Properties[n.props.Description]),
+	}
+	if n.props.VLAN != "" {
+		info.VLAN = strings.TrimSpace(propertyText(best.Properties[n.props.VLAN]))
+	}
+	var err error
+	info.OLT, info.ONT, err = n.extractNetworkInfo(best.Properties)
+	if err != nil {
+		// Datos faltantes en la página: solo se corrigen en Notion
+		return info, &core.DataError{Err: err}
+	}
+	return info, nil
+}
+
+// extractNetworkInfo lee la OLT y el ONT ID de las propiedades de la página elegida
+func (n *NotionAdapter) extractNetworkInfo(props map[string]notionProperty) (string, string, error) {
+
+	// EXTRACCIÓN: Obtenemos OLT y ONT ID (1/2/3) de las columnas de Notion
+	// OLT es de tipo "select" según la respuesta real de Notion
+	oltProp, ok := props[n.props.OLT]
+	if !ok {
+		return "", "", fmt.Errorf("propiedad %q (OLT) no encontrada en Notion", n.props.OLT)
+	}
+	olt := propertyText(oltProp)
+	if olt == "" {
+		return "", "", fmt.Errorf("propiedad %q (OLT) vacía en Notion", n.props.OLT)
+	}
+
+	// La primera columna candidata que exista (por defecto "" y luego "</>")
+	var ontProp notionProperty
+	found := false
+	for _, name := range n.props.ONT {
+		if ontProp, found = props[name]; found {
+			break
+		}
+	}
+	if !found {
+		return "", "", fmt.Errorf("propiedad %q (ONT ID) no encontrada en Notion", n.props.ONT)
+	}
+
+	// </> es de tipo rich_text según la respuesta real
+	ont := propertyText(ontProp)
+	if ont == "" {
+		return "", "", fmt.Errorf("propiedad %q (ONT ID) vacía en Notion", n.props.ONT)
+	}
+
+	return olt, ont, nil
+}
+
+// bestResult devuelve el candidato cuya descripción mejor corresponde al CID (ver BestMatch).
+// Si ninguno corresponde, se acepta el primero que contenga un formato de NOTION_CID_FORMATS:
+// BestMatch descarta un CID pegado a otro dígito, que es justamente el caso de "fx-0{cid}-".
+func (n *NotionAdapter) bestResult(result *notionQueryResp, circuitID string) *notionPage {
+	if result == nil {
+		return nil
+	}
+	descriptions := make([]string, len(result.Results))
+	for i, r := range result.Results {
+		descriptions[i] = propertyText(r.Properties[n.props.Description])
+	}
+	if best := BestMatch(descriptions, circuitID); best >= 0 {
+		return &result.Results[best]
+	}
+	for _, f := range n.cidFormats {
+		format := strings.ToLower(strings.ReplaceAll(f, CIDPlaceholder, circuitID))
+		for i, desc := range descriptions {
+			if strings.Contains(strings.ToLower(desc), format) {
+				return &result.Results[i]
+			}
+		}
+	}
+	return nil
+}
+
+// propertyText devuelve el texto de una propiedad sin importar su tipo (select, rich_text, title, number o formula)
+func propertyText(prop notionProperty) string {
+	switch {
+	case prop.Select != nil && prop.Select.Name != "":
+		return prop.Select.Name
+	case len(prop.RichText) > 0:
+		return prop.RichText[0].PlainText
+	case len(prop.Title) > 0:
+		return prop.Title[0].PlainText
+	case prop.Number != nil:
+		return formatNumber(*prop.Number)
+	case prop.Formula != nil:
+		return formulaText(*prop.Formula)
+	}
+	return ""
+}
+
+// formulaText devuelve el resultado de una fórmula como texto
+func formulaText(f notionFormula) string {
+	switch {
+	case f.String != nil:
+		return *f.String
+	case f.Number != nil:
+		return formatNumber(*f.Number)
+	case f.Boolean != nil:
+		return strconv.FormatBool(*f.Boolean)
+	}
+	return ""
+}
+
+// formatNumber formatea sin ceros de relleno: 12 -> "12", 1.5 -> "1.5" (nunca "12.000000")
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// searchDatabase busca el circuito en una base de Notion.
+// Retorna un resultado sin filas si el circuito no está en esa base.
+func (n *NotionAdapter) searchDatabase(ctx context.Context, databaseID, circuitID string) (*notionQueryResp, error) {
 	// ESTRATEGIA DE BÚSQUEDA EN DOS PASOS:
 	// 1. Primero intentamos buscar con el formato específico fx-CID-nombre
 	// 2. Si no encontramos, buscamos cualquier campo que contenga el número CID
 
-	// PASO 1: Buscar con formato fx-CID-nombre (o fxCID)
-	formats := []string{
-		fmt.Sprintf("fx-%s-", circuitID), // fx-CID-nombre
-		fmt.Sprintf("fx%s", circuitID),   // fxCID
-		fmt.Sprintf("fx-%s", circuitID),  // fx-CID
-	}
+	// PASO 1: Buscar con formato fx-CID-nombre (o fxCID, o los de NOTION_CID_FORMATS).
+	// Se detiene en el primer formato con un resultado que corresponda al CID: "contains" también
+	// devuelve otros circuitos (ej: fx-1575910 al buscar fx-157591), que no deben cortar la búsqueda.
+	formats := n.searchFormats(circuitID)
 
 	var result *notionQueryResp
 	var err error
+	matched := ""
 
 	// Intentamos cada formato con Title primero
 	for _, format := range formats {
 		filterBody := map[string]interface{}{
 			"filter": map[string]interface{}{
-				"property": "Description",
+				"property": n.props.Description,
 				"title": map[string]string{
 					"contains": format,
 				},
 			},
 		}
 
-		result, err = n.queryNotion(filterBody)
-		if err == nil && result != nil && len(result.Results) > 0 {
+		result, err = n.queryNotion(ctx, databaseID, filterBody)
+		if err == nil && n.bestResult(result, circuitID) != nil {
+			matched = format
 			break
 		}
 
 		// Si no encontramos con Title, intentamos con RichText
-		if err == nil && (result == nil || len(result.Results) == 0) {
+		if err == nil {
 			filterBodyRichText := map[string]interface{}{
 				"filter": map[string]interface{}{
-					"property": "Description",
+					"property": n.props.Description,
 					"rich_text": map[string]string{
 						"contains": format,
 					},
 				},
 			}
-			result, err = n.queryNotion(filterBodyRichText)
-			if err == nil && result != nil && len(result.Results) > 0 {
+			result, err = n.queryNotion(ctx, databaseID, filterBodyRichText)
+			if err == nil && n.bestResult(result, circuitID) != nil {
+				matched = format
 				break
 			}
 		}
 	}
+	if n.verbose && matched != "" {
+		log.Printf("[DEBUG] Notion: CID %s encontrado con el formato %q en la base %s", circuitID, matched, databaseID)
+	}
 
 	// PASO 2: Si no encontramos con formato específico, buscamos solo el número CID
-	if result == nil || len(result.Results) == 0 {
+	if matched == "" {
 		// Buscar solo el número CID en cualquier parte del campo Description
 		filterBody := map[string]interface{}{
 			"filter": map[string]interface{}{
-				"property": "Description",
+				"property": n.props.Description,
 				"title": map[string]string{
 					"contains": circuitID,
 				},
 			},
 		}
 
-		result, err = n.queryNotion(filterBody)
+		result, err = n.queryNotion(ctx, databaseID, filterBody)
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
 
 		// Si no encontramos con Title, intentamos con RichText
 		if len(result.Results) == 0 {
 			filterBodyRichText := map[string]interface{}{
 				"filter": map[string]interface{}{
-					"property": "Description",
+					"property": n.props.Description,
 					"rich_text": map[string]string{
 						"contains": circuitID,
 					},
 				},
 			}
-			result, err = n.queryNotion(filterBodyRichText)
+			result, err = n.queryNotion(ctx, databaseID, filterBodyRichText)
 			if err != nil {
-				return "", "", err
+				return nil, err
 			}
 		}
 	}
 
-	if result == nil || len(result.Results) == 0 {
-		return "", "", fmt.Errorf("circuit not found in notion")
-	}
-
-	props := result.Results[0].Properties
-
-	// EXTRACCIÓN: Obtenemos OLT y ONT ID (1/2/3) de las columnas de Notion
-	// OLT es de tipo "select" según la respuesta real de Notion
-	oltProp, ok := props["OLT"]
-	if !ok {
-		return "", "", fmt.Errorf("propiedad OLT no encontrada en Notion")
-	}
-
-	var olt string
-	if oltProp.Select != nil && oltProp.Select.Name != "" {
-		// OLT es un campo select
-		olt = oltProp.Select.Name
-	} else if len(oltProp.RichText) > 0 {
-		// Fallback: OLT como RichText
-		olt = oltProp.RichText[0].PlainText
-	} else if len(oltProp.Title) > 0 {
-		// Fallback: OLT como Title
-		olt = oltProp.Title[0].PlainText
-	} else {
-		return "", "", fmt.Errorf("propiedad OLT vacía en Notion")
-	}
-
-	// La columna </> tiene nombre vacío "" (no "</>") según la respuesta real
-	ontProp, ok := props[""]
-	if !ok {
-		// Intentamos también con "</>" por si acaso
-		ontProp, ok = props["</>"]
-		if !ok {
-			return "", "", fmt.Errorf("propiedad </> (ONT ID) no encontrada en Notion")
-		}
-	}
-
-	// </> es de tipo rich_text según la respuesta real
-	var ont string
-	if len(ontProp.RichText) > 0 {
-		ont = ontProp.RichText[0].PlainText
-	} else if len(ontProp.Title) > 0 {
-		// Fallback: </> como Title
-		ont = ontProp.Title[0].PlainText
-	} else {
-		return "", "", fmt.Errorf("propiedad </> (ONT ID) vacía en Notion")
+	if result == nil {
+		result = &notionQueryResp{}
 	}
-
-	return olt, ont, nil
+	return result, nil
 }
@@ -0,0 +1,189 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gpon-sync/internal/core"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestAdapter apunta el adaptador a un servidor de prueba
+func newTestAdapter(t *testing.T, handler http.HandlerFunc, opts Options) *NotionAdapter {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	opts.BaseURL = srv.URL + "/v1"
+	return NewNotionAdapter("secret_test", []string{"db-1"}, srv.Client(), nil, opts)
+}
+
+// flakyServer responde con los status de statuses en orden (Retry-After: 0 para no esperar
+// el backoff) y 200 con una lista vacía cuando se agotan
+func flakyServer(requests *atomic.Int32, statuses ...int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n <= len(statuses) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statuses[n-1])
+			_, _ = w.Write([]byte(`{"object":"error","code":"service_unavailable","message":"try again"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object":"list","results":[],"has_more":false}`))
+	}
+}
+
+func TestQueryNotionRetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      string
+		wantRequests int32
+	}{
+		{"503 dos veces y luego 200", []int{503, 503}, "", 3},
+		{"429 y luego 200", []int{429}, "", 2},
+		{"502 en todos los intentos", []int{502, 502, 502}, "max retries exceeded", 3},
+		{"400 no se reintenta", []int{400}, "notion api error: 400", 1},
+		{"404 no se reintenta", []int{404}, "notion api error: 404", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			n := newTestAdapter(t, flakyServer(&requests, tt.statuses...), Options{})
+
+			_, err := n.queryNotion(context.Background(), "db-1", map[string]interface{}{})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, se esperaba %q", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, se esperaban %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// pagesServer responde todas las consultas con las páginas dadas (JSON de la propiedad "results")
+func pagesServer(pages string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object":"list","has_more":false,"results":` + pages + `}`))
+	}
+}
+
+func TestGetNetworkInfoReturnsStructuredInfo(t *testing.T) {
+	pages := `[
+		{"id":"page-150","properties":{
+			"Description":{"type":"title","title":[{"plain_text":"fx-150-Otro"}]},
+			"OLT":{"type":"select","select":{"name":"OLT-B"}},
+			"":{"type":"rich_text","rich_text":[{"plain_text":"0/1/1"}]},
+			"VLAN":{"type":"number","number":300}}},
+		{"id":"page-15","properties":{
+			"Description":{"type":"title","title":[{"plain_text":"fx-15-Cliente"}]},
+			"OLT":{"type":"select","select":{"name":"OLT-A"}},
+			"":{"type":"rich_text","rich_text":[{"plain_text":"0/2/7"}]},
+			"VLAN":{"type":"rich_text","rich_text":[{"plain_text":" 120 "}]}}}
+	]`
+	n := newTestAdapter(t, pagesServer(pages), Options{Properties: Properties{VLAN: "VLAN"}})
+
+	info, err := n.GetNetworkInfo(context.Background(), "15")
+	if err != nil {
+		t.Fatalf("GetNetworkInfo: %v", err)
+	}
+	want := core.NetworkInfo{OLT: "OLT-A", ONT: "0/2/7", PageID: "page-15", Description: "fx-15-Cliente", VLAN: "120"}
+	if info != want {
+		t.Errorf("info = %+v, se esperaba %+v", info, want)
+	}
+}
+
+func TestGetNetworkInfoMissingDataKeepsPage(t *testing.T) {
+	pages := `[{"id":"page-15","properties":{
+		"Description":{"type":"title","title":[{"plain_text":"fx-15-"}]},
+		"OLT":{"type":"select","select":null},
+		"":{"type":"rich_text","rich_text":[{"plain_text":"0/2/7"}]}}}]`
+	n := newTestAdapter(t, pagesServer(pages), Options{})
+
+	info, err := n.GetNetworkInfo(context.Background(), "15")
+	if err == nil {
+		t.Fatal("se esperaba error por la OLT vacía")
+	}
+	// La página se reporta igual para poder corregirla en Notion
+	if info.PageID != "page-15" || info.Description != "fx-15-" {
+		t.Errorf("info = %+v, se esperaba la página encontrada", info)
+	}
+}
+
+// searchServer es una base de Notion con las descripciones dadas: responde los filtros "contains"
+// de Description (sin distinguir mayúsculas, como Notion) y registra los textos buscados
+type searchServer struct {
+	mu       sync.Mutex
+	searched []string
+}
+
+func (s *searchServer) handler(descriptions ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Filter struct {
+				Title    map[string]string `json:"title"`
+				RichText map[string]string `json:"rich_text"`
+			} `json:"filter"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		contains := body.Filter.Title["contains"]
+		if contains == "" {
+			contains = body.Filter.RichText["contains"]
+		}
+		s.mu.Lock()
+		s.searched = append(s.searched, contains)
+		s.mu.Unlock()
+
+		var pages []string
+		for i, desc := range descriptions {
+			if strings.Contains(strings.ToLower(desc), strings.ToLower(contains)) {
+				pages = append(pages, fmt.Sprintf(`{"id":"page-%d","properties":{
+					"Description":{"type":"title","title":[{"plain_text":%q}]},
+					"OLT":{"type":"select","select":{"name":"OLT-A"}},
+					"":{"type":"rich_text","rich_text":[{"plain_text":"0/2/7"}]}}}`, i, desc))
+			}
+		}
+		_, _ = w.Write([]byte(`{"object":"list","has_more":false,"results":[` + strings.Join(pages, ",") + `]}`))
+	}
+}
+
+func TestGetNetworkInfoCustomCIDFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		formats     []string
+		description string
+		wantPage    string // vacío = no encontrado
+		wantLast    string // último texto buscado: la búsqueda se detiene en el primer formato que encuentra
+	}{
+		{"prefijo regional", []string{"MIA-{cid}", "fx-0{cid}-"}, "MIA-157591 Cliente Norte", "page-0", "MIA-157591"},
+		{"CID con ceros a la izquierda", []string{"MIA-{cid}", "fx-0{cid}-"}, "fx-0157591-Cliente", "page-0", "fx-0157591-"},
+		{"sin formatos configurados", nil, "fx-0157591-Cliente", "", "157591"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &searchServer{}
+			n := newTestAdapter(t, srv.handler("fx-1575910-Otro", tt.description), Options{CIDFormats: tt.formats})
+
+			info, err := n.GetNetworkInfo(context.Background(), "157591")
+			if tt.wantPage == "" {
+				if !errors.Is(err, ErrCircuitNotFound) {
+					t.Errorf("error = %v, se esperaba ErrCircuitNotFound (página %q)", err, info.PageID)
+				}
+			} else if err != nil || info.Description != tt.description {
+				t.Errorf("info = %+v, error = %v; se esperaba la página %q", info, err, tt.description)
+			}
+			if last := srv.searched[len(srv.searched)-1]; last != tt.wantLast {
+				t.Errorf("último texto buscado = %q, se esperaba %q (búsquedas: %v)", last, tt.wantLast, srv.searched)
+			}
+		})
+	}
+}
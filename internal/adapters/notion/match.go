@@ -0,0 +1,72 @@
+package notion
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Puntajes de coincidencia entre la descripción de una fila y un CID,
+// en el mismo orden de prioridad que los formatos de búsqueda
+const (
+	scoreNone       = 0
+	scoreBareCID    = 1 // El CID aparece como número aislado (ej: "circuito 157591 norte")
+	scoreFxNoDash   = 2 // fxCID
+	scoreFxDash     = 3 // fx-CID
+	scoreFxPrefixed = 4 // fx-CID-nombre
+)
+
+// ScoreDescription puntúa qué tan bien una descripción corresponde a un CID.
+// El filtro "contains" de Notion devuelve candidatos como "fx-150-..." al buscar "15":
+// en todos los casos el CID no puede estar pegado a otro dígito.
+func ScoreDescription(description, cid string) int {
+	desc := strings.ToLower(strings.TrimSpace(description))
+	if cid == "" || desc == "" {
+		return scoreNone
+	}
+
+	best := scoreNone
+	for idx := strings.Index(desc, cid); idx >= 0; {
+		end := idx + len(cid)
+		if !digitAt(desc, idx-1) && !digitAt(desc, end) {
+			best = max(best, scoreOccurrence(desc, idx, end))
+		}
+
+		next := strings.Index(desc[idx+1:], cid)
+		if next < 0 {
+			break
+		}
+		idx += next + 1
+	}
+	return best
+}
+
+// scoreOccurrence puntúa una aparición del CID en desc[start:end] según lo que la rodea
+func scoreOccurrence(desc string, start, end int) int {
+	before := desc[:start]
+	switch {
+	case strings.HasSuffix(before, "fx-") && strings.HasPrefix(desc[end:], "-"):
+		return scoreFxPrefixed
+	case strings.HasSuffix(before, "fx-"):
+		return scoreFxDash
+	case strings.HasSuffix(before, "fx"):
+		return scoreFxNoDash
+	}
+	return scoreBareCID
+}
+
+// digitAt indica si s tiene un dígito en la posición i (fuera de rango = false)
+func digitAt(s string, i int) bool {
+	return i >= 0 && i < len(s) && unicode.IsDigit(rune(s[i]))
+}
+
+// BestMatch devuelve el índice de la descripción con mayor puntaje, o -1 si ninguna corresponde al CID.
+// Ante empate gana la primera (el orden en que Notion devolvió los resultados).
+func BestMatch(descriptions []string, cid string) int {
+	best, bestScore := -1, scoreNone
+	for i, desc := range descriptions {
+		if score := ScoreDescription(desc, cid); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
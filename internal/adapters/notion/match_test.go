@@ -0,0 +1,50 @@
+package notion
+
+import "testing"
+
+func TestScoreDescription(t *testing.T) {
+	tests := []struct {
+		description string
+		cid         string
+		want        int
+	}{
+		{"fx-15-Cliente Norte", "15", scoreFxPrefixed},
+		{"FX-15", "15", scoreFxDash},
+		{"fx15 cliente", "15", scoreFxNoDash},
+		{"circuito 15 norte", "15", scoreBareCID},
+		{"fx-150-Cliente", "15", scoreNone},
+		{"fx-115-Cliente", "15", scoreNone},
+		{"circuito 1575", "15", scoreNone},
+		// Gana la mejor de varias apariciones
+		{"ref 150 / fx-15-Sur", "15", scoreFxPrefixed},
+		{"", "15", scoreNone},
+	}
+	for _, tt := range tests {
+		if got := ScoreDescription(tt.description, tt.cid); got != tt.want {
+			t.Errorf("ScoreDescription(%q, %q) = %d, se esperaba %d", tt.description, tt.cid, got, tt.want)
+		}
+	}
+}
+
+func TestBestMatchAmbiguousCIDs(t *testing.T) {
+	tests := []struct {
+		name         string
+		descriptions []string
+		cid          string
+		want         int
+	}{
+		{"15 entre 150 y 1500", []string{"fx-150-A", "fx-1500-B", "fx-15-C"}, "15", 2},
+		{"prefijo completo antes que fx-CID", []string{"fx-15", "fx-15-Cliente"}, "15", 1},
+		{"fx-CID antes que número suelto", []string{"enlace 15", "fx15", "fx-15"}, "15", 2},
+		{"empate: gana el primero", []string{"fx-15-A", "fx-15-B"}, "15", 0},
+		{"ninguno corresponde", []string{"fx-150-A", "fx-151-B"}, "15", -1},
+		{"sin candidatos", nil, "15", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BestMatch(tt.descriptions, tt.cid); got != tt.want {
+				t.Errorf("BestMatch(%q, %q) = %d, se esperaba %d", tt.descriptions, tt.cid, got, tt.want)
+			}
+		})
+	}
+}
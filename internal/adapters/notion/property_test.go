@@ -0,0 +1,59 @@
+package notion
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPropertyTextDecodesEachType(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"title", `{"type":"title","title":[{"plain_text":"fx-157591- Cliente"}]}`, "fx-157591- Cliente"},
+		{"rich_text", `{"type":"rich_text","rich_text":[{"plain_text":"0/1/5"}]}`, "0/1/5"},
+		{"select", `{"type":"select","select":{"name":"OLT-DEMO-01"}}`, "OLT-DEMO-01"},
+		{"select vacío", `{"type":"select","select":null}`, ""},
+		{"number entero", `{"type":"number","number":12}`, "12"},
+		{"number con decimales", `{"type":"number","number":1.5}`, "1.5"},
+		{"number vacío", `{"type":"number","number":null}`, ""},
+		{"formula string", `{"type":"formula","formula":{"type":"string","string":"OLT-NORTE"}}`, "OLT-NORTE"},
+		{"formula number", `{"type":"formula","formula":{"type":"number","number":100}}`, "100"},
+		{"formula boolean", `{"type":"formula","formula":{"type":"boolean","boolean":true}}`, "true"},
+		{"formula sin resultado", `{"type":"formula","formula":{"type":"date","date":null}}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prop notionProperty
+			if err := json.Unmarshal([]byte(tt.json), &prop); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got := propertyText(prop); got != tt.want {
+				t.Errorf("propertyText = %q, se esperaba %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractNetworkInfoFromNumberAndFormula(t *testing.T) {
+	// OLT calculada con una fórmula y el ONT ID en una columna number
+	page := `{"id":"page-1","properties":{
+		"Description":{"type":"title","title":[{"plain_text":"fx-157591-"}]},
+		"OLT":{"type":"formula","formula":{"type":"string","string":"OLT-DEMO-01"}},
+		"ONT":{"type":"number","number":15}
+	}}`
+	var p notionPage
+	if err := json.Unmarshal([]byte(page), &p); err != nil {
+		t.Fatal(err)
+	}
+	n := NewNotionAdapter("key", []string{"db-1"}, nil, nil, Options{Properties: Properties{ONT: []string{"ONT"}}})
+
+	olt, ont, err := n.extractNetworkInfo(p.Properties)
+	if err != nil {
+		t.Fatalf("extractNetworkInfo: %v", err)
+	}
+	if olt != "OLT-DEMO-01" || ont != "15" {
+		t.Errorf("OLT/ONT = %q/%q, se esperaba OLT-DEMO-01/15", olt, ont)
+	}
+}
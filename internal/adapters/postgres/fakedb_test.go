@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeDB es un driver de database/sql en memoria: registra cada statement y responde
+// los SELECT ... IN (...) con las filas de rows, comparando los CIDs sin distinguir mayúsculas
+// como la collation de MySQL (un SELECT sin argumentos devuelve todas las filas).
+// latency simula el round trip de cada statement; se interrumpe si se cancela el contexto.
+type fakeDB struct {
+	mu         sync.Mutex
+	statements []fakeStatement
+	rows       map[string][]string // CID -> valores de las demás columnas seleccionadas
+	execErr    error               // Error que devuelve el próximo Exec (se consume)
+	latency    time.Duration
+	commits    int // Transacciones confirmadas
+}
+
+// committed devuelve la cantidad de transacciones confirmadas
+func (f *fakeDB) committed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commits
+}
+
+// fakeStatement es un statement ejecutado con sus argumentos
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+// sqlNull representa NULL en los valores de rows (y como clave, una fila con CID NULL)
+const sqlNull = "<NULL>"
+
+func newFakeDB(rows map[string][]string) (*fakeDB, *sql.DB) {
+	f := &fakeDB{rows: rows}
+	return f, sql.OpenDB(fakeConnector{f})
+}
+
+// executed devuelve los statements que empiezan con prefix (ej: "UPDATE", "SELECT")
+func (f *fakeDB) executed(prefix string) []fakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeStatement
+	for _, s := range f.statements {
+		if strings.HasPrefix(s.query, prefix) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (f *fakeDB) record(ctx context.Context, query string, args []driver.NamedValue) ([]driver.Value, error) {
+	if f.latency > 0 {
+		timer := time.NewTimer(f.latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, fakeStatement{query: query, args: values})
+	return values, nil
+}
+
+// value convierte un valor de rows al que devuelve el driver
+func value(s string) driver.Value {
+	if s == sqlNull {
+		return nil
+	}
+	return s
+}
+
+type fakeConnector struct{ db *fakeDB }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: c.db}, nil }
+func (c fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return nil, errors.New("usar fakeConnector") }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{db: c.db}, nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, err := c.db.record(ctx, query, args); err != nil {
+		return nil, err
+	}
+	c.db.mu.Lock()
+	err := c.db.execErr
+	c.db.execErr = nil
+	c.db.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values, err := c.db.record(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Columnas entre SELECT y FROM: la primera es el CID y el resto sale de rows
+	selected := strings.TrimPrefix(query[:strings.Index(query, " FROM ")], "SELECT ")
+	columns := strings.Split(selected, ", ")
+
+	rows := &fakeRows{columns: columns}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if len(values) == 0 {
+		cids := make([]string, 0, len(c.db.rows))
+		for cid := range c.db.rows {
+			cids = append(cids, cid)
+		}
+		sort.Strings(cids)
+		for _, cid := range cids {
+			rows.data = append(rows.data, fakeRow(cid, c.db.rows[cid], len(columns)))
+		}
+		return rows, nil
+	}
+	for _, v := range values {
+		arg, _ := v.(string)
+		for cid, stored := range c.db.rows {
+			if !strings.EqualFold(cid, arg) {
+				continue
+			}
+			// La fila se devuelve con el CID tal como está guardado
+			rows.data = append(rows.data, fakeRow(cid, stored, len(columns)))
+		}
+	}
+	return rows, nil
+}
+
+// fakeRow arma una fila con el CID y las primeras columns-1 columnas guardadas
+func fakeRow(cid string, stored []string, columns int) []driver.Value {
+	row := []driver.Value{value(cid)}
+	for i := 1; i < columns; i++ {
+		row = append(row, value(stored[i-1]))
+	}
+	return row
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (t fakeTx) Commit() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.commits++
+	return nil
+}
+func (fakeTx) Rollback() error { return nil }
+
+// fakeStmt solo se usa en la comparación con la escritura fila por fila (ver BenchmarkUpdatePerRow)
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: a}
+	}
+	return s.conn.ExecContext(context.Background(), s.query, named)
+}
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) { return nil, errors.New("no soportado") }
+
+type fakeRows struct {
+	columns []string
+	data    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.data) {
+		return io.EOF
+	}
+	copy(dest, r.data[r.next])
+	r.next++
+	return nil
+}
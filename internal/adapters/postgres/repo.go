@@ -2,83 +2,534 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"gpon-sync/internal/core"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql" // Driver MySQL implícito
+	"github.com/go-sql-driver/mysql" // Driver MySQL (registrado al importar)
 )
 
 type PostgresRepo struct {
-	db *sql.DB
+	db           *sql.DB
+	writeRetries int      // Reintentos de escritura ante errores transitorios (deadlock, conexión)
+	columns      []string // Columnas que se escriben (subconjunto de batchColumns)
+	schema       Schema
+
+	insertMissing bool              // Insertar los CIDs que el UPDATE no encontró
+	insertExtra   map[string]string // Columnas NOT NULL adicionales del INSERT con su valor fijo
+}
+
+// Options contiene los ajustes del pool de conexiones
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	PingTimeout     time.Duration // Si es 0, el ping inicial no tiene límite de tiempo
+	WriteRetries    int           // Reintentos de UpdateCircuitBatch ante errores transitorios
+
+	// Si es true, los CIDs que no existen en circuitos se insertan con los datos enriquecidos.
+	// InsertExtraColumns completa las columnas NOT NULL que no vienen del enriquecimiento (columna -> valor).
+	InsertMissing      bool
+	InsertExtraColumns map[string]string
+
+	// Columnas enriquecidas que se escriben (ej: solo RxPower y StatusGpon en una sincronización parcial).
+	// Si está vacío se escriben todas.
+	Columns []string
+
+	// Nombres de la tabla y columnas (los vacíos toman el valor de DefaultSchema)
+	Schema Schema
+
+	// Omite la verificación de tabla y columnas al arrancar (entornos sin acceso a INFORMATION_SCHEMA)
+	SkipSchemaCheck bool
 }
 
 // NewPostgresRepo: Crea una nueva instancia de PostgresRepo (compatible con MySQL)
-func NewPostgresRepo(connStr string) (*PostgresRepo, error) {
+func NewPostgresRepo(connStr string, opts Options) (*PostgresRepo, error) {
 	db, err := sql.Open("mysql", connStr)
 	if err != nil {
 		return nil, err
 	}
-	if err = db.Ping(); err != nil {
+
+	// Ajustes del pool: se aplican antes de la primera conexión
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+
+	// Ping con timeout para no quedar colgados al arrancar con una DB inalcanzable
+	ctx := context.Background()
+	if opts.PingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.PingTimeout)
+		defer cancel()
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, err
 	}
-	return &PostgresRepo{db: db}, nil
+	r := NewPostgresRepoFromDB(db, opts)
+
+	// Una columna mal configurada fallaría recién al escribir el primer batch, después de enriquecer todo
+	if !opts.SkipSchemaCheck {
+		if err := r.checkSchema(ctx); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// NewPostgresRepoFromDB crea el repositorio sobre una conexión ya abierta (ej: un *sql.DB de sqlmock).
+// De opts solo se usan los ajustes de escritura y esquema: el pool, el ping y la verificación
+// de esquema quedan a cargo de quien abrió db.
+func NewPostgresRepoFromDB(db *sql.DB, opts Options) *PostgresRepo {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = batchColumns
+	}
+	return &PostgresRepo{
+		db:            db,
+		writeRetries:  opts.WriteRetries,
+		columns:       columns,
+		schema:        opts.Schema.withDefaults(),
+		insertMissing: opts.InsertMissing,
+		insertExtra:   opts.InsertExtraColumns,
+	}
+}
+
+// checkSchema verifica en INFORMATION_SCHEMA que la tabla y las columnas configuradas existan
+func (r *PostgresRepo) checkSchema(ctx context.Context) error {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", r.schema.Table)
+	if err != nil {
+		return fmt.Errorf("no se pudo verificar el esquema (se puede omitir con DB_SCHEMA_CHECK=false): %w", err)
+	}
+	defer rows.Close()
+
+	// MySQL compara nombres de columna sin distinguir mayúsculas
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		existing[strings.ToLower(name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return fmt.Errorf("la tabla %s no existe en la base de datos (revisar DB_TABLE)", r.schema.Table)
+	}
+
+	required := []string{r.schema.CID}
+	for _, name := range r.columns {
+		required = append(required, r.schema.column(name))
+	}
+	if r.insertMissing {
+		for col := range r.insertExtra {
+			required = append(required, col)
+		}
+	}
+
+	var missing []string
+	for _, col := range required {
+		if !existing[strings.ToLower(col)] {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("columnas faltantes en la tabla %s: %s (revisar COL_* / INSERT_EXTRA_COLUMNS)",
+			r.schema.Table, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Ping verifica que la DB siga respondiendo
+func (r *PostgresRepo) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
 // FetchPendingCircuits: Obtiene TODOS los circuitos sin discriminar valores vacíos
-func (r *PostgresRepo) FetchPendingCircuits() ([]core.Circuit, error) {
+func (r *PostgresRepo) FetchPendingCircuits(ctx context.Context) ([]core.Circuit, error) {
 	// Según requerimiento: obtener TODOS los CID sin filtro
-	query := `SELECT CID FROM circuitos`
+	query := "SELECT " + quote(r.schema.CID) + " FROM " + quote(r.schema.Table)
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var circuits []core.Circuit
+	skipped := 0
 	for rows.Next() {
-		var c core.Circuit
-		// Solo escaneamos circuit_id (CID), los demás campos se obtienen después
-		if err := rows.Scan(&c.CID); err != nil {
+		// Solo escaneamos circuit_id (CID), los demás campos se obtienen después.
+		// Un CID NULL o vacío no se puede buscar en ninguna fuente: se omite.
+		var cid sql.NullString
+		if err := rows.Scan(&cid); err != nil {
 			return nil, err
 		}
-		circuits = append(circuits, c)
+		if nullString(cid) == "" {
+			skipped++
+			continue
+		}
+		circuits = append(circuits, core.Circuit{CID: cid.String})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		log.Printf("[WARN] %d filas con %s NULL o vacío se omitieron", skipped, r.schema.CID)
 	}
 	return circuits, nil
 }
 
+// maxINParams limita la cantidad de CIDs por cláusula IN (...).
+// MySQL admite hasta 65535 placeholders por statement; nos quedamos lejos de ese límite.
+const maxINParams = 1000
+
+// FetchCurrentValues: Obtiene los valores enriquecibles guardados para los CIDs dados.
+// Se hace una consulta por cada bloque de hasta maxINParams CIDs.
+func (r *PostgresRepo) FetchCurrentValues(ctx context.Context, cids []string) (map[string]core.EnrichedData, error) {
+	current := make(map[string]core.EnrichedData, len(cids))
+	for start := 0; start < len(cids); start += maxINParams {
+		end := min(start+maxINParams, len(cids))
+		if err := r.fetchCurrentChunk(ctx, cids[start:end], current); err != nil {
+			return nil, err
+		}
+	}
+	return current, nil
+}
+
+// fetchCurrentChunk consulta un bloque de CIDs y agrega los resultados a current
+func (r *PostgresRepo) fetchCurrentChunk(ctx context.Context, cids []string, current map[string]core.EnrichedData) error {
+	placeholders := make([]string, len(cids))
+	args := make([]interface{}, len(cids))
+	for i, cid := range cids {
+		placeholders[i] = "?"
+		args[i] = cid
+	}
+
+	s := r.schema
+	query := "SELECT " + quote(s.CID) + ", " + quote(s.RxPower) + ", " + quote(s.StatusGpon) + ", " +
+		quote(s.PPPoEUsername) + ", " + quote(s.PPPoEPassword) + " " +
+		"FROM " + quote(s.Table) + " WHERE " + quote(s.CID) + " IN (" + strings.Join(placeholders, ", ") + ")"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		// Las columnas pueden ser NULL en circuitos que nunca se sincronizaron (y RxPower puede ser numérica)
+		var cid, rx, status, user, pass sql.NullString
+		if err := rows.Scan(&cid, &rx, &status, &user, &pass); err != nil {
+			return err
+		}
+		current[cid.String] = core.EnrichedData{
+			CircuitID:     cid.String,
+			RxPower:       nullString(rx),
+			StatusGpon:    nullString(status),
+			PPPoEUsername: nullString(user),
+			PPPoEPassword: nullString(pass),
+		}
+	}
+	return rows.Err()
+}
+
+// nullString convierte NULL en "" (para comparar, NULL y vacío son lo mismo)
+func nullString(s sql.NullString) string {
+	if !s.Valid {
+		return ""
+	}
+	return s.String
+}
+
 // UpdateCircuitBatch: Actualiza un batch de circuitos en la base de datos
-func (r *PostgresRepo) UpdateCircuitBatch(data []core.EnrichedData) error {
+// Se envía un único UPDATE ... CASE por batch en lugar de un Exec por fila,
+// reduciendo los round trips a la DB de N a 1.
+// Los CIDs que no tienen fila en la tabla se devuelven en BatchResult.Unmatched
+// (o se insertan si INSERT_MISSING está activo).
+func (r *PostgresRepo) UpdateCircuitBatch(ctx context.Context, data []core.EnrichedData) (core.BatchResult, error) {
 	if len(data) == 0 {
-		return nil
+		return core.BatchResult{}, nil
 	}
 
-	// Implementación básica. Para producción masiva, usar COPY o transacciones por bloques.
-	tx, err := r.db.Begin()
-	if err != nil {
+	rows := dedupeLast(data)
+	query, args := buildBatchUpdate(r.schema, rows, r.columns)
+
+	var result core.BatchResult
+	err := r.withRetry(ctx, func() error {
+		var err error
+		result, err = r.updateBatch(ctx, query, args, rows)
 		return err
+	})
+	if err != nil {
+		return core.BatchResult{}, fmt.Errorf("error actualizando batch de %d circuitos: %w", len(data), err)
 	}
 
-	// Actualización de todos los campos según el flujo de trabajo
-	// MySQL usa backticks para nombres de columnas y ? para parámetros
-	// Nota: VLAN se ignora, no se actualiza
-	stmt, err := tx.Prepare(
-		"UPDATE circuitos " +
-		"SET `RxPower`=?, `StatusGpon`=?, `PPPoEUsername`=?, `PPPoEPassword`=? " +
-		"WHERE `CID`=?")
+	if len(result.Unmatched) > 0 {
+		log.Printf("[WARN] %d circuitos no tienen fila en la DB (no se guardaron): %s",
+			len(result.Unmatched), strings.Join(result.Unmatched, ", "))
+	}
+	if result.Inserted > 0 {
+		log.Printf("➕ Se insertaron %d circuitos que no existían en la DB", result.Inserted)
+	}
+	return result, nil
+}
+
+// updateBatch ejecuta el UPDATE y detecta los CIDs sin fila; con insertMissing los inserta
+// en la misma transacción. Es idempotente: si se reintenta, los CIDs ya insertados se actualizan.
+func (r *PostgresRepo) updateBatch(ctx context.Context, query string, args []interface{}, rows []core.EnrichedData) (core.BatchResult, error) {
+	var result core.BatchResult
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return result, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return result, err
+	}
+
+	// No se usa RowsAffected: MySQL cuenta filas modificadas, no encontradas,
+	// y un circuito sin cambios se confundiría con uno inexistente
+	missing, err := missingRows(ctx, tx, r.schema, rows)
+	if err != nil {
+		return result, err
+	}
+	result.Updated = len(rows) - len(missing)
+
+	if len(missing) > 0 {
+		if r.insertMissing {
+			insert, insertArgs := buildBatchInsert(r.schema, missing, r.columns, r.insertExtra)
+			if _, err := tx.ExecContext(ctx, insert, insertArgs...); err != nil {
+				return result, fmt.Errorf("error insertando %d circuitos nuevos: %w", len(missing), err)
+			}
+			result.Inserted = len(missing)
+		} else {
+			for _, d := range missing {
+				result.Unmatched = append(result.Unmatched, d.CircuitID)
+			}
+		}
+	}
+	return result, tx.Commit()
+}
+
+// missingRows devuelve las filas del batch cuyo CID no existe en circuitos
+func missingRows(ctx context.Context, tx *sql.Tx, schema Schema, rows []core.EnrichedData) ([]core.EnrichedData, error) {
+	placeholders := make([]string, len(rows))
+	args := make([]interface{}, len(rows))
+	for i, d := range rows {
+		placeholders[i] = "?"
+		args[i] = d.CircuitID
+	}
+
+	found, err := tx.QueryContext(ctx, "SELECT "+quote(schema.CID)+" FROM "+quote(schema.Table)+
+		" WHERE "+quote(schema.CID)+" IN ("+strings.Join(placeholders, ", ")+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer found.Close()
+
+	// La collation de MySQL compara sin distinguir mayúsculas: "abc" ya existe si la tabla tiene "ABC"
+	existing := make(map[string]bool, len(rows))
+	for found.Next() {
+		var cid string
+		if err := found.Scan(&cid); err != nil {
+			return nil, err
+		}
+		existing[strings.ToUpper(cid)] = true
+	}
+	if err := found.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []core.EnrichedData
+	for _, d := range rows {
+		if !existing[strings.ToUpper(d.CircuitID)] {
+			missing = append(missing, d)
+		}
+	}
+	return missing, nil
+}
+
+// Códigos de error de MySQL que vale la pena reintentar
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+)
+
+// withRetry ejecuta fn y la reintenta con backoff exponencial (200ms, 400ms, 800ms...)
+// solo si el error es transitorio. Errores de SQL o de constraints fallan de inmediato.
+// Si ctx se cancela se deja de reintentar.
+func (r *PostgresRepo) withRetry(ctx context.Context, fn func() error) error {
+	baseDelay := 200 * time.Millisecond
+
+	err := fn()
+	// Con el contexto vencido no hay reintento posible (context.DeadlineExceeded además parece un timeout de red)
+	for attempt := 0; attempt < r.writeRetries && ctx.Err() == nil && isRetryable(err); attempt++ {
+		delay := baseDelay * time.Duration(1<<uint(attempt))
+		log.Printf("[WARN] Error transitorio en la DB (%v), reintento %d/%d en %s", err, attempt+1, r.writeRetries, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
+
+// isRetryable indica si un error de la DB es transitorio (deadlock, lock wait timeout, conexión caída)
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
 	}
-	defer stmt.Close()
 
-	for _, d := range data {
-		_, err := stmt.Exec(d.RxPower, d.StatusGpon, d.PPPoEUsername, d.PPPoEPassword, d.CircuitID)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("error actualizando circuito %s: %v", d.CircuitID, err)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// batchColumns son todas las columnas enriquecidas que se pueden actualizar
+// Nota: VLAN se ignora, no se actualiza
+var batchColumns = []string{"RxPower", "StatusGpon", "PPPoEUsername", "PPPoEPassword"}
+
+// batchValues devuelve los valores de d para las columnas dadas, en el mismo orden
+func batchValues(d core.EnrichedData, columns []string) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, name := range columns {
+		switch name {
+		case "RxPower":
+			values[i] = d.RxPower
+		case "StatusGpon":
+			values[i] = d.StatusGpon
+		case "PPPoEUsername":
+			values[i] = d.PPPoEUsername
+		case "PPPoEPassword":
+			values[i] = d.PPPoEPassword
+		}
+	}
+	return values
+}
+
+// dedupeLast deja una fila por CID. Si un CID aparece repetido en el batch,
+// gana la última ocurrencia (igual que con un UPDATE por fila).
+func dedupeLast(data []core.EnrichedData) []core.EnrichedData {
+	lastIdx := make(map[string]int, len(data))
+	for i, d := range data {
+		lastIdx[d.CircuitID] = i
+	}
+	rows := make([]core.EnrichedData, 0, len(lastIdx))
+	for i, d := range data {
+		if lastIdx[d.CircuitID] == i {
+			rows = append(rows, d)
+		}
+	}
+	return rows
+}
+
+// buildBatchUpdate arma un UPDATE multi-fila de la forma:
+//
+//	UPDATE circuitos SET `RxPower` = CASE `CID` WHEN ? THEN ? ... END, ... WHERE `CID` IN (?, ...)
+//
+// rows no debe tener CIDs repetidos (ver dedupeLast).
+func buildBatchUpdate(schema Schema, rows []core.EnrichedData, columns []string) (string, []interface{}) {
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(rows)*(2*len(columns)+1))
+
+	// MySQL usa backticks para nombres de columnas y ? para parámetros
+	sb.WriteString("UPDATE " + quote(schema.Table) + " SET ")
+	for col, name := range columns {
+		if col > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quote(schema.column(name)) + " = CASE " + quote(schema.CID))
+		for _, d := range rows {
+			// Una lectura descartada o que Zabbix no devolvió conserva el valor actual de la columna
+			if d.KeepsCurrent(name) {
+				sb.WriteString(" WHEN ? THEN " + quote(schema.column(name)))
+				args = append(args, d.CircuitID)
+				continue
+			}
+			sb.WriteString(" WHEN ? THEN ?")
+			args = append(args, d.CircuitID, batchValues(d, columns)[col])
 		}
+		sb.WriteString(" END")
 	}
-	return tx.Commit()
+
+	sb.WriteString(" WHERE " + quote(schema.CID) + " IN (")
+	for i, d := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("?")
+		args = append(args, d.CircuitID)
+	}
+	sb.WriteString(")")
+
+	return sb.String(), args
+}
+
+// buildBatchInsert arma un INSERT multi-fila con el CID, las columnas enriquecidas
+// y las columnas adicionales configuradas (en orden alfabético, con el mismo valor para todas las filas)
+func buildBatchInsert(schema Schema, rows []core.EnrichedData, columns []string, extra map[string]string) (string, []interface{}) {
+	extraCols := make([]string, 0, len(extra))
+	for col := range extra {
+		extraCols = append(extraCols, col)
+	}
+	sort.Strings(extraCols)
+
+	cols := []string{schema.CID}
+	for _, name := range columns {
+		cols = append(cols, schema.column(name))
+	}
+	cols = append(cols, extraCols...)
+
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(rows)*len(cols))
+
+	sb.WriteString("INSERT INTO " + quote(schema.Table) + " (")
+	for i, name := range cols {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quote(name))
+	}
+	sb.WriteString(") VALUES ")
+
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ") + ")"
+	for i, d := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(row)
+		args = append(args, d.CircuitID)
+		args = append(args, batchValues(d, columns)...)
+		for _, col := range extraCols {
+			args = append(args, extra[col])
+		}
+	}
+
+	return sb.String(), args
 }
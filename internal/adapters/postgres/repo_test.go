@@ -0,0 +1,399 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"gpon-sync/internal/core"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func batchOf(n int) []core.EnrichedData {
+	data := make([]core.EnrichedData, n)
+	for i := range data {
+		cid := fmt.Sprintf("%d", 1000+i)
+		data[i] = core.EnrichedData{CircuitID: cid, RxPower: "-20.1 dBm", StatusGpon: "1", PPPoEUsername: "u" + cid, PPPoEPassword: "p"}
+	}
+	return data
+}
+
+func existingRows(data []core.EnrichedData) map[string][]string {
+	rows := make(map[string][]string, len(data))
+	for _, d := range data {
+		rows[d.CircuitID] = []string{"", "", "", ""}
+	}
+	return rows
+}
+
+func TestUpdateCircuitBatchSingleStatement(t *testing.T) {
+	data := batchOf(100)
+	// Una lectura descartada conserva el valor actual de la columna
+	data[1].RxPowerDiscarded = true
+	fake, db := newFakeDB(existingRows(data))
+	repo := NewPostgresRepoFromDB(db, Options{})
+
+	if _, err := repo.UpdateCircuitBatch(context.Background(), data); err != nil {
+		t.Fatalf("UpdateCircuitBatch: %v", err)
+	}
+
+	updates := fake.executed("UPDATE")
+	if len(updates) != 1 {
+		t.Fatalf("se ejecutaron %d UPDATE, se esperaba uno por batch", len(updates))
+	}
+	query := updates[0].query
+	if !strings.HasPrefix(query, "UPDATE `circuitos` SET `RxPower` = CASE `CID` WHEN ? THEN ?") {
+		t.Errorf("query inesperada: %.120s...", query)
+	}
+	if !strings.Contains(query, "WHEN ? THEN `RxPower`") {
+		t.Error("la lectura descartada debería conservar el valor actual de RxPower")
+	}
+	// CASE por columna (CID + valor, salvo la lectura descartada) + la lista del IN
+	if want := 4*2*100 - 1 + 100; len(updates[0].args) != want {
+		t.Errorf("args = %d, se esperaban %d", len(updates[0].args), want)
+	}
+}
+
+func TestUpdateCircuitBatchKeepsUnresolvedFields(t *testing.T) {
+	data := batchOf(2)
+	// 1000: Zabbix devolvió solo el status; 1001: solo el rx power
+	data[0].RxPower, data[0].RxPowerUnresolved = "", true
+	data[1].StatusGpon, data[1].StatusUnresolved = "", true
+	fake, db := newFakeDB(existingRows(data))
+	repo := NewPostgresRepoFromDB(db, Options{})
+
+	if _, err := repo.UpdateCircuitBatch(context.Background(), data); err != nil {
+		t.Fatalf("UpdateCircuitBatch: %v", err)
+	}
+	update := fake.executed("UPDATE")[0]
+	for _, column := range []string{"`RxPower`", "`StatusGpon`"} {
+		if n := strings.Count(update.query, "WHEN ? THEN "+column); n != 1 {
+			t.Errorf("%s: %d filas conservan el valor actual, se esperaba 1", column, n)
+		}
+	}
+	// Ningún valor vacío llega a la DB
+	for _, arg := range update.args {
+		if arg == "" {
+			t.Errorf("se escribió un valor vacío: %v", update.args)
+			break
+		}
+	}
+}
+
+func TestUpdateCircuitBatchDedupesCIDs(t *testing.T) {
+	data := batchOf(2)
+	last := data[0]
+	last.RxPower = "-25.0 dBm"
+	data = append(data, last)
+	fake, db := newFakeDB(existingRows(data))
+	repo := NewPostgresRepoFromDB(db, Options{Columns: []string{"RxPower"}})
+
+	if _, err := repo.UpdateCircuitBatch(context.Background(), data); err != nil {
+		t.Fatalf("UpdateCircuitBatch: %v", err)
+	}
+	args := fake.executed("UPDATE")[0].args
+	// WHEN 1001 THEN -20.1, WHEN 1000 THEN -25.0 (en la posición de la última ocurrencia), IN (1001, 1000)
+	if len(args) != 6 || args[2] != "1000" || args[3] != "-25.0 dBm" {
+		t.Errorf("args = %v, se esperaba la última ocurrencia de 1000", args)
+	}
+}
+
+// updatePerRow es la escritura original: un Exec por fila dentro de una transacción
+func updatePerRow(ctx context.Context, r *PostgresRepo, data []core.EnrichedData) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, "UPDATE circuitos SET RxPower = ?, StatusGpon = ?, PPPoEUsername = ?, PPPoEPassword = ? WHERE CID = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, d := range data {
+		if _, err := stmt.ExecContext(ctx, d.RxPower, d.StatusGpon, d.PPPoEUsername, d.PPPoEPassword, d.CircuitID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Con 100 circuitos por batch y 200µs de round trip simulado (el sleep real ronda 1.2ms):
+//
+//	BenchmarkUpdatePerRow         	      10	 120.9 ms/op   (100 statements)
+//	BenchmarkUpdateCircuitBatch   	     428	   2.9 ms/op   (UPDATE + SELECT de los CIDs sin fila)
+const benchLatency = 200 * time.Microsecond
+
+func BenchmarkUpdatePerRow(b *testing.B) {
+	data := batchOf(100)
+	fake, db := newFakeDB(existingRows(data))
+	fake.latency = benchLatency
+	repo := NewPostgresRepoFromDB(db, Options{})
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := updatePerRow(ctx, repo, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdateCircuitBatch(b *testing.B) {
+	data := batchOf(100)
+	fake, db := newFakeDB(existingRows(data))
+	fake.latency = benchLatency
+	repo := NewPostgresRepoFromDB(db, Options{})
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.UpdateCircuitBatch(ctx, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestUpdateCircuitBatchReportsUnmatchedCIDs(t *testing.T) {
+	data := batchOf(4)
+	rows := existingRows(data)
+	delete(rows, "1001")
+	delete(rows, "1003")
+	// La tabla guarda el CID en mayúsculas: la collation de MySQL lo encuentra igual
+	data[2].CircuitID = "abc-1002"
+	delete(rows, "1002")
+	rows["ABC-1002"] = []string{"", "", "", ""}
+	_, db := newFakeDB(rows)
+	repo := NewPostgresRepoFromDB(db, Options{})
+
+	result, err := repo.UpdateCircuitBatch(context.Background(), data)
+	if err != nil {
+		t.Fatalf("UpdateCircuitBatch: %v", err)
+	}
+	if result.Updated != 2 || result.Inserted != 0 {
+		t.Errorf("actualizados/insertados = %d/%d, se esperaba 2/0", result.Updated, result.Inserted)
+	}
+	if strings.Join(result.Unmatched, ",") != "1001,1003" {
+		t.Errorf("sin fila = %v, se esperaba [1001 1003]", result.Unmatched)
+	}
+}
+
+func TestUpdateCircuitBatchInsertsMissing(t *testing.T) {
+	data := batchOf(2)
+	rows := existingRows(data)
+	delete(rows, "1001")
+	fake, db := newFakeDB(rows)
+	repo := NewPostgresRepoFromDB(db, Options{InsertMissing: true})
+
+	result, err := repo.UpdateCircuitBatch(context.Background(), data)
+	if err != nil {
+		t.Fatalf("UpdateCircuitBatch: %v", err)
+	}
+	if result.Updated != 1 || result.Inserted != 1 || len(result.Unmatched) != 0 {
+		t.Errorf("resultado = %+v, se esperaba 1 actualizado y 1 insertado", result)
+	}
+	if inserts := fake.executed("INSERT"); len(inserts) != 1 {
+		t.Errorf("se ejecutaron %d INSERT, se esperaba 1", len(inserts))
+	}
+}
+
+func TestFetchCurrentValuesChunksAndMapsByCID(t *testing.T) {
+	cids := make([]string, 2500)
+	rows := make(map[string][]string)
+	for i := range cids {
+		cids[i] = fmt.Sprintf("%d", 10000+i)
+	}
+	// Solo algunos CIDs tienen fila
+	rows["10000"] = []string{"-20.1 dBm", "1", "user10000", "pass10000"}
+	rows["11500"] = []string{"-22.0 dBm", "2", "user11500", "pass11500"}
+	rows["12499"] = []string{"", "", "", ""}
+	fake, db := newFakeDB(rows)
+	repo := NewPostgresRepoFromDB(db, Options{})
+
+	current, err := repo.FetchCurrentValues(context.Background(), cids)
+	if err != nil {
+		t.Fatalf("FetchCurrentValues: %v", err)
+	}
+
+	selects := fake.executed("SELECT")
+	if len(selects) != 3 {
+		t.Fatalf("se ejecutaron %d SELECT, se esperaban 3 bloques de hasta %d", len(selects), maxINParams)
+	}
+	for i, want := range []int{1000, 1000, 500} {
+		if len(selects[i].args) != want {
+			t.Errorf("bloque %d: %d CIDs, se esperaban %d", i, len(selects[i].args), want)
+		}
+	}
+	if !strings.HasPrefix(selects[0].query, "SELECT `CID`, `RxPower`, `StatusGpon`, `PPPoEUsername`, `PPPoEPassword` FROM `circuitos` WHERE `CID` IN (?, ?") {
+		t.Errorf("query inesperada: %.120s...", selects[0].query)
+	}
+
+	if len(current) != 3 {
+		t.Errorf("se obtuvieron %d circuitos, se esperaban 3", len(current))
+	}
+	if got := current["11500"]; got.CircuitID != "11500" || got.RxPower != "-22.0 dBm" || got.StatusGpon != "2" ||
+		got.PPPoEUsername != "user11500" || got.PPPoEPassword != "pass11500" {
+		t.Errorf("CID 11500 = %+v", got)
+	}
+	if _, ok := current["10001"]; ok {
+		t.Error("un CID sin fila no debería aparecer en el resultado")
+	}
+}
+
+func TestFetchCurrentValuesWithoutCIDs(t *testing.T) {
+	fake, db := newFakeDB(nil)
+	repo := NewPostgresRepoFromDB(db, Options{})
+
+	current, err := repo.FetchCurrentValues(context.Background(), nil)
+	if err != nil || len(current) != 0 {
+		t.Fatalf("FetchCurrentValues(nil) = %v, %v", current, err)
+	}
+	if n := len(fake.executed("SELECT")); n != 0 {
+		t.Errorf("sin CIDs no debería consultarse la DB (se ejecutaron %d SELECT)", n)
+	}
+}
+
+func TestNewPostgresRepoFromDBFetchesCircuits(t *testing.T) {
+	fake, db := newFakeDB(map[string][]string{"157591": nil, "1575": nil})
+	repo := NewPostgresRepoFromDB(db, Options{Schema: Schema{Table: "inventario", CID: "circuit_id"}})
+
+	circuits, err := repo.FetchPendingCircuits(context.Background())
+	if err != nil {
+		t.Fatalf("FetchPendingCircuits: %v", err)
+	}
+	if len(circuits) != 2 || circuits[0].CID != "1575" || circuits[1].CID != "157591" {
+		t.Errorf("circuitos = %+v", circuits)
+	}
+	if q := fake.executed("SELECT")[0].query; q != "SELECT `circuit_id` FROM `inventario`" {
+		t.Errorf("query = %q, se esperaba la tabla y columna configuradas", q)
+	}
+}
+
+func TestNewPostgresRepoFailsWithoutDatabase(t *testing.T) {
+	if _, err := NewPostgresRepo("sin-formato-de-dsn", Options{}); err == nil {
+		t.Error("se esperaba error con un DSN inválido")
+	}
+	// Nada escucha en el puerto 1: el ping inicial falla en lugar de devolver un repositorio inservible
+	start := time.Now()
+	_, err := NewPostgresRepo("u:p@tcp(127.0.0.1:1)/db?timeout=1s", Options{PingTimeout: 2 * time.Second})
+	if err == nil {
+		t.Fatal("se esperaba error con una DB inalcanzable")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("el ping tardó %s, se esperaba que respete PingTimeout", elapsed)
+	}
+}
+
+func TestCancelledContextAbortsLongQuery(t *testing.T) {
+	fake, db := newFakeDB(existingRows(batchOf(3)))
+	// Una DB colgada: cada statement tarda más que el test
+	fake.latency = time.Minute
+	repo := NewPostgresRepoFromDB(db, Options{WriteRetries: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := repo.FetchPendingCircuits(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FetchPendingCircuits: err = %v, se esperaba context.DeadlineExceeded", err)
+	}
+	// La escritura tampoco se reintenta con el contexto vencido
+	if _, err := repo.UpdateCircuitBatch(ctx, batchOf(3)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("UpdateCircuitBatch: err = %v, se esperaba context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("las consultas tardaron %s en abortar", elapsed)
+	}
+}
+
+func TestScanHandlesNULLColumns(t *testing.T) {
+	_, db := newFakeDB(map[string][]string{
+		"100":   {sqlNull, sqlNull, sqlNull, sqlNull}, // Nunca sincronizado
+		"200":   {"-20.1 dBm", sqlNull, "user200", sqlNull},
+		"":      {sqlNull, sqlNull, sqlNull, sqlNull}, // CID vacío
+		sqlNull: {sqlNull, sqlNull, sqlNull, sqlNull}, // CID NULL
+	})
+	repo := NewPostgresRepoFromDB(db, Options{})
+
+	circuits, err := repo.FetchPendingCircuits(context.Background())
+	if err != nil {
+		t.Fatalf("FetchPendingCircuits: %v", err)
+	}
+	if len(circuits) != 2 || circuits[0].CID != "100" || circuits[1].CID != "200" {
+		t.Errorf("circuitos = %+v, se esperaba omitir los CIDs NULL o vacíos", circuits)
+	}
+
+	current, err := repo.FetchCurrentValues(context.Background(), []string{"100", "200"})
+	if err != nil {
+		t.Fatalf("FetchCurrentValues: %v", err)
+	}
+	if got := current["100"]; got.CircuitID != "100" || got.RxPower != "" || got.StatusGpon != "" || got.PPPoEUsername != "" || got.PPPoEPassword != "" {
+		t.Errorf("CID 100 = %+v, se esperaban columnas vacías", got)
+	}
+	if got := current["200"]; got.RxPower != "-20.1 dBm" || got.StatusGpon != "" || got.PPPoEUsername != "user200" || got.PPPoEPassword != "" {
+		t.Errorf("CID 200 = %+v", got)
+	}
+}
+
+func TestUpdateCircuitBatchRetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantErr     bool
+		wantUpdates int
+		wantCommits int
+	}{
+		{"deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, false, 2, 1},
+		{"lock wait timeout", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, false, 2, 1},
+		{"conexión caída", driver.ErrBadConn, false, 2, 1},
+		// Un error de constraint no se resuelve reintentando
+		{"clave duplicada", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, true, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := batchOf(3)
+			fake, db := newFakeDB(existingRows(data))
+			fake.execErr = tt.err
+			repo := NewPostgresRepoFromDB(db, Options{WriteRetries: 3})
+
+			result, err := repo.UpdateCircuitBatch(context.Background(), data)
+			if tt.wantErr {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, se esperaba %v", err, tt.err)
+				}
+			} else if err != nil || result.Updated != len(data) {
+				t.Fatalf("result = %+v, error = %v; se esperaba el batch completo", result, err)
+			}
+			if got := len(fake.executed("UPDATE")); got != tt.wantUpdates {
+				t.Errorf("UPDATE ejecutados = %d, se esperaban %d", got, tt.wantUpdates)
+			}
+			if got := fake.committed(); got != tt.wantCommits {
+				t.Errorf("commits = %d, se esperaban %d", got, tt.wantCommits)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&mysql.MySQLError{Number: 1213}, true},
+		{fmt.Errorf("batch: %w", &mysql.MySQLError{Number: 1205}), true},
+		{&mysql.MySQLError{Number: 1062}, false},
+		{&mysql.MySQLError{Number: 1054}, false},
+		{driver.ErrBadConn, true},
+		{mysql.ErrInvalidConn, true},
+		{&net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, true},
+		{errors.New("sintaxis inválida"), false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("isRetryable(%v) = %v, se esperaba %v", tt.err, got, tt.want)
+		}
+	}
+}
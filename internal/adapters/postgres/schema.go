@@ -0,0 +1,64 @@
+package postgres
+
+import "strings"
+
+// Schema son los nombres de la tabla y las columnas de circuitos en la DB.
+// Se validan como identificadores simples al cargar la configuración y además se citan con backticks.
+type Schema struct {
+	Table         string
+	CID           string
+	RxPower       string
+	StatusGpon    string
+	PPPoEUsername string
+	PPPoEPassword string
+}
+
+// DefaultSchema es el esquema original (tabla circuitos)
+func DefaultSchema() Schema {
+	return Schema{
+		Table:         "circuitos",
+		CID:           "CID",
+		RxPower:       "RxPower",
+		StatusGpon:    "StatusGpon",
+		PPPoEUsername: "PPPoEUsername",
+		PPPoEPassword: "PPPoEPassword",
+	}
+}
+
+// withDefaults completa los nombres vacíos con los del esquema original
+func (s Schema) withDefaults() Schema {
+	def := DefaultSchema()
+	for _, f := range []struct{ v, d *string }{
+		{&s.Table, &def.Table},
+		{&s.CID, &def.CID},
+		{&s.RxPower, &def.RxPower},
+		{&s.StatusGpon, &def.StatusGpon},
+		{&s.PPPoEUsername, &def.PPPoEUsername},
+		{&s.PPPoEPassword, &def.PPPoEPassword},
+	} {
+		if *f.v == "" {
+			*f.v = *f.d
+		}
+	}
+	return s
+}
+
+// column devuelve el nombre real de una de las batchColumns
+func (s Schema) column(field string) string {
+	switch field {
+	case "RxPower":
+		return s.RxPower
+	case "StatusGpon":
+		return s.StatusGpon
+	case "PPPoEUsername":
+		return s.PPPoEUsername
+	case "PPPoEPassword":
+		return s.PPPoEPassword
+	}
+	return field
+}
+
+// quote cita un identificador de MySQL con backticks (los backticks internos se duplican)
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -1,132 +1,298 @@
 package ubersmith
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"gpon-sync/internal/core"
+	"gpon-sync/internal/ratelimit"
 	"io"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type UbersmithAdapter struct {
 	baseURL string
 	user    string
 	pass    string
+	client  *http.Client
+	limiter *ratelimit.Limiter // Limita las requests por segundo hacia Ubersmith (nil = sin límite)
+	opts    Options
+
+	// Variables de custom fields detectadas (detección aproximada), compartidas por todos los workers
+	fields *fieldCache
+}
+
+// Campos que se pueden exigir en Options.RequiredFields
+const (
+	FieldUser = "user"
+	FieldPass = "pass"
+)
+
+// Options contiene los ajustes opcionales del adaptador
+type Options struct {
+	// Campos que deben encontrarse para dar la búsqueda por terminada (FieldUser, FieldPass).
+	// Apenas están todos, se omiten las estrategias y nombres de fallback restantes.
+	// Si está vacío se exigen ambos.
+	RequiredFields []string
+
+	// Nombres de custom fields donde buscar usuario y contraseña PPPoE, en orden.
+	// Si están vacíos se usan DefaultUserFields / DefaultPassFields.
+	UserFields []string
+	PassFields []string
+
+	// Nombres de custom fields donde buscar la VLAN, en orden (vacío = Ubersmith no aporta VLAN)
+	VLANFields []string
+
+	// Habilita la detección aproximada de custom fields por substrings del nombre
+	// (ej: cualquier variable que contenga "user"). Puede capturar campos equivocados: por defecto está apagada.
+	HeuristicMatch bool
+
+	// Métodos de la API que se prueban para obtener las credenciales, en orden.
+	// Si está vacío se usa DefaultMethods.
+	Methods []Method
+
+	// Vigencia de la definición de custom fields en cache (0 = DefaultFieldCacheTTL)
+	FieldCacheTTL time.Duration
+}
+
+// Métodos de la API de Ubersmith con los que se buscan las credenciales PPPoE
+const (
+	MethodCustomFields = "uber.metadata_bulk_get" // Custom fields del servicio
+	MethodServiceGet   = "client.service_get"     // Campos directos del servicio (username, password)
+)
+
+// Method es un método de la API a probar y el parámetro que usa.
+// Para client.service_get, Param es el nombre del parámetro con el CID (por defecto service_id);
+// para uber.metadata_bulk_get, es el meta_type de los custom fields (por defecto pack).
+type Method struct {
+	Name  string
+	Param string
+}
+
+// DefaultMethods es el orden histórico: custom fields primero y luego los campos directos del servicio
+var DefaultMethods = []Method{
+	{Name: MethodCustomFields, Param: "pack"},
+	{Name: MethodServiceGet, Param: "service_id"},
+}
+
+// ParseMethods convierte una lista "método[:parámetro]" (ej: "client.service_get:service_id")
+// en métodos. Sin parámetro se usa el de DefaultMethods.
+func ParseMethods(specs []string) []Method {
+	methods := make([]Method, 0, len(specs))
+	for _, spec := range specs {
+		name, param, _ := strings.Cut(spec, ":")
+		m := Method{Name: strings.TrimSpace(name), Param: strings.TrimSpace(param)}
+		if m.Param == "" {
+			for _, d := range DefaultMethods {
+				if d.Name == m.Name {
+					m.Param = d.Param
+				}
+			}
+		}
+		methods = append(methods, m)
+	}
+	return methods
 }
 
-func NewUbersmithAdapter(baseURL, user, pass string) *UbersmithAdapter {
+// Nombres de custom fields conocidos para las credenciales PPPoE
+var (
+	DefaultUserFields = []string{
+		"username", "user", "pppoe_user", "pppoe_username",
+		"pppo_user", "pppo_username", "ppp_username", "ppp_user",
+	}
+	DefaultPassFields = []string{
+		"password", "pass", "pppoe_password", "pppoe_pass",
+		"pppo_password", "pppo_pass", "ppp_password", "ppp_pass",
+	}
+)
+
+// ErrFieldsNotIdentifiable indica que ningún campo del servicio coincide por nombre con usuario o contraseña PPPoE
+var ErrFieldsNotIdentifiable = errors.New("no se pudieron identificar los campos PPPoE del servicio por nombre")
+
+// NewUbersmithAdapter crea el adaptador. client viene de httpclient.New (timeout, TLS, proxy).
+func NewUbersmithAdapter(baseURL, user, pass string, client *http.Client, limiter *ratelimit.Limiter, opts Options) *UbersmithAdapter {
+	if len(opts.RequiredFields) == 0 {
+		opts.RequiredFields = []string{FieldUser, FieldPass}
+	}
+	if len(opts.UserFields) == 0 {
+		opts.UserFields = DefaultUserFields
+	}
+	if len(opts.PassFields) == 0 {
+		opts.PassFields = DefaultPassFields
+	}
+	if len(opts.Methods) == 0 {
+		opts.Methods = DefaultMethods
+	}
+	if opts.FieldCacheTTL <= 0 {
+		opts.FieldCacheTTL = DefaultFieldCacheTTL
+	}
 	return &UbersmithAdapter{
 		baseURL: baseURL,
 		user:    user,
 		pass:    pass,
+		client:  client,
+		limiter: limiter,
+		opts:    opts,
+		fields:  newFieldCache(opts.FieldCacheTTL),
 	}
 }
 
-// GetServiceDetails busca credenciales PPPoE por CID (Service ID en Ubersmith)
-func (u *UbersmithAdapter) GetServiceDetails(cid string) (user, pass string, err error) {
-	// ESTRATEGIA 1: Custom Fields (pack meta_type)
-	user, pass, _ = u.getServiceCustomFields(cid)
-
-	// ESTRATEGIA 2: Obtener datos completos del servicio para buscar en campos directos
-	serviceData, err := u.getServiceData(cid)
-	if err != nil {
-		// Si falla pero tenemos datos de custom fields, los retornamos
-		if user != "" || pass != "" {
-			return user, pass, nil
+// done indica si ya se encontraron todos los campos requeridos
+func (u *UbersmithAdapter) done(user, pass string) bool {
+	for _, field := range u.opts.RequiredFields {
+		switch field {
+		case FieldUser:
+			if user == "" {
+				return false
+			}
+		case FieldPass:
+			if pass == "" {
+				return false
+			}
 		}
-		return "", "", err
 	}
+	return true
+}
+
+// GetServiceDetails busca credenciales PPPoE por CID (Service ID en Ubersmith).
+// Prueba los métodos configurados en orden y se detiene apenas están los campos requeridos.
+func (u *UbersmithAdapter) GetServiceDetails(ctx context.Context, cid string) (user, pass string, err error) {
+	var lastErr error
+	for _, method := range u.opts.Methods {
+		switch method.Name {
+		case MethodCustomFields:
+			// Custom Fields (por defecto meta_type pack)
+			cfUser, cfPass, err := u.getServiceCustomFields(ctx, cid, method.Param)
+			user, pass = firstNonEmpty(user, cfUser), firstNonEmpty(pass, cfPass)
+			if errors.Is(err, core.ErrUbersmithAuth) {
+				// Con credenciales inválidas los demás métodos fallarían igual
+				return "", "", err
+			}
+			if err != nil {
+				lastErr = err
+				continue
+			}
 
-	// Buscar username y password en campos directos del servicio
-	if user == "" {
-		if usernameVal, ok := serviceData["username"].(string); ok && usernameVal != "" {
-			user = usernameVal
+		case MethodServiceGet:
+			// Datos completos del servicio para buscar en campos directos
+			serviceData, err := u.getServiceData(ctx, cid, method.Param)
+			if errors.Is(err, core.ErrUbersmithAuth) {
+				return "", "", err
+			}
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if usernameVal, ok := serviceData["username"].(string); ok {
+				user = firstNonEmpty(user, usernameVal)
+			}
+			if passwordVal, ok := serviceData["password"].(string); ok {
+				pass = firstNonEmpty(pass, passwordVal)
+			}
 		}
-	}
-	if pass == "" {
-		if passwordVal, ok := serviceData["password"].(string); ok && passwordVal != "" {
-			pass = passwordVal
+
+		if u.done(user, pass) {
+			return user, pass, nil
 		}
 	}
 
+	// Si algún método falló y ningún otro encontró datos, se reporta el error
+	if user == "" && pass == "" && lastErr != nil {
+		return "", "", lastErr
+	}
+	if user == "" && pass == "" && !u.opts.HeuristicMatch {
+		return "", "", fmt.Errorf("%w (CID %s; revisar UBERSMITH_USER_FIELDS / UBERSMITH_PASS_FIELDS)", ErrFieldsNotIdentifiable, cid)
+	}
 	return user, pass, nil
 }
 
-// getServiceData obtiene los datos completos del servicio usando client.service_get
-func (u *UbersmithAdapter) getServiceData(serviceID string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s?method=client.service_get&service_id=%s", u.baseURL, serviceID)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.SetBasicAuth(u.user, u.pass)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+// GetVLAN busca la VLAN del servicio en los custom fields configurados (ver Options.VLANFields).
+// Retorna "" si no hay campos configurados o ninguno tiene valor.
+func (u *UbersmithAdapter) GetVLAN(ctx context.Context, cid string) (string, error) {
+	for _, varName := range u.opts.VLANFields {
+		vlan, err := u.getCustomFieldValue(ctx, varName, "pack", cid)
+		if err != nil {
+			return "", err
+		}
+		if vlan != "" {
+			return vlan, nil
+		}
 	}
-	defer resp.Body.Close()
+	return "", nil
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+// Ping hace una llamada sin efectos (uber.method_list) para verificar URL y credenciales
+func (u *UbersmithAdapter) Ping(ctx context.Context) error {
+	_, err := u.call(ctx, u.baseURL+"?method=uber.method_list")
+	return err
+}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &result); err != nil {
-		return nil, err
+// firstNonEmpty devuelve current si ya tiene valor, o found en caso contrario
+func firstNonEmpty(current, found string) string {
+	if current != "" {
+		return current
 	}
+	return found
+}
 
-	if status, ok := result["status"].(bool); !ok || !status {
-		return nil, fmt.Errorf("respuesta de Ubersmith indica error")
+// getServiceData obtiene los datos completos del servicio usando client.service_get.
+// param es el nombre del parámetro que recibe el CID (normalmente service_id).
+func (u *UbersmithAdapter) getServiceData(ctx context.Context, serviceID, param string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s?method=%s&%s=%s", u.baseURL, MethodServiceGet, param, serviceID)
+	result, err := u.call(ctx, url)
+	if err != nil {
+		return nil, err
 	}
 
 	if data, ok := result["data"].(map[string]interface{}); ok {
 		return data, nil
 	}
 
-	return nil, fmt.Errorf("no se encontraron datos en la respuesta")
+	return nil, fmt.Errorf("%w: no se encontraron datos en la respuesta", core.ErrUbersmithBadResponse)
 }
 
-// getServiceCustomFields obtiene los custom fields del servicio usando metadata_field_list y metadata_bulk_get
-func (u *UbersmithAdapter) getServiceCustomFields(serviceID string) (user, pass string, err error) {
-	// Obtener los nombres de las variables de custom fields
-	customFieldVars := u.getCustomFieldVariables("pack")
-
-	// Obtener los valores usando los nombres encontrados
-	if customFieldVars.userVar != "" {
-		user = u.getCustomFieldValue(customFieldVars.userVar, "pack", serviceID)
-	}
-	if customFieldVars.passVar != "" {
-		pass = u.getCustomFieldValue(customFieldVars.passVar, "pack", serviceID)
-	}
+// getServiceCustomFields obtiene los custom fields del servicio usando metadata_bulk_get
+// (y metadata_field_list si la detección aproximada está habilitada).
+// Solo devuelve error si la búsqueda no puede seguir (credenciales, red); ver getCustomFieldValue.
+func (u *UbersmithAdapter) getServiceCustomFields(ctx context.Context, serviceID, metaType string) (user, pass string, err error) {
+	// Detección aproximada: obtener los nombres de las variables de custom fields por substrings
+	if u.opts.HeuristicMatch {
+		customFieldVars, err := u.getCustomFieldVariables(ctx, metaType)
+		if err != nil {
+			return "", "", err
+		}
 
-	// Fallback: intentar con nombres conocidos si no encontramos
-	if user == "" || pass == "" {
-		// Más variaciones para username
-		userFallbacks := []string{
-			"username", "user", "pppoe_user", "pppoe_username",
-			"pppo_user", "pppo_username", "ppp_username", "ppp_user",
+		// Obtener los valores usando los nombres encontrados
+		if user, err = u.getCustomFieldValue(ctx, customFieldVars.userVar, metaType, serviceID); err != nil {
+			return "", "", err
 		}
-		// Más variaciones para password
-		passFallbacks := []string{
-			"password", "pass", "pppoe_password", "pppoe_pass",
-			"pppo_password", "pppo_pass", "ppp_password", "ppp_pass",
+		if pass, err = u.getCustomFieldValue(ctx, customFieldVars.passVar, metaType, serviceID); err != nil {
+			return "", "", err
 		}
+	}
 
-		for _, varName := range userFallbacks {
-			if user == "" {
-				user = u.getCustomFieldValue(varName, "pack", serviceID)
-				if user != "" {
-					break
-				}
+	// Nombres configurados: intentar en orden si faltan campos requeridos
+	if !u.done(user, pass) {
+		for _, varName := range u.opts.UserFields {
+			if user != "" || u.done(user, pass) {
+				break
+			}
+			if user, err = u.getCustomFieldValue(ctx, varName, metaType, serviceID); err != nil {
+				return "", "", err
 			}
 		}
 
-		for _, varName := range passFallbacks {
-			if pass == "" {
-				pass = u.getCustomFieldValue(varName, "pack", serviceID)
-				if pass != "" {
-					break
-				}
+		for _, varName := range u.opts.PassFields {
+			if pass != "" || u.done(user, pass) {
+				break
+			}
+			if pass, err = u.getCustomFieldValue(ctx, varName, metaType, serviceID); err != nil {
+				return "", "", err
 			}
 		}
 	}
@@ -139,37 +305,37 @@ type customFieldVars struct {
 	passVar string
 }
 
-// getCustomFieldVariables obtiene los nombres de las variables de custom fields usando uber.metadata_field_list
-func (u *UbersmithAdapter) getCustomFieldVariables(metaType string) customFieldVars {
-	vars := customFieldVars{}
-	url := fmt.Sprintf("%s?method=uber.metadata_field_list&meta_type=%s", u.baseURL, metaType)
-
-	req, _ := http.NewRequest("GET", url, nil)
-	req.SetBasicAuth(u.user, u.pass)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return vars
+// getCustomFieldVariables obtiene los nombres de las variables de custom fields,
+// de la cache si están vigentes. Los errores no se cachean: se reintenta en el próximo circuito.
+// Solo se devuelven los errores que impiden seguir (ver fieldLookupError); con los demás
+// se continúa con los nombres configurados.
+func (u *UbersmithAdapter) getCustomFieldVariables(ctx context.Context, metaType string) (customFieldVars, error) {
+	if vars, ok := u.fields.get(metaType); ok {
+		return vars, nil
 	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
+	vars, err := u.fetchCustomFieldVariables(ctx, metaType)
 	if err != nil {
-		return vars
+		return vars, fieldLookupError(err)
 	}
+	u.fields.put(metaType, vars)
+	return vars, nil
+}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &result); err != nil {
-		return vars
-	}
+// fetchCustomFieldVariables consulta los nombres de las variables de custom fields usando uber.metadata_field_list
+func (u *UbersmithAdapter) fetchCustomFieldVariables(ctx context.Context, metaType string) (customFieldVars, error) {
+	vars := customFieldVars{}
+	url := fmt.Sprintf("%s?method=uber.metadata_field_list&meta_type=%s", u.baseURL, metaType)
 
-	if status, ok := result["status"].(bool); !ok || !status {
-		return vars
+	result, err := u.call(ctx, url)
+	if err != nil {
+		return vars, err
 	}
 
 	if data, ok := result["data"].(map[string]interface{}); ok {
-		for _, cfData := range data {
-			if cfObj, ok := cfData.(map[string]interface{}); ok {
+		// Las claves son los IDs de los campos: se recorren de menor a mayor, así que con varios
+		// campos que parecen usuario o contraseña gana siempre el de menor ID (el más antiguo)
+		for _, key := range core.SortedKeys(data) {
+			if cfObj, ok := data[key].(map[string]interface{}); ok {
 				if variable, ok := cfObj["variable"].(string); ok {
 					variableLower := strings.ToLower(variable)
 
@@ -195,51 +361,44 @@ func (u *UbersmithAdapter) getCustomFieldVariables(metaType string) customFieldV
 		}
 	}
 
-	return vars
+	return vars, nil
 }
 
-// getCustomFieldValue obtiene el valor de un custom field usando uber.metadata_bulk_get
-func (u *UbersmithAdapter) getCustomFieldValue(variable, metaType, serviceID string) string {
-	if variable == "" {
-		return ""
+// fieldLookupError decide si un error al consultar un custom field corta la búsqueda.
+// Que una variable no exista o no tenga dato es normal al probar nombres de fallback (nil);
+// credenciales inválidas o un fallo de red afectan a todas las consultas (se devuelve el error).
+func fieldLookupError(err error) error {
+	if errors.Is(err, core.ErrUbersmithNotFound) || errors.Is(err, core.ErrUbersmithBadResponse) {
+		return nil
 	}
-	url := fmt.Sprintf("%s?method=uber.metadata_bulk_get&variable=%s&meta_type=%s", u.baseURL, variable, metaType)
-
-	req, _ := http.NewRequest("GET", url, nil)
-	req.SetBasicAuth(u.user, u.pass)
+	return err
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return ""
+// getCustomFieldValue obtiene el valor de un custom field usando uber.metadata_bulk_get.
+// Retorna "" sin error si la variable no existe o el servicio no tiene valor cargado.
+func (u *UbersmithAdapter) getCustomFieldValue(ctx context.Context, variable, metaType, serviceID string) (string, error) {
+	if variable == "" {
+		return "", nil
 	}
-	defer resp.Body.Close()
+	url := fmt.Sprintf("%s?method=uber.metadata_bulk_get&variable=%s&meta_type=%s", u.baseURL, variable, metaType)
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	result, err := u.call(ctx, url)
 	if err != nil {
-		return ""
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &result); err != nil {
-		return ""
-	}
-
-	if status, ok := result["status"].(bool); !ok || !status {
-		return ""
+		return "", fieldLookupError(err)
 	}
 
 	if data, ok := result["data"].(map[string]interface{}); ok {
 		// Buscar como string
 		if val, ok := data[serviceID]; ok {
 			if valStr, ok := val.(string); ok && valStr != "" {
-				return strings.TrimSpace(valStr)
+				return strings.TrimSpace(valStr), nil
 			}
 			// También intentar como número
 			if valNum, ok := val.(float64); ok {
-				return fmt.Sprintf("%.0f", valNum)
+				return fmt.Sprintf("%.0f", valNum), nil
 			}
 			if valNum, ok := val.(int); ok {
-				return strconv.Itoa(valNum)
+				return strconv.Itoa(valNum), nil
 			}
 		}
 
@@ -249,29 +408,107 @@ func (u *UbersmithAdapter) getCustomFieldValue(variable, metaType, serviceID str
 			serviceIDStr := fmt.Sprintf("%d", serviceIDNum)
 			if val, ok := data[serviceIDStr]; ok {
 				if valStr, ok := val.(string); ok && valStr != "" {
-					return strings.TrimSpace(valStr)
+					return strings.TrimSpace(valStr), nil
 				}
 				// También intentar como número
 				if valNum, ok := val.(float64); ok {
-					return fmt.Sprintf("%.0f", valNum)
+					return fmt.Sprintf("%.0f", valNum), nil
 				}
 				if valNum, ok := val.(int); ok {
-					return strconv.Itoa(valNum)
+					return strconv.Itoa(valNum), nil
 				}
 			}
 		}
 	}
 
-	return ""
+	return "", nil
 }
 
-// maskPassword oculta la contraseña para logging
-func maskPassword(pass string) string {
-	if pass == "" {
-		return ""
+// call hace el GET y decodifica la respuesta JSON de Ubersmith ({"status": bool, "data": ..., "error_code", "error_message"}).
+// Los errores se clasifican en core.ErrUbersmithAuth, core.ErrUbersmithNotFound o core.ErrUbersmithBadResponse.
+func (u *UbersmithAdapter) call(ctx context.Context, url string) (map[string]interface{}, error) {
+	resp, err := u.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w (HTTP %d)", core.ErrUbersmithAuth, resp.StatusCode)
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w (HTTP 404)", core.ErrUbersmithNotFound)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("%w: HTTP %d", core.ErrUbersmithBadResponse, resp.StatusCode)
 	}
-	if len(pass) <= 4 {
-		return "****"
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return nil, fmt.Errorf("%w: JSON inválido: %v", core.ErrUbersmithBadResponse, err)
+	}
+
+	if status, ok := result["status"].(bool); !ok || !status {
+		return nil, classifyError(result)
 	}
-	return pass[:2] + "****" + pass[len(pass)-2:]
+	return result, nil
+}
+
+// Valores de error_code de Ubersmith en respuestas con status=false
+const (
+	errCodeAuth     = "1" // Autenticación requerida o rechazada
+	errCodeNotFound = "2" // Servicio, cliente o campo inexistente
+)
+
+// Palabras del error_message que indican el tipo de error cuando error_code no es conocido.
+// Se comparan como palabras completas: "auth" no debe coincidir con "author".
+var (
+	authMessage     = regexp.MustCompile(`\b(auth|authentication|unauthorized|login|credentials?|permission|access denied)\b`)
+	notFoundMessage = regexp.MustCompile(`\b(not found|invalid service|does not exist|no such)\b`)
+)
+
+// classifyError interpreta una respuesta con status=false: primero error_code y,
+// si no es uno conocido, error_message
+func classifyError(result map[string]interface{}) error {
+	msg, _ := result["error_message"].(string)
+	code := ""
+	switch c := result["error_code"].(type) {
+	case float64:
+		code = strconv.FormatFloat(c, 'f', -1, 64)
+	case string:
+		code = c
+	}
+
+	detail := fmt.Sprintf("error_code=%s error_message=%q", code, msg)
+	switch code {
+	case errCodeAuth:
+		return fmt.Errorf("%w: %s", core.ErrUbersmithAuth, detail)
+	case errCodeNotFound:
+		return fmt.Errorf("%w: %s", core.ErrUbersmithNotFound, detail)
+	}
+	lower := strings.ToLower(msg)
+	switch {
+	case authMessage.MatchString(lower):
+		return fmt.Errorf("%w: %s", core.ErrUbersmithAuth, detail)
+	case notFoundMessage.MatchString(lower):
+		return fmt.Errorf("%w: %s", core.ErrUbersmithNotFound, detail)
+	}
+	return fmt.Errorf("%w: %s", core.ErrUbersmithBadResponse, detail)
+}
+
+// get hace un GET autenticado con Basic Auth, respetando el rate limit de Ubersmith
+func (u *UbersmithAdapter) get(ctx context.Context, url string) (*http.Response, error) {
+	if err := u.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(u.user, u.pass)
+	return u.client.Do(req)
 }
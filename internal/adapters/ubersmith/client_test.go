@@ -0,0 +1,204 @@
+package ubersmith
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gpon-sync/internal/core"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeUbersmith responde uber.metadata_bulk_get con los valores de fields (variable -> CID -> valor),
+// client.service_get con los de services (CID -> campos) y uber.metadata_field_list con fieldList
+// (ID del campo -> variable). Registra cada request.
+type fakeUbersmith struct {
+	*httptest.Server
+	fields    map[string]map[string]string
+	services  map[string]map[string]string
+	fieldList map[string]string
+
+	mu       sync.Mutex
+	requests []string // "método" o "método:variable"
+}
+
+func newFakeUbersmith(t *testing.T, fields map[string]map[string]string, services map[string]map[string]string) *fakeUbersmith {
+	t.Helper()
+	f := &fakeUbersmith{fields: fields, services: services}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeUbersmith) serve(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	method := q.Get("method")
+	f.mu.Lock()
+	if v := q.Get("variable"); v != "" {
+		f.requests = append(f.requests, method+":"+v)
+	} else {
+		f.requests = append(f.requests, method)
+	}
+	f.mu.Unlock()
+
+	var resp map[string]interface{}
+	switch method {
+	case MethodCustomFields:
+		values, ok := f.fields[q.Get("variable")]
+		if !ok {
+			resp = map[string]interface{}{"status": false, "error_code": 2, "error_message": "Metadata field not found"}
+			break
+		}
+		resp = map[string]interface{}{"status": true, "data": values}
+	case MethodServiceGet:
+		service, ok := f.services[q.Get("service_id")]
+		if !ok {
+			resp = map[string]interface{}{"status": false, "error_code": 2, "error_message": "Invalid service id specified"}
+			break
+		}
+		resp = map[string]interface{}{"status": true, "data": service}
+	case "uber.metadata_field_list":
+		data := make(map[string]interface{}, len(f.fieldList))
+		for id, variable := range f.fieldList {
+			data[id] = map[string]interface{}{"variable": variable}
+		}
+		resp = map[string]interface{}{"status": true, "data": data}
+	default:
+		resp = map[string]interface{}{"status": false, "error_message": "Unknown method"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (f *fakeUbersmith) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+func (f *fakeUbersmith) adapter(opts Options) *UbersmithAdapter {
+	return NewUbersmithAdapter(f.URL+"/api/2.0/", "api", "secret", f.Client(), nil, opts)
+}
+
+// fullChain es la cantidad de requests sin cortar la búsqueda: cada nombre de fallback
+// de usuario y contraseña más client.service_get
+var fullChain = len(DefaultUserFields) + len(DefaultPassFields) + 1
+
+func TestGetServiceDetailsStopsWhenRequiredFieldsFound(t *testing.T) {
+	fields := map[string]map[string]string{
+		"username": {"100": "cliente100"},
+		"password": {"100": "clave100"},
+	}
+	tests := []struct {
+		name         string
+		required     []string
+		wantUser     string
+		wantPass     string
+		wantRequests int
+	}{
+		{"usuario y contraseña", nil, "cliente100", "clave100", 2},
+		{"solo usuario", []string{FieldUser}, "cliente100", "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeUbersmith(t, fields, nil)
+			u := srv.adapter(Options{RequiredFields: tt.required})
+
+			user, pass, err := u.GetServiceDetails(context.Background(), "100")
+			if err != nil {
+				t.Fatalf("GetServiceDetails: %v", err)
+			}
+			if user != tt.wantUser || pass != tt.wantPass {
+				t.Errorf("credenciales = %q/%q, se esperaba %q/%q", user, pass, tt.wantUser, tt.wantPass)
+			}
+			got := srv.requestCount()
+			if got != tt.wantRequests {
+				t.Errorf("requests = %d (%v), se esperaban %d", got, srv.requests, tt.wantRequests)
+			}
+			t.Logf("requests: %d de %d sin cortar la búsqueda", got, fullChain)
+		})
+	}
+}
+
+func TestGetServiceDetailsFallsBackToServiceGet(t *testing.T) {
+	srv := newFakeUbersmith(t, nil, map[string]map[string]string{
+		"100": {"username": "directo100", "password": "clave100"},
+	})
+	u := srv.adapter(Options{})
+
+	user, pass, err := u.GetServiceDetails(context.Background(), "100")
+	if err != nil {
+		t.Fatalf("GetServiceDetails: %v", err)
+	}
+	if user != "directo100" || pass != "clave100" {
+		t.Errorf("credenciales = %q/%q, se esperaban las de client.service_get", user, pass)
+	}
+	// Ningún custom field existe: se prueban todos los nombres antes de pasar al siguiente método
+	if got := srv.requestCount(); got != fullChain {
+		t.Errorf("requests = %d, se esperaban %d", got, fullChain)
+	}
+}
+
+func TestFetchCustomFieldVariablesIsDeterministic(t *testing.T) {
+	srv := newFakeUbersmith(t, nil, nil)
+	// Varios campos parecen usuario o contraseña PPPoE: gana siempre el de menor ID numérico
+	srv.fieldList = map[string]string{
+		"7":  "pppoe_user_backup",
+		"3":  "pppoe_username",
+		"12": "pppoe_pass_old",
+		"5":  "pppoe_password",
+		"9":  "vlan_id",
+	}
+
+	for i := 0; i < 20; i++ {
+		u := srv.adapter(Options{})
+		vars, err := u.fetchCustomFieldVariables(context.Background(), "pack")
+		if err != nil {
+			t.Fatalf("fetchCustomFieldVariables: %v", err)
+		}
+		if vars.userVar != "pppoe_username" || vars.passVar != "pppoe_password" {
+			t.Fatalf("intento %d: usuario %q, contraseña %q; se esperaba pppoe_username (ID 3) y pppoe_password (ID 5)", i, vars.userVar, vars.passVar)
+		}
+	}
+}
+
+func TestCallClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"HTTP 401", http.StatusUnauthorized, `{"status":false}`, core.ErrUbersmithAuth},
+		{"HTTP 403", http.StatusForbidden, ``, core.ErrUbersmithAuth},
+		{"HTTP 404", http.StatusNotFound, ``, core.ErrUbersmithNotFound},
+		{"5xx sin JSON", http.StatusBadGateway, `<html>Bad Gateway</html>`, core.ErrUbersmithBadResponse},
+		{"200 sin JSON", http.StatusOK, `<html>mantenimiento</html>`, core.ErrUbersmithBadResponse},
+		{"código de autenticación", http.StatusOK, `{"status":false,"error_code":1,"error_message":"Authentication required"}`, core.ErrUbersmithAuth},
+		{"código de no encontrado", http.StatusOK, `{"status":false,"error_code":2,"error_message":"Invalid service id specified"}`, core.ErrUbersmithNotFound},
+		// El código manda sobre el texto del mensaje
+		{"código de no encontrado con texto ambiguo", http.StatusOK, `{"status":false,"error_code":"2","error_message":"login record not found"}`, core.ErrUbersmithNotFound},
+		// Sin código conocido se usa el mensaje, por palabras completas
+		{"mensaje de credenciales", http.StatusOK, `{"status":false,"error_message":"Invalid login credentials"}`, core.ErrUbersmithAuth},
+		{"mensaje con author", http.StatusOK, `{"status":false,"error_code":99,"error_message":"Invalid author field"}`, core.ErrUbersmithBadResponse},
+		{"mensaje de no encontrado", http.StatusOK, `{"status":false,"error_message":"Client does not exist"}`, core.ErrUbersmithNotFound},
+		{"método desconocido", http.StatusOK, `{"status":false,"error_code":3,"error_message":"Unknown method"}`, core.ErrUbersmithBadResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(srv.Close)
+			u := NewUbersmithAdapter(srv.URL+"/api/2.0/", "api", "secret", srv.Client(), nil, Options{})
+
+			_, err := u.call(context.Background(), srv.URL+"/api/2.0/?method=client.service_get")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, se esperaba %v", err, tt.want)
+			}
+		})
+	}
+}
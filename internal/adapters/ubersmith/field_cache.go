@@ -0,0 +1,72 @@
+package ubersmith
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultFieldCacheTTL es cada cuánto se vuelve a consultar la definición de custom fields
+const DefaultFieldCacheTTL = time.Hour
+
+// fieldCache guarda las variables de custom fields detectadas por meta_type.
+// Las definiciones son globales (no dependen del servicio): se consultan una vez
+// y se reutilizan en todos los circuitos y ciclos hasta que vence el TTL.
+type fieldCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedFields
+	hits    int
+	misses  int
+}
+
+type cachedFields struct {
+	vars    customFieldVars
+	fetched time.Time
+}
+
+func newFieldCache(ttl time.Duration) *fieldCache {
+	return &fieldCache{ttl: ttl, entries: make(map[string]cachedFields)}
+}
+
+// get devuelve las variables de metaType si están en cache y no vencieron
+func (c *fieldCache) get(metaType string) (customFieldVars, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[metaType]
+	if !ok || (c.ttl > 0 && time.Since(entry.fetched) > c.ttl) {
+		c.misses++
+		return customFieldVars{}, false
+	}
+	c.hits++
+	return entry.vars, true
+}
+
+func (c *fieldCache) put(metaType string, vars customFieldVars) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[metaType] = cachedFields{vars: vars, fetched: time.Now()}
+}
+
+// resetStats reinicia los contadores sin descartar las definiciones
+func (c *fieldCache) resetStats() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hits, c.misses = 0, 0
+}
+
+func (c *fieldCache) stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// ResetCache reinicia los contadores de la cache de custom fields al comenzar un ciclo.
+// Las definiciones se conservan: solo se vuelven a consultar al vencer el TTL.
+func (u *UbersmithAdapter) ResetCache() {
+	u.fields.resetStats()
+}
+
+// CacheStats devuelve los aciertos y consultas de la cache de custom fields en el ciclo actual
+func (u *UbersmithAdapter) CacheStats() (hits, misses int) {
+	return u.fields.stats()
+}
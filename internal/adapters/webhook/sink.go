@@ -0,0 +1,111 @@
+// aqui estamos implementando la interfaz de ResultSink con un webhook HTTP
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gpon-sync/internal/core"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebhookSink publica cada resultado enriquecido con un POST JSON a una URL
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	opts   Options
+}
+
+// Options contiene los ajustes opcionales del webhook
+type Options struct {
+	// Token que se envía como "Authorization: Bearer <token>" (vacío = sin autenticación)
+	Token string
+}
+
+// Payload es el cuerpo JSON de cada publicación.
+// La contraseña PPPoE no se publica: los consumidores externos no la necesitan.
+type Payload struct {
+	Time          time.Time `json:"time"`
+	CID           string    `json:"cid"`
+	OLT           string    `json:"olt,omitempty"`
+	ONT           string    `json:"ont,omitempty"`
+	VLAN          int       `json:"vlan,omitempty"`
+	PPPoEUsername string    `json:"pppoe_username,omitempty"`
+	StatusGpon    string    `json:"status_gpon,omitempty"`
+	StatusGponRaw string    `json:"status_gpon_raw,omitempty"`
+	RxPower       string    `json:"rx_power,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	HostProblem   string    `json:"host_problem,omitempty"`
+}
+
+// NewWebhookSink crea el sink. client viene de httpclient.New (timeout, TLS, proxy).
+func NewWebhookSink(url string, client *http.Client, opts Options) *WebhookSink {
+	return &WebhookSink{url: url, client: client, opts: opts}
+}
+
+func newPayload(d core.EnrichedData) Payload {
+	p := Payload{
+		Time:          time.Now().UTC(),
+		CID:           d.CircuitID,
+		OLT:           d.OLT,
+		ONT:           d.ONT,
+		VLAN:          d.VLANID,
+		PPPoEUsername: d.PPPoEUsername,
+		StatusGpon:    d.StatusGpon,
+		StatusGponRaw: d.StatusGponRaw,
+		HostProblem:   d.HostProblem,
+	}
+	// Una lectura descartada (fuera de rango) o no resuelta no se publica como válida
+	if !d.KeepsCurrent("RxPower") {
+		p.RxPower = d.RxPower
+	}
+	if d.Error != nil {
+		p.Error = d.Error.Error()
+	}
+	return p
+}
+
+// Publish envía un resultado. Cualquier respuesta fuera de 2xx se considera un fallo.
+func (s *WebhookSink) Publish(ctx context.Context, data core.EnrichedData) error {
+	body, err := json.Marshal(newPayload(data))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.opts.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: error publicando CID %s: %w", data.CircuitID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook: HTTP %d publicando CID %s: %s", resp.StatusCode, data.CircuitID, preview(respBody))
+	}
+	// Vaciar el cuerpo permite reutilizar la conexión
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// preview recorta una respuesta para incluirla en un mensaje de error
+func preview(b []byte) string {
+	const maxLen = 200
+	s := strings.TrimSpace(string(b))
+	if len(s) > maxLen {
+		return s[:maxLen] + "..."
+	}
+	return s
+}
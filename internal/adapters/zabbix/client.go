@@ -1,13 +1,23 @@
 package zabbix
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"gpon-sync/internal/core"
+	"gpon-sync/internal/ratelimit"
 	"io"
+	"log"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,16 +25,89 @@ type ZabbixAdapter struct {
 	url      string
 	user     string
 	password string
-	token    string
 	client   *http.Client
+	limiter  *ratelimit.Limiter // Limita las requests por segundo hacia Zabbix (nil = sin límite)
+	opts     Options
+
+	// Cache por ciclo de la existencia de cada host (solo si opts.CheckHost)
+	hostsMu sync.Mutex
+	hosts   map[string]bool
+
+	// Cache por ciclo de los problemas activos de cada host (ver GetActiveProblems)
+	problemsMu sync.Mutex
+	problems   map[string][]core.Problem
+
+	// ID de la última request JSON-RPC (cada llamada usa uno nuevo)
+	lastID atomic.Int64
+
+	// Token de sesión. Puede renovarse a mitad del ciclo si vence (ver relogin);
+	// loginMu evita que varios workers hagan user.login a la vez.
+	tokenMu sync.RWMutex
+	token   string
+	loginMu sync.Mutex
+}
+
+// Options contiene los ajustes opcionales del adaptador
+type Options struct {
+	Keys KeyTemplates
+
+	// Verifica con host.get que la OLT exista antes de consultar items.
+	// Agrega una request por host distinto en cada ciclo.
+	CheckHost bool
+
+	// Traducción de códigos de status GPON a etiquetas (ej: "1" -> "online").
+	// Los códigos sin mapeo se devuelven sin cambios.
+	StatusLabels map[string]string
+
+	// Token de API fijo (Zabbix 5.4+). Si está definido no se hace user.login.
+	APIToken string
+
+	// Dónde se envía el token: AuthModeField (campo "auth" del JSON, por defecto)
+	// o AuthModeHeader (Authorization: Bearer, Zabbix 6.4+)
+	AuthMode string
+
+	// Formato del rx power devuelto (nil = DefaultPowerFormat)
+	PowerFormat *PowerFormat
+
+	// Tag de Zabbix (5.4+) con el que se filtran los items de potencia en el servidor,
+	// como "nombre" o "nombre:valor". Vacío = se traen todos los items del host.
+	// Los items ms_item_ont_rx_power también deben tener el tag.
+	PowerTag string
+
+	// Antigüedad máxima de lastvalue (0 = sin límite). Un item que dejó de actualizarse
+	// conserva su último valor: más viejo que esto se descarta y se reporta como desactualizado.
+	MaxValueAge time.Duration
+
+	// Logs [DEBUG] por consulta (ej: status sin etiqueta en GPON_STATUS_MAP)
+	Verbose bool
 }
 
-func NewZabbixAdapter(url, user, pass string) *ZabbixAdapter {
+// Modos de envío del token en Options.AuthMode
+const (
+	AuthModeField  = "field"
+	AuthModeHeader = "header"
+)
+
+// KeyTemplates son las plantillas de las keys de status y rx power (dependen del template de Zabbix de cada OLT)
+type KeyTemplates struct {
+	Status core.KeyTemplate
+	Power  core.KeyTemplate
+}
+
+// NewZabbixAdapter crea el adaptador. client viene de httpclient.New (timeout, TLS, proxy).
+// user y pass se ignoran si opts.APIToken está definido.
+func NewZabbixAdapter(url, user, pass string, client *http.Client, limiter *ratelimit.Limiter, opts Options) *ZabbixAdapter {
+	if opts.PowerFormat == nil {
+		format := DefaultPowerFormat()
+		opts.PowerFormat = &format
+	}
 	return &ZabbixAdapter{
 		url:      url,
 		user:     user,
 		password: pass,
-		client:   &http.Client{Timeout: 10 * time.Second}, // Timeout para Zabbix
+		client:   client,
+		limiter:  limiter,
+		opts:     opts,
 	}
 }
 
@@ -37,104 +120,316 @@ type zabbixRequest struct {
 	Auth    string      `json:"auth,omitempty"`
 }
 
-type zabbixResponse struct {
-	Result json.RawMessage `json:"result"` // Usamos RawMessage para diferir el decode
-	Error  *zabbixError    `json:"error,omitempty"`
-}
-
 type zabbixError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Data    string `json:"data"`
 }
 
+func (e *zabbixError) Error() string {
+	if e.Data != "" {
+		return fmt.Sprintf("zabbix api error %d: %s (%s)", e.Code, e.Message, e.Data)
+	}
+	return fmt.Sprintf("zabbix api error %d: %s", e.Code, e.Message)
+}
+
+// sessionExpired indica si Zabbix rechazó el token de sesión (ej: "Session terminated, re-login, please."
+// o "Not authorised."): un nuevo user.login lo resuelve
+func sessionExpired(err error) bool {
+	var zErr *zabbixError
+	if !errors.As(err, &zErr) {
+		return false
+	}
+	text := strings.ToLower(zErr.Message + " " + zErr.Data)
+	return strings.Contains(text, "session terminated") || strings.Contains(text, "re-login") ||
+		strings.Contains(text, "not authorised") || strings.Contains(text, "not authorized")
+}
+
 // Estructura para leer los Items
 type zabbixItem struct {
 	ItemID    string `json:"itemid"`
 	Name      string `json:"name"`
 	Key       string `json:"key_"`      // Ej: "rx power:1/1" - Nota: Zabbix usa "key_" en el JSON
 	LastValue string `json:"lastvalue"` // Ej: "-26.7"
+	LastClock string `json:"lastclock"` // Unix timestamp del último valor (ej: "1717171717"); "0" = nunca se recolectó
+}
+
+// stale indica si el último valor del item es más viejo que MaxValueAge (siempre false si no está configurado)
+func (z *ZabbixAdapter) stale(item zabbixItem) bool {
+	if z.opts.MaxValueAge <= 0 {
+		return false
+	}
+	clock, err := strconv.ParseInt(item.LastClock, 10, 64)
+	if err != nil || clock <= 0 {
+		return true
+	}
+	return time.Since(time.Unix(clock, 0)) > z.opts.MaxValueAge
+}
+
+// freshItems devuelve los items cuyo último valor no está vencido
+func (z *ZabbixAdapter) freshItems(items []zabbixItem) []zabbixItem {
+	if z.opts.MaxValueAge <= 0 {
+		return items
+	}
+	fresh := make([]zabbixItem, 0, len(items))
+	for _, item := range items {
+		if !z.stale(item) {
+			fresh = append(fresh, item)
+		}
+	}
+	return fresh
 }
 
 // Authenticate: Realiza el login y guarda el token
-func (z *ZabbixAdapter) Authenticate() error {
+// Con un token de API configurado no hay login: solo se usa ese token.
+func (z *ZabbixAdapter) Authenticate(ctx context.Context) error {
+	// Cada ciclo se reautentica: aprovechamos para descartar la cache de hosts
+	// (una OLT recién agregada a Zabbix se detecta en el siguiente ciclo)
+	z.hostsMu.Lock()
+	z.hosts = make(map[string]bool)
+	z.hostsMu.Unlock()
+	z.problemsMu.Lock()
+	z.problems = make(map[string][]core.Problem)
+	z.problemsMu.Unlock()
+
+	if z.opts.APIToken != "" {
+		z.setToken(z.opts.APIToken)
+		return nil
+	}
+
+	z.loginMu.Lock()
+	defer z.loginMu.Unlock()
+	return z.login(ctx)
+}
+
+// login hace user.login y guarda el token de sesión
+func (z *ZabbixAdapter) login(ctx context.Context) error {
 	// Según la documentación de Zabbix API, los parámetros pueden ser "user" o "username"
 	// Probamos con "username" que es más común en versiones recientes
-	body := zabbixRequest{
-		Jsonrpc: "2.0",
-		Method:  "user.login",
-		Params: map[string]interface{}{
-			"username": z.user,
-			"password": z.password,
-		},
-		ID: 1,
+	var result json.RawMessage
+	err := z.callMethod(ctx, "user.login", map[string]interface{}{
+		"username": z.user,
+		"password": z.password,
+	}, &result)
+	if err != nil {
+		return err
 	}
 
-	respBytes, err := z.doRequest(body)
+	token, err := parseToken(result)
 	if err != nil {
 		return err
 	}
+	z.setToken(token)
+	return nil
+}
 
-	// Parseamos el resultado que es un string simple (el token)
+// Ping se autentica y consulta un host para verificar que el token sirva
+// (con un token de API, Authenticate no llega a contactar a Zabbix)
+func (z *ZabbixAdapter) Ping(ctx context.Context) error {
+	if err := z.Authenticate(ctx); err != nil {
+		return err
+	}
+	return z.callMethod(ctx, "host.get", map[string]interface{}{
+		"output": []string{"hostid"},
+		"limit":  1,
+	}, nil)
+}
+
+// parseToken extrae el token del resultado de user.login. Normalmente es un string,
+// pero algunas versiones o proxies lo devuelven dentro de un objeto (ej: userData=true devuelve "sessionid").
+func parseToken(result json.RawMessage) (string, error) {
 	var token string
-	if err := json.Unmarshal(respBytes, &token); err != nil {
-		return fmt.Errorf("fallo al parsear token: %v", err)
+	if err := json.Unmarshal(result, &token); err == nil && token != "" {
+		return token, nil
 	}
 
-	z.token = token
-	return nil
+	var obj map[string]interface{}
+	if err := json.Unmarshal(result, &obj); err == nil {
+		for _, key := range []string{"sessionid", "token", "auth", "result"} {
+			if s, ok := obj[key].(string); ok && s != "" {
+				return s, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("fallo al parsear token: resultado inesperado de user.login: %s", preview(result))
+}
+
+// preview devuelve el inicio de una respuesta para incluirlo en un error
+func preview(b []byte) string {
+	s := strings.TrimSpace(string(b))
+	if len(s) > previewLen {
+		return s[:previewLen] + "..."
+	}
+	return s
+}
+
+// previewLen es el largo máximo de una respuesta incluida en un mensaje de error
+const previewLen = 200
+
+// statusLabel traduce un código de status a su etiqueta configurada
+func (z *ZabbixAdapter) statusLabel(raw string) string {
+	if len(z.opts.StatusLabels) == 0 || raw == "" {
+		return raw
+	}
+	if label, ok := z.opts.StatusLabels[raw]; ok {
+		return label
+	}
+	if z.opts.Verbose {
+		log.Printf("[DEBUG] Zabbix: status GPON %q sin etiqueta en GPON_STATUS_MAP, se guarda sin traducir", raw)
+	}
+	return raw
+}
+
+// hostExists consulta host.get una sola vez por host en cada ciclo
+func (z *ZabbixAdapter) hostExists(ctx context.Context, host string) (bool, error) {
+	z.hostsMu.Lock()
+	exists, cached := z.hosts[host]
+	z.hostsMu.Unlock()
+	if cached {
+		return exists, nil
+	}
+
+	var hosts []struct {
+		HostID string `json:"hostid"`
+	}
+	err := z.callMethod(ctx, "host.get", map[string]interface{}{
+		"output": []string{"hostid"},
+		"filter": map[string]interface{}{
+			"host": []string{host},
+		},
+	}, &hosts)
+	if err != nil {
+		return false, err
+	}
+
+	exists = len(hosts) > 0
+	z.hostsMu.Lock()
+	if z.hosts == nil {
+		z.hosts = make(map[string]bool)
+	}
+	z.hosts[host] = exists
+	z.hostsMu.Unlock()
+	return exists, nil
 }
 
-// GetOpticalInfo construye la key exacta basada en puerto e indice
-func (z *ZabbixAdapter) GetOpticalInfo(oltHost, ontID string) (string, string, error) {
+// GetActiveProblems devuelve los problemas sin resolver del host (problem.get), una sola vez por host en cada ciclo.
+// Un host que no existe en Zabbix no tiene problemas.
+func (z *ZabbixAdapter) GetActiveProblems(ctx context.Context, host string) ([]core.Problem, error) {
+	z.problemsMu.Lock()
+	problems, cached := z.problems[host]
+	z.problemsMu.Unlock()
+	if cached {
+		return problems, nil
+	}
+
+	// problem.get filtra por hostid, no por nombre
+	var hosts []struct {
+		HostID string `json:"hostid"`
+	}
+	err := z.callMethod(ctx, "host.get", map[string]interface{}{
+		"output": []string{"hostid"},
+		"filter": map[string]interface{}{
+			"host": []string{host},
+		},
+	}, &hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	problems = []core.Problem{}
+	if len(hosts) > 0 {
+		var rows []struct {
+			Name     string `json:"name"`
+			Severity string `json:"severity"` // Zabbix devuelve los números como strings
+		}
+		err := z.callMethod(ctx, "problem.get", map[string]interface{}{
+			"output":  []string{"name", "severity"},
+			"hostids": []string{hosts[0].HostID},
+		}, &rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			severity, _ := strconv.Atoi(row.Severity)
+			problems = append(problems, core.Problem{Severity: severity, Description: row.Name})
+		}
+	}
+
+	z.problemsMu.Lock()
+	if z.problems == nil {
+		z.problems = make(map[string][]core.Problem)
+	}
+	z.problems[host] = problems
+	z.problemsMu.Unlock()
+	return problems, nil
+}
+
+// GetOpticalInfo construye la key exacta basada en puerto e indice.
+// Se mantiene por compatibilidad: es un wrapper de GetOpticalDetails que descarta el diagnóstico.
+func (z *ZabbixAdapter) GetOpticalInfo(ctx context.Context, oltHost, ontID string) (string, string, error) {
+	info, err := z.GetOpticalDetails(ctx, oltHost, ontID)
+	if err != nil {
+		return "", "", err
+	}
+	return info.Status, info.RxPower, nil
+}
+
+// GetOpticalDetails consulta status y rx power e indica qué keys se encontraron y por qué camino
+func (z *ZabbixAdapter) GetOpticalDetails(ctx context.Context, oltHost, ontID string) (core.OpticalInfo, error) {
 	// 1. LÓGICA DE PARSEO: 1/2/3 -> [1, 2, 3]
-	parts := strings.Split(ontID, "/")
-	if len(parts) < 3 {
-		return "", "", fmt.Errorf("formato ONT ID inválido: %s", ontID)
+	// Validamos antes de cualquier llamada de red: un valor malformado no debe llegar a Zabbix
+	ont, err := core.ParseONTID(ontID)
+	if err != nil {
+		return core.OpticalInfo{}, err
 	}
 
-	segundo := parts[1] // El "2" para el status (segundo número)
-	tercero := parts[2] // El "3" para la potencia (tercer número)
+	segundo := ont.Second // El "2" para el status (segundo número)
+	tercero := ont.Third  // El "3" para la potencia (tercer número)
 
-	// Keys según requerimiento: rx power:2/3 y gpon_2_status
+	// Keys según las plantillas configuradas (por defecto rx power:2/3 y gpon_2_status)
 	// Ejemplo: </>=1/2/3 entonces rx power:2/3 y gpon_2_status
-	// Formato exacto en Zabbix: "rx power:1/1", "rx power:1/2", etc.
-	powerKey := fmt.Sprintf("rx power:%s/%s", segundo, tercero)
-	statusKey := fmt.Sprintf("gpon_%s_status", segundo)
+	info := core.OpticalInfo{
+		StatusKey: z.opts.Keys.Status.Render(ont),
+		PowerKey:  z.opts.Keys.Power.Render(ont),
+	}
+
+	// Pre-chequeo opcional: sin él, un host inexistente devuelve listas vacías y el circuito queda en blanco
+	if z.opts.CheckHost {
+		exists, err := z.hostExists(ctx, oltHost)
+		if err != nil {
+			return info, err
+		}
+		if !exists {
+			return info, &core.ErrZabbixHostNotFound{Host: oltHost}
+		}
+	}
 
 	// Buscamos ambas keys directamente por nombre exacto
 	// Hacemos dos consultas separadas porque el filtro con array puede no funcionar correctamente
 	// Primero el status
 	paramsStatus := map[string]interface{}{
-		"output": []string{"lastvalue", "key_"},
+		"output": []string{"lastvalue", "lastclock", "key_"},
 		"host":   oltHost,
 		"filter": map[string]interface{}{
-			"key_": statusKey,
+			"key_": info.StatusKey,
 		},
 	}
 
-	reqBodyStatus := zabbixRequest{
-		Jsonrpc: "2.0",
-		Method:  "item.get",
-		Params:  paramsStatus,
-		ID:      2,
-		Auth:    z.token,
-	}
-
-	resultBytesStatus, err := z.doRequest(reqBodyStatus)
-	if err != nil {
-		return "", "", err
-	}
-
 	var statusItems []zabbixItem
-	if err := json.Unmarshal(resultBytesStatus, &statusItems); err != nil {
-		return "", "", fmt.Errorf("error parseando status items: %v", err)
+	if err := z.callMethod(ctx, "item.get", paramsStatus, &statusItems); err != nil {
+		return info, err
 	}
 
-	var status string
 	for _, item := range statusItems {
-		if item.Key == statusKey {
-			status = item.LastValue
+		if item.Key == info.StatusKey {
+			if z.stale(item) {
+				info.StatusStale = true
+				break
+			}
+			info.StatusRaw = item.LastValue
+			info.Status = z.statusLabel(item.LastValue)
+			info.StatusFound = true
 			break
 		}
 	}
@@ -142,109 +437,374 @@ func (z *ZabbixAdapter) GetOpticalInfo(oltHost, ontID string) (string, string, e
 	// Ahora buscamos el RxPower
 	// Obtenemos todas las keys del host y buscamos la key exacta en memoria
 	paramsPower := map[string]interface{}{
-		"output": []string{"lastvalue", "key_"},
+		"output": []string{"lastvalue", "lastclock", "key_"},
 		"host":   oltHost,
 	}
+	if tags := z.powerTagFilter(); tags != nil {
+		paramsPower["tags"] = tags
+	}
 
-	reqBodyPower := zabbixRequest{
-		Jsonrpc: "2.0",
-		Method:  "item.get",
-		Params:  paramsPower,
-		ID:      3,
-		Auth:    z.token,
-	}
-
-	resultBytesPower, err := z.doRequest(reqBodyPower)
-	var rx string
-	if err == nil {
-		var allItems []zabbixItem
-		if err := json.Unmarshal(resultBytesPower, &allItems); err == nil {
-			// Buscar la key exacta
-			for _, item := range allItems {
-				if item.Key == powerKey {
-					rx = item.LastValue
-					// Solo agregar " dBm" si hay un valor y no es "0"
-					if rx != "" && rx != "0" {
-						rx = rx + " dBm"
-					} else if rx == "0" {
-						rx = "" // Si es 0, probablemente no hay señal activa
-					}
-					break
-				}
-			}
+	// Un error en la consulta de potencia no invalida el status ya obtenido: viaja en PowerErr
+	var allItems []zabbixItem
+	if err := z.callMethod(ctx, "item.get", paramsPower, &allItems); err != nil {
+		info.PowerErr = fmt.Errorf("consulta de rx power: %w", err)
+		return info, nil
+	}
+
+	// Buscar la key exacta (solo entre los items con valores recientes)
+	format := *z.opts.PowerFormat
+	items := z.freshItems(allItems)
+	if rx, ok := findExactPower(items, info.PowerKey, format); ok {
+		info.RxPower = rx
+		info.PowerFound = true
+		info.PowerSource = core.PowerSourceExactKey
+		return info, nil
+	}
+
+	// Si no encontramos la key exacta, buscamos ms_item_ont_rx_power_7m y parseamos el JSON
+	if rx, ok := findJSONPower(items, fmt.Sprintf("%s/%s", segundo, tercero), format); ok {
+		info.RxPower = rx
+		info.PowerFound = true
+		info.PowerSource = core.PowerSourceJSON
+		return info, nil
+	}
+
+	// Sin potencia reciente: si había un valor vencido se informa como desactualizado, no como faltante
+	if len(items) < len(allItems) {
+		_, exact := findExactPower(allItems, info.PowerKey, format)
+		_, inJSON := findJSONPower(allItems, fmt.Sprintf("%s/%s", segundo, tercero), format)
+		info.PowerStale = exact || inJSON
+	}
+	return info, nil
+}
+
+// powerTagFilter arma el filtro "tags" de item.get a partir de PowerTag (nil = sin filtro).
+// Sin valor se aceptan todos los items que tengan el tag (operador "contiene" con valor vacío).
+func (z *ZabbixAdapter) powerTagFilter() []map[string]interface{} {
+	if z.opts.PowerTag == "" {
+		return nil
+	}
+	name, value, hasValue := strings.Cut(z.opts.PowerTag, ":")
+	operator := 0 // Contiene
+	if hasValue {
+		operator = 1 // Igual
+	}
+	return []map[string]interface{}{{"tag": name, "value": value, "operator": operator}}
+}
+
+// findExactPower busca el item con la key exacta de potencia
+func findExactPower(items []zabbixItem, powerKey string, format PowerFormat) (string, bool) {
+	for _, item := range items {
+		if item.Key == powerKey {
+			// Si es vacío (o 0, según el formato), probablemente no hay señal activa: seguimos buscando en el JSON
+			return format.formatExact(item.LastValue)
+		}
+	}
+	return "", false
+}
 
-			// Si no encontramos la key exacta, buscamos ms_item_ont_rx_power_7m y parseamos el JSON
-			if rx == "" {
-				for _, item := range allItems {
-					if strings.Contains(strings.ToLower(item.Key), "ms_item_ont_rx_power") {
-						// El valor es un JSON array con objetos que tienen "interface" y valores numéricos
-						// Ejemplo: [{"interface":"1/6","...":"-20.4"}, ...]
-						var powerData []map[string]interface{}
-						if err := json.Unmarshal([]byte(item.LastValue), &powerData); err == nil {
-							// Buscar el objeto que tenga interface igual a nuestro patrón (segundo/tercero)
-							ontPattern := fmt.Sprintf("%s/%s", segundo, tercero)
-							for _, entry := range powerData {
-								if iface, ok := entry["interface"].(string); ok && iface == ontPattern {
-									// Buscar el valor numérico (puede estar en diferentes campos)
-									for key, val := range entry {
-										if key != "interface" && key != "onustatus" && key != "indice" && key != "contador" {
-											if valStr, ok := val.(string); ok {
-												// Intentar convertir a número para verificar que es un valor válido
-												if valFloat, err := strconv.ParseFloat(valStr, 64); err == nil {
-													// Si el valor es 0, probablemente no hay señal
-													if valFloat != 0 {
-														// Los valores vienen en centésimas (ej: -158 = -15.8 dBm)
-														// Dividimos por 10 para obtener el valor real
-														rx = fmt.Sprintf("%.1f", valFloat/10.0)
-														rx = rx + " dBm"
-														break
-													}
-												}
-											}
-										}
-									}
-									if rx != "" {
-										break
-									}
-								}
-							}
-						}
-						if rx != "" {
-							break
-						}
+// findJSONPower busca la potencia dentro de los items ms_item_ont_rx_power (valor JSON con todas las ONT)
+func findJSONPower(items []zabbixItem, ontPattern string, format PowerFormat) (string, bool) {
+	for _, item := range items {
+		if !strings.Contains(strings.ToLower(item.Key), "ms_item_ont_rx_power") {
+			continue
+		}
+		// El valor es un JSON array con objetos que tienen "interface" y valores numéricos
+		// Ejemplo: [{"interface":"1/6","...":"-20.4"}, ...]
+		var powerData []map[string]interface{}
+		if err := json.Unmarshal([]byte(item.LastValue), &powerData); err != nil {
+			continue
+		}
+		// Buscar el objeto que tenga interface igual a nuestro patrón (segundo/tercero)
+		for _, entry := range powerData {
+			if iface, ok := entry["interface"].(string); !ok || iface != ontPattern {
+				continue
+			}
+			// Buscar el valor numérico (puede estar en diferentes campos).
+			// Las claves son nombres de campo: en orden alfabético, con varios campos
+			// numéricos se usa siempre el primero (ej: "rx" antes que "tx").
+			for _, key := range core.SortedKeys(entry) {
+				if key == "interface" || key == "onustatus" || key == "indice" || key == "contador" {
+					continue
+				}
+				valStr, ok := entry[key].(string)
+				if !ok {
+					continue
+				}
+				// Intentar convertir a número para verificar que es un valor válido
+				// Si el valor es 0, probablemente no hay señal (según el formato)
+				if valFloat, err := strconv.ParseFloat(valStr, 64); err == nil {
+					// Los valores vienen en centésimas (ej: -158 = -15.8 dBm)
+					// Dividimos por 10 para obtener el valor real
+					if rx, ok := format.formatDBm(valFloat / 10.0); ok {
+						return rx, true
 					}
 				}
 			}
 		}
 	}
+	return "", false
+}
+
+// callMethod llama a un método de la API con el sobre JSON-RPC y el token de la sesión,
+// y decodifica "result" en out (nil = se descarta el resultado).
+// Los errores de la API se devuelven con su código y mensaje.
+func (z *ZabbixAdapter) callMethod(ctx context.Context, method string, params interface{}, out interface{}) error {
+	reqBody := zabbixRequest{
+		Jsonrpc: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      int(z.lastID.Add(1)),
+	}
+	// El login es la única llamada sin token
+	if method != "user.login" {
+		reqBody.Auth = z.currentToken()
+	}
+
+	if out == nil {
+		var discard json.RawMessage
+		out = &discard
+	}
+	err := z.doRequest(ctx, reqBody, out)
 
-	return status, rx, nil
+	// Sesión vencida a mitad del ciclo: un solo reintento después de volver a loguearse.
+	// Con un token de API no hay login que lo renueve.
+	if reqBody.Auth == "" || z.opts.APIToken != "" || !sessionExpired(err) {
+		return err
+	}
+	log.Printf("[WARN] Zabbix: la sesión venció (%v), reautenticando...", err)
+	if loginErr := z.relogin(ctx, reqBody.Auth); loginErr != nil {
+		return fmt.Errorf("%w (la reautenticación falló: %v)", err, loginErr)
+	}
+	reqBody.Auth = z.currentToken()
+	reqBody.ID = int(z.lastID.Add(1))
+	return z.doRequest(ctx, reqBody, out)
 }
 
-// doRequest: Helper privado para hacer la llamada HTTP y manejar errores de Zabbix
-func (z *ZabbixAdapter) doRequest(reqBody zabbixRequest) ([]byte, error) {
+// currentToken devuelve el token de sesión vigente
+func (z *ZabbixAdapter) currentToken() string {
+	z.tokenMu.RLock()
+	defer z.tokenMu.RUnlock()
+	return z.token
+}
+
+func (z *ZabbixAdapter) setToken(token string) {
+	z.tokenMu.Lock()
+	defer z.tokenMu.Unlock()
+	z.token = token
+}
+
+// relogin renueva la sesión después de que Zabbix rechazó expired. Varios workers pueden
+// detectar el vencimiento a la vez: solo el primero hace user.login, el resto usa el token nuevo.
+func (z *ZabbixAdapter) relogin(ctx context.Context, expired string) error {
+	z.loginMu.Lock()
+	defer z.loginMu.Unlock()
+	if z.currentToken() != expired {
+		return nil
+	}
+	return z.login(ctx)
+}
+
+// doRequest: Helper privado para hacer la llamada HTTP y manejar errores de Zabbix.
+// "result" se decodifica en out directamente desde el cuerpo, sin leerlo entero en memoria:
+// el item.get de una OLT grande son varios MB y varios workers pueden estar leyendo a la vez.
+func (z *ZabbixAdapter) doRequest(ctx context.Context, reqBody zabbixRequest, out interface{}) error {
+	if err := z.limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	// En modo header el token viaja en Authorization y el campo "auth" (deprecado en 6.4) se omite
+	bearer := ""
+	if z.opts.AuthMode == AuthModeHeader && reqBody.Auth != "" {
+		bearer = reqBody.Auth
+		reqBody.Auth = ""
+	}
+
 	jsonData, _ := json.Marshal(reqBody)
-	req, err := http.NewRequest("POST", z.url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", z.url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	// No se define Accept-Encoding a mano: así el transport pide gzip y descomprime solo
+	// (el item.get sin filtro de una OLT grande pesa varios MB)
 	resp, err := z.client.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
-	bodyBytes, _ := io.ReadAll(resp.Body)
+	body, err := decodedBody(resp)
+	if err != nil {
+		return fmt.Errorf("respuesta de Zabbix comprimida inválida: %w", err)
+	}
+
+	// El comienzo del cuerpo queda disponible para mostrarlo si no es JSON-RPC
+	buffered := bufio.NewReader(body)
+	head, _ := buffered.Peek(previewLen)
+
+	zErr, err := decodeResponse(json.NewDecoder(buffered), out)
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("error parseando la respuesta de %s: %v", reqBody.Method, err)
+		}
+		// Ej: una página HTML de error de un proxy
+		return fmt.Errorf("respuesta de Zabbix no es JSON-RPC (HTTP %d): %s", resp.StatusCode, preview(head))
+	}
+
+	if zErr != nil {
+		return zErr
+	}
+	return nil
+}
 
-	var zResp zabbixResponse
-	if err := json.Unmarshal(bodyBytes, &zResp); err != nil {
+// decodeResponse recorre el sobre JSON-RPC token por token y decodifica "result" en out.
+// json.Decoder.Decode guarda el valor completo en su buffer antes de decodificarlo, así que
+// decodificar el sobre de una vez volvería a tener el cuerpo entero en memoria junto al slice.
+func decodeResponse(dec *json.Decoder, out interface{}) (*zabbixError, error) {
+	if err := expectDelim(dec, '{'); err != nil {
 		return nil, err
 	}
+	var zErr *zabbixError
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "result":
+			err = decodeResult(dec, out)
+		case "error":
+			err = dec.Decode(&zErr)
+		default: // jsonrpc, id
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return zErr, expectDelim(dec, '}')
+}
 
-	if zResp.Error != nil {
-		return nil, fmt.Errorf("zabbix api error %d: %s", zResp.Error.Code, zResp.Error.Message)
+// decodeResult decodifica una lista (ej: los items de item.get) de a un elemento, para que el
+// buffer del decoder no crezca más que un item. Cualquier otro resultado se decodifica entero.
+func decodeResult(dec *json.Decoder, out interface{}) error {
+	list := reflect.ValueOf(out)
+	if _, custom := out.(json.Unmarshaler); custom || list.Kind() != reflect.Pointer ||
+		list.Elem().Kind() != reflect.Slice || list.Elem().Type().Elem().Kind() == reflect.Uint8 {
+		return dec.Decode(out)
+	}
+	list = list.Elem()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil { // null
+		return nil
 	}
+	if tok != json.Delim('[') {
+		return &json.UnmarshalTypeError{Value: fmt.Sprint(tok), Type: list.Type(), Offset: dec.InputOffset()}
+	}
+	list.Set(reflect.MakeSlice(list.Type(), 0, 0))
+	for dec.More() {
+		elem := reflect.New(list.Type().Elem())
+		if err := dec.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		list.Set(reflect.Append(list, elem.Elem()))
+	}
+	return expectDelim(dec, ']')
+}
 
-	return zResp.Result, nil
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("se esperaba %q en la respuesta JSON-RPC, se obtuvo %v", delim, tok)
+	}
+	return nil
+}
+
+// decodedBody devuelve el cuerpo descomprimido. El transport ya descomprime las respuestas gzip
+// que pidió él mismo; esto cubre a un proxy que comprime sin que se le pida.
+func decodedBody(resp *http.Response) (io.Reader, error) {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+	return gzip.NewReader(resp.Body)
+}
+
+// HistoryType es el tipo de dato del item en Zabbix: history.get solo devuelve
+// datos si se pide la tabla de historia correcta para el item
+type HistoryType int
+
+const (
+	HistoryFloat    HistoryType = 0 // Numérico con decimales (ej: rx power)
+	HistoryText     HistoryType = 1 // Caracteres (character)
+	HistoryLog      HistoryType = 2
+	HistoryUnsigned HistoryType = 3 // Numérico entero sin signo (ej: status)
+	HistoryLongText HistoryType = 4 // Texto
+)
+
+// numeric indica si los valores de este tipo se pueden interpretar como números
+func (t HistoryType) numeric() bool {
+	return t == HistoryFloat || t == HistoryUnsigned
+}
+
+// HistoryPoint es un valor histórico de un item.
+// Value solo se completa para tipos numéricos; Raw siempre contiene el valor tal cual.
+type HistoryPoint struct {
+	Clock time.Time
+	Value float64
+	Raw   string
+}
+
+// GetItemHistory obtiene los valores de un item entre from y to (history.get), del más reciente al más antiguo.
+// limit <= 0 no limita la cantidad de puntos.
+func (z *ZabbixAdapter) GetItemHistory(ctx context.Context, itemID string, historyType HistoryType, from, to time.Time, limit int) ([]HistoryPoint, error) {
+	params := map[string]interface{}{
+		"output":    "extend",
+		"history":   int(historyType),
+		"itemids":   []string{itemID},
+		"time_from": from.Unix(),
+		"time_till": to.Unix(),
+		"sortfield": "clock",
+		"sortorder": "DESC",
+	}
+	if limit > 0 {
+		params["limit"] = limit
+	}
+
+	// Zabbix devuelve clock y value como strings
+	var rows []struct {
+		Clock string `json:"clock"`
+		Value string `json:"value"`
+	}
+	if err := z.callMethod(ctx, "history.get", params, &rows); err != nil {
+		return nil, err
+	}
+
+	points := make([]HistoryPoint, 0, len(rows))
+	for _, row := range rows {
+		sec, err := strconv.ParseInt(row.Clock, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("clock inválido en historia del item %s: %q", itemID, row.Clock)
+		}
+		p := HistoryPoint{Clock: time.Unix(sec, 0), Raw: row.Value}
+		if historyType.numeric() {
+			if p.Value, err = strconv.ParseFloat(row.Value, 64); err != nil {
+				return nil, fmt.Errorf("valor no numérico en historia del item %s: %q (¿tipo de historia incorrecto?)", itemID, row.Value)
+			}
+		}
+		points = append(points, p)
+	}
+	return points, nil
 }
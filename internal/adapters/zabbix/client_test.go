@@ -0,0 +1,739 @@
+package zabbix
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gpon-sync/internal/core"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rpcCall es una request JSON-RPC recibida por el servidor falso
+type rpcCall struct {
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params"`
+	Auth   string                 `json:"auth"`
+
+	Authorization string `json:"-"` // Header Authorization de la request
+}
+
+// rpcServer es un Zabbix falso: handle responde cada llamada con un resultado o un error JSON-RPC
+type rpcServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	calls []rpcCall
+}
+
+func newRPCServer(t *testing.T, handle func(call rpcCall) (interface{}, *zabbixError)) *rpcServer {
+	t.Helper()
+	s := &rpcServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call rpcCall
+		if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		call.Authorization = r.Header.Get("Authorization")
+		s.mu.Lock()
+		s.calls = append(s.calls, call)
+		s.mu.Unlock()
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": 1}
+		if result, rpcErr := handle(call); rpcErr != nil {
+			resp["error"] = rpcErr
+		} else {
+			resp["result"] = result
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// methods devuelve los métodos llamados en orden
+func (s *rpcServer) methods() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, len(s.calls))
+	for i, c := range s.calls {
+		out[i] = c.Method
+	}
+	return out
+}
+
+func (s *rpcServer) adapter(opts Options) *ZabbixAdapter {
+	return NewZabbixAdapter(s.URL, "user", "pass", s.Client(), nil, opts)
+}
+
+func TestGetItemHistory(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+		return []map[string]string{
+			{"itemid": "42", "clock": "1717171800", "value": "-20.5"},
+			{"itemid": "42", "clock": "1717171700", "value": "-21"},
+		}, nil
+	})
+	z := srv.adapter(Options{APIToken: "tok"})
+	if err := z.Authenticate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := time.Unix(1717170000, 0), time.Unix(1717172000, 0)
+	points, err := z.GetItemHistory(context.Background(), "42", HistoryFloat, from, to, 10)
+	if err != nil {
+		t.Fatalf("GetItemHistory: %v", err)
+	}
+	if len(points) != 2 || points[0].Value != -20.5 || points[1].Value != -21 || !points[0].Clock.Equal(time.Unix(1717171800, 0)) {
+		t.Errorf("puntos = %+v", points)
+	}
+
+	params := srv.calls[0].Params
+	// JSON decodifica los números como float64
+	if srv.calls[0].Method != "history.get" || params["history"] != float64(HistoryFloat) ||
+		params["time_from"] != float64(from.Unix()) || params["time_till"] != float64(to.Unix()) || params["limit"] != float64(10) {
+		t.Errorf("request inesperada: %s %v", srv.calls[0].Method, params)
+	}
+}
+
+func TestGetItemHistoryTextValues(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+		return []map[string]string{{"clock": "1717171800", "value": "Online"}}, nil
+	})
+	z := srv.adapter(Options{APIToken: "tok"})
+	_ = z.Authenticate(context.Background())
+
+	points, err := z.GetItemHistory(context.Background(), "7", HistoryText, time.Unix(0, 0), time.Now(), 0)
+	if err != nil {
+		t.Fatalf("GetItemHistory: %v", err)
+	}
+	if len(points) != 1 || points[0].Raw != "Online" || points[0].Value != 0 {
+		t.Errorf("puntos = %+v", points)
+	}
+	if _, ok := srv.calls[0].Params["limit"]; ok {
+		t.Error("limit <= 0 no debería enviarse")
+	}
+
+	// Con el tipo equivocado el valor no es numérico: se reporta en lugar de devolver ceros
+	if _, err := z.GetItemHistory(context.Background(), "7", HistoryFloat, time.Unix(0, 0), time.Now(), 0); err == nil {
+		t.Error("se esperaba error al pedir historia numérica de un item de texto")
+	}
+}
+
+// loginServer acepta user.login y responde los demás métodos con una lista vacía
+func loginServer(t *testing.T) *rpcServer {
+	return newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+		if call.Method == "user.login" {
+			return "session-token", nil
+		}
+		return []interface{}{}, nil
+	})
+}
+
+func TestAuthenticateWithAPIToken(t *testing.T) {
+	srv := loginServer(t)
+	z := srv.adapter(Options{APIToken: "api-token"})
+
+	if err := z.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if n := len(srv.methods()); n != 0 {
+		t.Errorf("con token de API no debería haber login (requests: %v)", srv.methods())
+	}
+	if err := z.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if got := srv.calls[0]; got.Method != "host.get" || got.Auth != "api-token" {
+		t.Errorf("request = %s con auth %q, se esperaba host.get con el token de API", got.Method, got.Auth)
+	}
+}
+
+func TestAuthenticateWithUserAndPassword(t *testing.T) {
+	srv := loginServer(t)
+	z := srv.adapter(Options{})
+
+	if err := z.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	login, next := srv.calls[0], srv.calls[1]
+	if login.Method != "user.login" || login.Auth != "" || login.Params["username"] != "user" || login.Params["password"] != "pass" {
+		t.Errorf("login inesperado: %+v", login)
+	}
+	if next.Method != "host.get" || next.Auth != "session-token" {
+		t.Errorf("request = %s con auth %q, se esperaba el token de la sesión", next.Method, next.Auth)
+	}
+}
+
+func TestAuthModeSendsToken(t *testing.T) {
+	tests := []struct {
+		mode       string
+		wantAuth   string
+		wantHeader string
+	}{
+		{"", "api-token", ""},
+		{AuthModeField, "api-token", ""},
+		{AuthModeHeader, "", "Bearer api-token"},
+	}
+	for _, tt := range tests {
+		t.Run("modo "+tt.mode, func(t *testing.T) {
+			srv := loginServer(t)
+			z := srv.adapter(Options{APIToken: "api-token", AuthMode: tt.mode})
+
+			if err := z.Ping(context.Background()); err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+			got := srv.calls[0]
+			if got.Auth != tt.wantAuth || got.Authorization != tt.wantHeader {
+				t.Errorf("auth = %q, Authorization = %q; se esperaba %q y %q", got.Auth, got.Authorization, tt.wantAuth, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestAuthModeHeaderLoginHasNoToken(t *testing.T) {
+	srv := loginServer(t)
+	z := srv.adapter(Options{AuthMode: AuthModeHeader})
+
+	if err := z.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if login := srv.calls[0]; login.Authorization != "" {
+		t.Errorf("user.login no debería llevar Authorization (valor: %q)", login.Authorization)
+	}
+	if next := srv.calls[1]; next.Authorization != "Bearer session-token" || next.Auth != "" {
+		t.Errorf("host.get: Authorization = %q, auth = %q; se esperaba el token de la sesión en el header", next.Authorization, next.Auth)
+	}
+}
+
+func TestFindJSONPowerDividesTenths(t *testing.T) {
+	items := []zabbixItem{
+		{Key: "rx power:1/5", LastValue: "-18.4"},
+		{Key: "ms_item_ont_rx_power[0/1]", LastValue: `[{"interface":"1/5","indice":"7","value":"-158"},{"interface":"1/6","value":"0"}]`},
+	}
+	rx, ok := findJSONPower(items, "1/5", DefaultPowerFormat())
+	if !ok || rx != "-15.8 dBm" {
+		t.Errorf("findJSONPower = %q, %v; se esperaba -15.8 dBm", rx, ok)
+	}
+	// 0 es sin señal: no se devuelve
+	if rx, ok := findJSONPower(items, "1/6", DefaultPowerFormat()); ok {
+		t.Errorf("findJSONPower = %q, no se esperaba valor para una ONT sin señal", rx)
+	}
+}
+
+func TestCallMethodDecodesResult(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+		return []map[string]string{{"hostid": "10084", "host": "OLT-A"}}, nil
+	})
+	z := srv.adapter(Options{APIToken: "api-token"})
+	_ = z.Authenticate(context.Background())
+
+	var hosts []struct {
+		HostID string `json:"hostid"`
+		Host   string `json:"host"`
+	}
+	params := map[string]interface{}{"output": []string{"hostid", "host"}}
+	if err := z.callMethod(context.Background(), "host.get", params, &hosts); err != nil {
+		t.Fatalf("callMethod: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].HostID != "10084" || hosts[0].Host != "OLT-A" {
+		t.Errorf("hosts = %+v", hosts)
+	}
+	// El sobre JSON-RPC lo arma callMethod: método, parámetros y token
+	if got := srv.calls[0]; got.Method != "host.get" || got.Auth != "api-token" || got.Params["output"] == nil {
+		t.Errorf("request inesperada: %+v", got)
+	}
+
+	// Sin out el resultado se descarta
+	if err := z.callMethod(context.Background(), "host.get", params, nil); err != nil {
+		t.Errorf("callMethod sin out: %v", err)
+	}
+}
+
+func TestCallMethodReturnsAPIError(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+		return nil, &zabbixError{Code: -32602, Message: "Invalid params.", Data: `Incorrect method "host.gett".`}
+	})
+	z := srv.adapter(Options{APIToken: "api-token"})
+	_ = z.Authenticate(context.Background())
+
+	var out []interface{}
+	err := z.callMethod(context.Background(), "host.gett", map[string]interface{}{}, &out)
+	var zErr *zabbixError
+	if !errors.As(err, &zErr) {
+		t.Fatalf("error = %v, se esperaba un *zabbixError", err)
+	}
+	if zErr.Code != -32602 || !strings.Contains(err.Error(), "Invalid params.") || !strings.Contains(err.Error(), "host.gett") {
+		t.Errorf("error = %v, se esperaban el código, el mensaje y el detalle de Zabbix", err)
+	}
+	// Un error de parámetros no es una sesión vencida: no se reintenta
+	if n := len(srv.methods()); n != 1 {
+		t.Errorf("requests = %v, se esperaba una sola", srv.methods())
+	}
+}
+
+func TestCallMethodRejectsNonJSONRPC(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+	}))
+	defer srv.Close()
+	z := NewZabbixAdapter(srv.URL, "user", "pass", srv.Client(), nil, Options{APIToken: "api-token"})
+	_ = z.Authenticate(context.Background())
+
+	err := z.callMethod(context.Background(), "host.get", map[string]interface{}{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 502") || !strings.Contains(err.Error(), "Bad Gateway") {
+		t.Errorf("error = %v, se esperaba el status y el comienzo del cuerpo", err)
+	}
+}
+
+// problemServer tiene un solo host (OLT-A, hostid 10084) con los problemas dados
+func problemServer(t *testing.T, problems []map[string]string) *rpcServer {
+	return newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+		switch call.Method {
+		case "host.get":
+			filter, _ := call.Params["filter"].(map[string]interface{})
+			if hosts, _ := filter["host"].([]interface{}); len(hosts) == 1 && hosts[0] == "OLT-A" {
+				return []map[string]string{{"hostid": "10084"}}, nil
+			}
+			return []interface{}{}, nil
+		case "problem.get":
+			return problems, nil
+		}
+		return nil, &zabbixError{Code: -32601, Message: "Method not found."}
+	})
+}
+
+func TestGetActiveProblems(t *testing.T) {
+	srv := problemServer(t, []map[string]string{
+		{"eventid": "1", "name": "OLT-A: puerto 0/1 caído", "severity": "4"},
+		{"eventid": "2", "name": "OLT-A: temperatura alta", "severity": "2"},
+	})
+	z := srv.adapter(Options{APIToken: "api-token"})
+	_ = z.Authenticate(context.Background())
+
+	problems, err := z.GetActiveProblems(context.Background(), "OLT-A")
+	if err != nil {
+		t.Fatalf("GetActiveProblems: %v", err)
+	}
+	if len(problems) != 2 || problems[0].Severity != 4 || problems[0].Description != "OLT-A: puerto 0/1 caído" || problems[1].Severity != 2 {
+		t.Errorf("problemas = %+v", problems)
+	}
+	if got := srv.calls[1]; got.Method != "problem.get" || got.Params["hostids"].([]interface{})[0] != "10084" {
+		t.Errorf("request inesperada: %+v", got)
+	}
+
+	// Una sola consulta por host en cada ciclo
+	if _, err := z.GetActiveProblems(context.Background(), "OLT-A"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.methods()); n != 2 {
+		t.Errorf("requests = %v, se esperaba reutilizar la primera consulta", srv.methods())
+	}
+
+	// Un host que no existe no tiene problemas ni llega a problem.get
+	problems, err = z.GetActiveProblems(context.Background(), "OLT-X")
+	if err != nil || len(problems) != 0 {
+		t.Errorf("problemas = %+v, error = %v; se esperaba una lista vacía", problems, err)
+	}
+	if methods := srv.methods(); methods[len(methods)-1] != "host.get" {
+		t.Errorf("requests = %v, no se esperaba problem.get para un host inexistente", methods)
+	}
+}
+
+// defaultKeys son las plantillas de keys por defecto (gpon_{second}_status y rx power:{second}/{third})
+func defaultKeys(t *testing.T) KeyTemplates {
+	t.Helper()
+	status, err := core.ParseKeyTemplate(core.DefaultStatusKeyTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	power, err := core.ParseKeyTemplate(core.DefaultPowerKeyTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return KeyTemplates{Status: status, Power: power}
+}
+
+func TestPowerQueryFiltersByTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		wantTags []interface{} // nil = consulta sin filtro
+	}{
+		{"", nil},
+		{"component", []interface{}{map[string]interface{}{"tag": "component", "value": "", "operator": float64(0)}}},
+		{"component:rx-power", []interface{}{map[string]interface{}{"tag": "component", "value": "rx-power", "operator": float64(1)}}},
+	}
+	for _, tt := range tests {
+		t.Run("tag "+tt.tag, func(t *testing.T) {
+			srv := newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+				return []zabbixItem{{Key: "rx power:2/3", LastValue: "-20.5"}}, nil
+			})
+			z := srv.adapter(Options{APIToken: "api-token", PowerTag: tt.tag, Keys: defaultKeys(t)})
+			_ = z.Authenticate(context.Background())
+
+			info, err := z.GetOpticalDetails(context.Background(), "OLT-A", "1/2/3")
+			if err != nil || !info.PowerFound {
+				t.Fatalf("GetOpticalDetails = %+v, %v", info, err)
+			}
+			status, power := srv.calls[0].Params, srv.calls[1].Params
+			// El filtro solo aplica a la consulta de potencia, no a la del status
+			if _, ok := status["tags"]; ok {
+				t.Errorf("la consulta de status no debería filtrar por tag: %v", status)
+			}
+			tags, ok := power["tags"]
+			if tt.wantTags == nil {
+				if ok {
+					t.Errorf("sin ZABBIX_POWER_TAG no se esperaba filtro (tags: %v)", tags)
+				}
+				return
+			}
+			gotJSON, _ := json.Marshal(tags)
+			wantJSON, _ := json.Marshal(tt.wantTags)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("tags = %s, se esperaba %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+// gzipServer responde item.get con items comprimidos en gzip y registra el Accept-Encoding recibido
+func gzipServer(t *testing.T, acceptEncoding *string) *httptest.Server {
+	items := []zabbixItem{{Key: "gpon_2_status", LastValue: "1"}, {Key: "rx power:2/3", LastValue: "-21.3"}}
+	for i := 0; i < 2000; i++ {
+		items = append(items, zabbixItem{Key: fmt.Sprintf("ifInOctets[%d]", i), LastValue: "0"})
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		_ = json.NewEncoder(gz).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": items})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGzipResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		transport  *http.Transport
+		wantHeader string
+	}{
+		// El transport pide gzip y descomprime solo: no hay que definir Accept-Encoding a mano
+		{"pedido por el transport", &http.Transport{}, "gzip"},
+		// Un proxy que comprime aunque no se lo pidan
+		{"sin pedirlo", &http.Transport{DisableCompression: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var acceptEncoding string
+			srv := gzipServer(t, &acceptEncoding)
+			defer tt.transport.CloseIdleConnections()
+			z := NewZabbixAdapter(srv.URL, "user", "pass", &http.Client{Transport: tt.transport}, nil,
+				Options{APIToken: "api-token", Keys: defaultKeys(t)})
+			_ = z.Authenticate(context.Background())
+
+			info, err := z.GetOpticalDetails(context.Background(), "OLT-A", "1/2/3")
+			if err != nil {
+				t.Fatalf("GetOpticalDetails: %v", err)
+			}
+			if !info.StatusFound || !info.PowerFound || info.RxPower != "-21.3 dBm" {
+				t.Errorf("info = %+v, se esperaban los valores de la respuesta comprimida", info)
+			}
+			if acceptEncoding != tt.wantHeader {
+				t.Errorf("Accept-Encoding = %q, se esperaba %q", acceptEncoding, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestDecodeResponseStreamsLists(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		wantErr string
+	}{
+		{"lista", `{"jsonrpc":"2.0","result":[{"key_":"a"},{"key_":"b"}],"id":1}`, 2, ""},
+		{"lista vacía", `{"jsonrpc":"2.0","result":[],"id":1}`, 0, ""},
+		{"result null", `{"jsonrpc":"2.0","result":null,"id":1}`, 0, ""},
+		{"error antes del result", `{"jsonrpc":"2.0","error":{"code":-32500,"message":"Application error."},"id":1}`, 0, "Application error."},
+		{"objeto en lugar de lista", `{"jsonrpc":"2.0","result":{"key_":"a"},"id":1}`, 0, "error parseando la respuesta de item.get"},
+		{"elemento con tipo equivocado", `{"jsonrpc":"2.0","result":[{"key_":1}],"id":1}`, 0, "error parseando la respuesta de item.get"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			z := NewZabbixAdapter(srv.URL, "user", "pass", srv.Client(), nil, Options{APIToken: "api-token"})
+			_ = z.Authenticate(context.Background())
+
+			var items []zabbixItem
+			err := z.callMethod(context.Background(), "item.get", map[string]interface{}{}, &items)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, se esperaba %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(items) != tt.want {
+				t.Errorf("items = %+v, error = %v; se esperaban %d items", items, err, tt.want)
+			}
+		})
+	}
+}
+
+// largeItemList es la respuesta de item.get de una OLT grande (varios MB)
+func largeItemList(b *testing.B) []byte {
+	b.Helper()
+	items := make([]zabbixItem, 50000)
+	for i := range items {
+		items[i] = zabbixItem{ItemID: fmt.Sprint(i), Key: fmt.Sprintf("rx power:%d/%d", i/128, i%128), LastValue: "-21.3", LastClock: "1717171717"}
+	}
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": items})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return body
+}
+
+// BenchmarkItemListStreaming mide doRequest, que decodifica "result" directo desde el cuerpo.
+// Comparar B/op con BenchmarkItemListReadAll (go test -bench ItemList -benchmem).
+func BenchmarkItemListStreaming(b *testing.B) {
+	body := largeItemList(b)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+	z := NewZabbixAdapter(srv.URL, "user", "pass", srv.Client(), nil, Options{APIToken: "api-token"})
+	_ = z.Authenticate(context.Background())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var items []zabbixItem
+		if err := z.callMethod(context.Background(), "item.get", map[string]interface{}{}, &items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkItemListReadAll es el camino anterior: el cuerpo entero en memoria y después json.Unmarshal
+func BenchmarkItemListReadAll(b *testing.B) {
+	body := largeItemList(b)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+	client := srv.Client()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{}`))
+		if err != nil {
+			b.Fatal(err)
+		}
+		raw, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			b.Fatal(err)
+		}
+		var zResp struct {
+			Result []zabbixItem `json:"result"`
+		}
+		if err := json.Unmarshal(raw, &zResp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGetOpticalDetailsDiscardsStaleValues(t *testing.T) {
+	now := time.Now()
+	fresh := fmt.Sprint(now.Add(-2 * time.Minute).Unix())
+	old := fmt.Sprint(now.Add(-7 * 24 * time.Hour).Unix())
+
+	tests := []struct {
+		name            string
+		maxAge          time.Duration
+		statusClock     string
+		powerClock      string
+		wantStatus      bool
+		wantPower       bool
+		wantStatusStale bool
+		wantPowerStale  bool
+	}{
+		{"valores recientes", time.Hour, fresh, fresh, true, true, false, false},
+		{"rx power de hace una semana", time.Hour, fresh, old, true, false, false, true},
+		{"status de hace una semana", time.Hour, old, fresh, false, true, true, false},
+		{"nunca recolectado", time.Hour, "0", "0", false, false, true, true},
+		{"sin ZABBIX_MAX_VALUE_AGE", 0, old, old, true, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+				if filter, ok := call.Params["filter"].(map[string]interface{}); ok && filter["key_"] == "gpon_2_status" {
+					return []zabbixItem{{Key: "gpon_2_status", LastValue: "1", LastClock: tt.statusClock}}, nil
+				}
+				return []zabbixItem{{Key: "rx power:2/3", LastValue: "-20.5", LastClock: tt.powerClock}}, nil
+			})
+			z := srv.adapter(Options{APIToken: "api-token", Keys: defaultKeys(t), MaxValueAge: tt.maxAge})
+			_ = z.Authenticate(context.Background())
+
+			info, err := z.GetOpticalDetails(context.Background(), "OLT-A", "1/2/3")
+			if err != nil {
+				t.Fatalf("GetOpticalDetails: %v", err)
+			}
+			if info.StatusFound != tt.wantStatus || info.StatusStale != tt.wantStatusStale {
+				t.Errorf("status encontrado = %v (vencido: %v), se esperaba %v (%v)", info.StatusFound, info.StatusStale, tt.wantStatus, tt.wantStatusStale)
+			}
+			if info.PowerFound != tt.wantPower || info.PowerStale != tt.wantPowerStale {
+				t.Errorf("rx power encontrado = %v (vencido: %v), se esperaba %v (%v)", info.PowerFound, info.PowerStale, tt.wantPower, tt.wantPowerStale)
+			}
+			// Un valor vencido no se devuelve como si fuera actual
+			if tt.wantPowerStale && info.RxPower != "" {
+				t.Errorf("RxPower = %q, se esperaba vacío", info.RxPower)
+			}
+			// lastclock se pide en ambas consultas
+			for _, call := range srv.calls {
+				if output, _ := json.Marshal(call.Params["output"]); !strings.Contains(string(output), "lastclock") {
+					t.Errorf("%s sin lastclock en output: %s", call.Method, output)
+				}
+			}
+		})
+	}
+}
+
+// expiringServer emite session-1, session-2... en cada user.login y rechaza la primera sesión
+// a partir de expireAfter requests con ella, como Zabbix cuando la sesión vence a mitad del ciclo
+func expiringServer(t *testing.T, expireAfter int) *rpcServer {
+	var mu sync.Mutex
+	logins, used := 0, 0
+	return newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+		mu.Lock()
+		defer mu.Unlock()
+		if call.Method == "user.login" {
+			logins++
+			return fmt.Sprintf("session-%d", logins), nil
+		}
+		if call.Auth == "session-1" {
+			if used++; used > expireAfter {
+				return nil, &zabbixError{Code: -32602, Message: "Invalid params.", Data: "Session terminated, re-login, please."}
+			}
+		}
+		return []interface{}{}, nil
+	})
+}
+
+// count cuenta las llamadas a method
+func (s *rpcServer) count(method string) int {
+	n := 0
+	for _, m := range s.methods() {
+		if m == method {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSessionExpiryReloginsOnce(t *testing.T) {
+	srv := expiringServer(t, 1)
+	z := srv.adapter(Options{})
+	if err := z.Authenticate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		// La segunda vez la sesión vence: se reautentica una vez y se reintenta la misma request
+		if err := z.callMethod(context.Background(), "item.get", map[string]interface{}{}, nil); err != nil {
+			t.Fatalf("item.get %d: %v", i+1, err)
+		}
+	}
+	want := []string{"user.login", "item.get", "item.get", "user.login", "item.get"}
+	if got := srv.methods(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("requests = %v, se esperaba %v", got, want)
+	}
+	if last := srv.calls[len(srv.calls)-1]; last.Auth != "session-2" {
+		t.Errorf("el reintento usó %q, se esperaba la sesión nueva", last.Auth)
+	}
+}
+
+func TestSessionExpiryConcurrentWorkersReloginOnce(t *testing.T) {
+	srv := expiringServer(t, 0)
+	z := srv.adapter(Options{})
+	if err := z.Authenticate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Todos los workers ven el vencimiento a la vez: un solo user.login
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- z.callMethod(context.Background(), "host.get", map[string]interface{}{}, nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("callMethod: %v", err)
+		}
+	}
+	if n := srv.count("user.login"); n != 2 {
+		t.Errorf("user.login = %d, se esperaban 2 (el inicial y una sola reautenticación)", n)
+	}
+}
+
+func TestSessionExpiryRetriesOnlyOnce(t *testing.T) {
+	// Zabbix rechaza todas las sesiones: el reintento falla y no se vuelve a intentar
+	srv := newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+		if call.Method == "user.login" {
+			return "session", nil
+		}
+		return nil, &zabbixError{Code: -32602, Message: "Invalid params.", Data: "Not authorised."}
+	})
+	z := srv.adapter(Options{})
+
+	if err := z.Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "Not authorised.") {
+		t.Errorf("error = %v, se esperaba el rechazo de Zabbix", err)
+	}
+	if got := srv.count("host.get"); got != 2 {
+		t.Errorf("host.get = %d, se esperaban 2 (la request y un solo reintento)", got)
+	}
+
+	// Con token de API no hay login que lo renueve: el error se devuelve sin reintentar
+	srv = newRPCServer(t, func(call rpcCall) (interface{}, *zabbixError) {
+		return nil, &zabbixError{Code: -32602, Message: "Invalid params.", Data: "Not authorised."}
+	})
+	z = srv.adapter(Options{APIToken: "api-token"})
+	_ = z.Authenticate(context.Background())
+	if err := z.Ping(context.Background()); err == nil {
+		t.Error("se esperaba error con un token de API rechazado")
+	}
+	if got := srv.methods(); len(got) != 1 {
+		t.Errorf("requests = %v, se esperaba una sola sin login", got)
+	}
+}
+
+func TestFindJSONPowerIsDeterministic(t *testing.T) {
+	// Dos campos numéricos para la misma ONT: se usa siempre el primero en orden de clave (rx)
+	items := []zabbixItem{{Key: "ms_item_ont_rx_power[0/1]", LastValue: `[{"interface":"1/5","tx":"25","rx":"-158","indice":"7"}]`}}
+	for i := 0; i < 20; i++ {
+		if rx, ok := findJSONPower(items, "1/5", DefaultPowerFormat()); !ok || rx != "-15.8 dBm" {
+			t.Fatalf("intento %d: findJSONPower = %q, %v; se esperaba -15.8 dBm", i, rx, ok)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package zabbix
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PowerFormat define cómo se escribe el rx power leído de Zabbix
+type PowerFormat struct {
+	// Unidad que se agrega después del número (ej: "dBm"); vacío = solo el número
+	Unit string
+	// Decimales del valor. -1 = el valor de la key exacta tal cual lo guarda Zabbix
+	// y 1 decimal para ms_item_ont_rx_power (el comportamiento original)
+	Precision int
+	// Un valor 0 se considera sin señal: no se devuelve y se sigue buscando en ms_item_ont_rx_power
+	ZeroAsEmpty bool
+}
+
+// DefaultPowerFormat es el formato original: "-20.4 dBm", con los ceros tratados como sin señal
+func DefaultPowerFormat() PowerFormat {
+	return PowerFormat{Unit: "dBm", Precision: -1, ZeroAsEmpty: true}
+}
+
+// formatExact formatea el lastvalue de la key exacta. ok es false si no hay valor utilizable.
+func (f PowerFormat) formatExact(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err == nil && v == 0 && f.ZeroAsEmpty {
+		return "", false
+	}
+	if err != nil || f.Precision < 0 {
+		return f.withUnit(raw), true
+	}
+	return f.withUnit(strconv.FormatFloat(v, 'f', f.Precision, 64)), true
+}
+
+// formatDBm formatea un valor ya convertido a dBm (ms_item_ont_rx_power). ok es false si no hay valor utilizable.
+func (f PowerFormat) formatDBm(v float64) (string, bool) {
+	if v == 0 && f.ZeroAsEmpty {
+		return "", false
+	}
+	precision := f.Precision
+	if precision < 0 {
+		precision = 1
+	}
+	return f.withUnit(strconv.FormatFloat(v, 'f', precision, 64)), true
+}
+
+func (f PowerFormat) withUnit(value string) string {
+	if f.Unit == "" {
+		return value
+	}
+	return value + " " + f.Unit
+}
@@ -0,0 +1,54 @@
+package zabbix
+
+import "testing"
+
+func TestPowerFormatExact(t *testing.T) {
+	tests := []struct {
+		name   string
+		format PowerFormat
+		raw    string
+		want   string
+		ok     bool
+	}{
+		{"por defecto, valor tal cual", DefaultPowerFormat(), "-20.43", "-20.43 dBm", true},
+		{"por defecto, cero es sin señal", DefaultPowerFormat(), "0", "", false},
+		{"vacío", DefaultPowerFormat(), "  ", "", false},
+		{"sin unidad", PowerFormat{Precision: -1, ZeroAsEmpty: true}, "-20.43", "-20.43", true},
+		{"otra unidad", PowerFormat{Unit: "dB", Precision: -1}, "-20.43", "-20.43 dB", true},
+		{"precisión 1", PowerFormat{Unit: "dBm", Precision: 1}, "-20.46", "-20.5 dBm", true},
+		{"precisión 0", PowerFormat{Precision: 0}, "-20.46", "-20", true},
+		{"cero como valor", PowerFormat{Unit: "dBm", Precision: 2}, "0", "0.00 dBm", true},
+		{"no numérico con precisión", PowerFormat{Precision: 2}, "LOS", "LOS", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.format.formatExact(tt.raw)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("formatExact(%q) = %q, %v; se esperaba %q, %v", tt.raw, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPowerFormatDBm(t *testing.T) {
+	tests := []struct {
+		name   string
+		format PowerFormat
+		v      float64
+		want   string
+		ok     bool
+	}{
+		{"por defecto, 1 decimal", DefaultPowerFormat(), -20.46, "-20.5 dBm", true},
+		{"por defecto, cero es sin señal", DefaultPowerFormat(), 0, "", false},
+		{"precisión 3 sin unidad", PowerFormat{Precision: 3}, -20.46, "-20.460", true},
+		{"cero como valor", PowerFormat{Unit: "dBm", Precision: -1}, 0, "0.0 dBm", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.format.formatDBm(tt.v)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("formatDBm(%v) = %q, %v; se esperaba %q, %v", tt.v, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
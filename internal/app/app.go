@@ -0,0 +1,698 @@
+// aqui orquestamos un ciclo completo de sincronización (antes era el closure runProcess de main)
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gpon-sync/internal/core"
+	"gpon-sync/internal/redact"
+	"gpon-sync/internal/report"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRunInProgress indica que se pidió un ciclo mientras otro seguía en curso
+var ErrRunInProgress = errors.New("ya hay un ciclo de sincronización en curso")
+
+// ErrRunTimeout indica que el ciclo superó MaxRunDuration y se canceló
+var ErrRunTimeout = errors.New("el ciclo superó la duración máxima")
+
+// ZabbixAuthenticator es la parte del cliente de Zabbix que necesita el ciclo (reautenticar en cada ejecución)
+type ZabbixAuthenticator interface {
+	Authenticate(ctx context.Context) error
+}
+
+// RunCache es una cache que se reinicia al comenzar cada ciclo (ej: búsquedas de Notion por CID)
+// y cuyos aciertos se reportan en el RunSummary. Una cache de vida más larga
+// (ej: custom fields de Ubersmith) solo reinicia sus contadores.
+type RunCache interface {
+	ResetCache()
+	CacheStats() (hits, misses int)
+}
+
+// DeadLetter lleva la cuenta de fallos consecutivos por circuito (ver deadletter.Store).
+// Los circuitos en dead-letter se omiten en los ciclos normales.
+type DeadLetter interface {
+	IsDead(cid string) bool
+	Record(cid string, err error)
+	Save() error
+}
+
+// CacheStats son los aciertos y fallos de una RunCache en un ciclo
+type CacheStats struct {
+	Hits   int
+	Misses int
+}
+
+// Options contiene los ajustes de un ciclo de sincronización
+type Options struct {
+	BatchSize int  // Cantidad de resultados por escritura en la DB
+	DryRun    bool // Si es true, no se escribe en la DB
+
+	// Ruta del reporte de dry-run (.csv o .json). Si está vacía solo se imprime un resumen.
+	DryRunOutput string
+
+	// Tiempo que se siguen esperando los circuitos en curso después de un apagado.
+	// Al vencer se deja de leer resultados y se guarda lo recibido. 0 = esperar a que terminen todos.
+	DrainTimeout time.Duration
+
+	// Filtro de circuitos. Con Allowlist solo se procesan esos CIDs;
+	// los CIDs de Denylist se omiten siempre (aunque estén en la Allowlist).
+	Allowlist []string
+	Denylist  []string
+
+	// Campos que se comparan contra la DB para escribir solo los circuitos que cambiaron.
+	// Si está vacío se escriben todos.
+	ChangeFields []string
+
+	// El repositorio inserta los circuitos sin fila (INSERT_MISSING). Lo hace el repositorio:
+	// aquí solo define la acción que muestra el reporte de dry-run.
+	InsertMissing bool
+
+	// Procesar solo los circuitos en dead-letter (en lugar de omitirlos)
+	RetryDeadLetter bool
+
+	// Cada cuántos circuitos se loguea el progreso del ciclo (0 = nunca)
+	ProgressEvery int
+
+	// Loguear el detalle de cada circuito exitoso (los errores se loguean siempre)
+	Verbose bool
+
+	// Loguear en una línea cada circuito que no se pudo enriquecer por completo, con sus categorías
+	LogCoverage bool
+
+	// Circuitos que se envían primero al worker pool: por CID, o por la OLT que tuvieron en
+	// ciclos anteriores (ver prioritize)
+	PriorityCIDs []string
+	PriorityOLTs []string
+
+	// Duración máxima de un ciclo: al vencer se cancela como en un apagado
+	// (se guarda lo procesado y el scheduler sigue). 0 = sin límite.
+	MaxRunDuration time.Duration
+}
+
+// App agrupa las dependencias necesarias para ejecutar un ciclo de sincronización
+type App struct {
+	repo   core.CircuitRepository
+	pool   *core.WorkerPool
+	zabbix ZabbixAuthenticator
+	opts   Options
+	caches map[string]RunCache
+	dead   DeadLetter // nil = sin seguimiento de fallos
+	audit  AuditSink  // nil = sin auditoría de escrituras
+
+	sink     core.ResultSink // nil = sin publicación de resultados
+	sinkOnly bool
+
+	// OLT de cada CID según el último resultado, para PriorityOLTs
+	oltMu    sync.Mutex
+	knownOLT map[string]string
+
+	// Guarda contra ciclos concurrentes: dos ciclos completos duplicarían la carga
+	// sobre las APIs y competirían al escribir los mismos circuitos
+	running atomic.Bool
+}
+
+func NewApp(repo core.CircuitRepository, pool *core.WorkerPool, zabbix ZabbixAuthenticator, opts Options) *App {
+	return &App{
+		repo:   repo,
+		pool:   pool,
+		zabbix: zabbix,
+		opts:   opts,
+	}
+}
+
+// AddRunCache registra una cache por ciclo con el nombre con que aparece en el resumen
+func (a *App) AddRunCache(name string, c RunCache) {
+	if a.caches == nil {
+		a.caches = make(map[string]RunCache)
+	}
+	a.caches[name] = c
+}
+
+// SetDeadLetter habilita el seguimiento de circuitos que fallan en todos los ciclos
+func (a *App) SetDeadLetter(d DeadLetter) {
+	a.dead = d
+}
+
+// SetAudit habilita la auditoría de cada circuito escrito (ver audit.Logger)
+func (a *App) SetAudit(s AuditSink) {
+	a.audit = s
+}
+
+// SetSink habilita la publicación de cada resultado en un consumidor externo,
+// después de escribir en la DB o, si only es true, en lugar de escribir
+func (a *App) SetSink(s core.ResultSink, only bool) {
+	a.sink = s
+	a.sinkOnly = only
+}
+
+// newWriter crea el BatchWriter de un ciclo con la auditoría y el sink configurados
+func (a *App) newWriter(size int) *BatchWriter {
+	writer := NewBatchWriter(a.repo, size, a.opts.DryRun)
+	writer.SetInsertMissing(a.opts.InsertMissing)
+	if a.audit != nil {
+		writer.SetAudit(a.audit)
+	}
+	if a.sink != nil {
+		writer.SetSink(a.sink, a.sinkOnly)
+	}
+	return writer
+}
+
+// resetCaches vacía las caches al comenzar un ciclo
+func (a *App) resetCaches() {
+	for _, c := range a.caches {
+		c.ResetCache()
+	}
+}
+
+// recordCaches copia al resumen los aciertos y fallos de cada cache
+func (a *App) recordCaches(summary *RunSummary) {
+	for name, c := range a.caches {
+		hits, misses := c.CacheStats()
+		summary.Caches[name] = CacheStats{Hits: hits, Misses: misses}
+	}
+}
+
+// RunSummary es el resultado de un ciclo de sincronización
+type RunSummary struct {
+	Total         int // Circuitos enviados al worker pool (después de los filtros)
+	Processed     int
+	Success       int
+	Errors        int
+	WriteFailures int  // Batches que no se pudieron guardar
+	Unmatched     int  // Circuitos sin fila en la DB (sus datos no se guardaron)
+	Denied        int  // Circuitos omitidos por CIRCUIT_DENYLIST
+	DeadLettered  int  // Circuitos omitidos por estar en dead-letter
+	Changed       int  // Circuitos escritos por tener cambios (solo con detección de cambios)
+	Unchanged     int  // Circuitos sin cambios que no se escribieron
+	Published     int  // Resultados publicados en el sink externo
+	PublishFailed int  // Resultados que no se pudieron publicar (no afectan la escritura en la DB)
+	Interrupted   bool // El ciclo se cortó por apagado: no se procesaron todos los circuitos
+
+	// Métricas del worker pool (duración, latencia por etapa). nil si no se procesaron circuitos.
+	Stats *core.RunStats
+
+	// Aciertos y fallos de las caches por ciclo, por nombre (ej: "notion")
+	Caches map[string]CacheStats
+
+	// Conteo por fuente: errores que impidieron completar el circuito y advertencias parciales
+	ErrorsBySource   map[core.Stage]int
+	WarningsBySource map[core.Stage]int
+
+	// Cobertura de datos: circuitos por categoría (un circuito puede contar en varias, ver core.ClassifyCoverage)
+	Coverage map[core.Coverage]int
+}
+
+// HasErrors indica si el ciclo terminó con errores por circuito o fallos de escritura
+func (s RunSummary) HasErrors() bool {
+	return s.Errors > 0 || s.WriteFailures > 0
+}
+
+func newRunSummary() RunSummary {
+	return RunSummary{
+		ErrorsBySource:   make(map[core.Stage]int),
+		WarningsBySource: make(map[core.Stage]int),
+		Caches:           make(map[string]CacheStats),
+		Coverage:         make(map[core.Coverage]int),
+	}
+}
+
+// record contabiliza un resultado del worker pool
+func (s *RunSummary) record(res core.EnrichedData) {
+	s.Processed++
+	if res.Error != nil {
+		s.Errors++
+		s.ErrorsBySource[core.ErrorStage(res.Error)]++
+	} else {
+		s.Success++
+	}
+	for _, w := range res.Warnings {
+		s.WarningsBySource[core.ErrorStage(w)]++
+	}
+	for _, c := range core.ClassifyCoverage(res) {
+		s.Coverage[c]++
+	}
+}
+
+// acquire marca el inicio de un ciclo; retorna false si ya hay uno en curso
+func (a *App) acquire() bool {
+	return a.running.CompareAndSwap(false, true)
+}
+
+func (a *App) release() {
+	a.running.Store(false)
+}
+
+// start autentica con Zabbix, obtiene los circuitos, aplica el filtro y los envía al worker pool.
+// Retorna un canal nil si no hay circuitos para procesar.
+func (a *App) start(ctx context.Context, summary *RunSummary) (<-chan core.EnrichedData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	a.resetCaches()
+
+	// Autenticación de Zabbix (reautenticar cada vez por si expira el token).
+	// Sin cliente de Zabbix (ENABLE_ZABBIX=false) se omite.
+	if a.zabbix != nil {
+		log.Println("Autenticando con Zabbix...")
+		if err := a.zabbix.Authenticate(ctx); err != nil {
+			return nil, fmt.Errorf("error autenticando con Zabbix: %w", err)
+		}
+		log.Println("✅ Autenticación con Zabbix exitosa")
+	}
+
+	// Obtener circuitos
+	log.Println("Obteniendo circuitos...")
+	circuits, err := a.repo.FetchPendingCircuits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo circuitos: %w", err)
+	}
+
+	circuits, summary.Denied = a.filter(circuits)
+	if summary.Denied > 0 {
+		log.Printf("⛔ %d circuitos omitidos por CIRCUIT_DENYLIST", summary.Denied)
+	}
+
+	circuits, summary.DeadLettered = a.filterDeadLetter(circuits)
+	if summary.DeadLettered > 0 {
+		log.Printf("🪦 %d circuitos omitidos por estar en dead-letter (reprocesar con -retry-deadletter)", summary.DeadLettered)
+	}
+
+	if len(circuits) == 0 {
+		log.Println("⚠️  No hay circuitos pendientes para procesar")
+		return nil, nil
+	}
+
+	circuits = a.prioritize(circuits)
+	a.pool.Prefetch(ctx, circuits)
+
+	summary.Total = len(circuits)
+	log.Printf("Procesando %d circuitos...", len(circuits))
+	resultsCh, stats := a.pool.Run(ctx, circuits)
+	summary.Stats = stats
+	return resultsCh, nil
+}
+
+// filter aplica la allowlist y la denylist. Devuelve los circuitos a procesar
+// y cuántos se omitieron por la denylist.
+func (a *App) filter(circuits []core.Circuit) ([]core.Circuit, int) {
+	if len(a.opts.Allowlist) == 0 && len(a.opts.Denylist) == 0 {
+		return circuits, 0
+	}
+
+	allowed := toSet(a.opts.Allowlist)
+	denied := toSet(a.opts.Denylist)
+
+	kept := circuits[:0:0]
+	deniedCount := 0
+	for _, c := range circuits {
+		if denied[c.CID] {
+			deniedCount++
+			continue
+		}
+		if len(allowed) > 0 && !allowed[c.CID] {
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	if len(allowed) > 0 {
+		log.Printf("🎯 CIRCUIT_ALLOWLIST activa: se procesan %d de %d circuitos", len(kept), len(circuits))
+	}
+	return kept, deniedCount
+}
+
+// filterDeadLetter omite los circuitos en dead-letter, o con RetryDeadLetter deja solo esos.
+// Devuelve los circuitos a procesar y cuántos se omitieron por estar en dead-letter.
+func (a *App) filterDeadLetter(circuits []core.Circuit) ([]core.Circuit, int) {
+	if a.dead == nil {
+		return circuits, 0
+	}
+
+	kept := circuits[:0:0]
+	skipped := 0
+	for _, c := range circuits {
+		if a.dead.IsDead(c.CID) != a.opts.RetryDeadLetter {
+			if !a.opts.RetryDeadLetter {
+				skipped++
+			}
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	if a.opts.RetryDeadLetter {
+		log.Printf("🪦 Reprocesando %d circuitos en dead-letter", len(kept))
+	}
+	return kept, skipped
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// RunOnce ejecuta un ciclo completo: autenticación, obtención de circuitos, enriquecimiento y escritura.
+// Retorna error solo ante fallos fatales del ciclo (autenticación, lectura de la DB);
+// los errores por circuito quedan contabilizados en el RunSummary.
+func (a *App) RunOnce(ctx context.Context) (RunSummary, error) {
+	summary := newRunSummary()
+
+	if !a.acquire() {
+		return summary, ErrRunInProgress
+	}
+	defer a.release()
+
+	// Watchdog: un ciclo trabado (una dependencia que no responde ni falla) no debe bloquear al scheduler
+	if a.opts.MaxRunDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, a.opts.MaxRunDuration, ErrRunTimeout)
+		defer cancel()
+	}
+
+	resultsCh, err := a.start(ctx, &summary)
+	if err != nil || resultsCh == nil {
+		return summary, err
+	}
+
+	stopWatchdog := context.AfterFunc(ctx, func() {
+		if context.Cause(ctx) == ErrRunTimeout {
+			logStuck(a.opts.MaxRunDuration, summary.Stats.InFlight())
+		}
+	})
+	defer stopWatchdog()
+
+	// Acumulador para Batch Update
+	// Las escrituras no heredan la cancelación: tras un apagado el último batch se guarda igual
+	// (el plazo lo pone SHUTDOWN_GRACE, que fuerza la salida)
+	writeCtx := context.WithoutCancel(ctx)
+	writer := a.newWriter(a.opts.BatchSize)
+	writer.SetChangeDetection(a.opts.ChangeFields)
+	if a.opts.DryRun && a.opts.DryRunOutput != "" {
+		rep, err := report.NewFileWriter(a.opts.DryRunOutput)
+		if err != nil {
+			log.Printf("[WARN] %v (se continúa sin reporte)", err)
+		} else {
+			writer.SetReport(rep)
+			defer func() {
+				if err := rep.Close(); err != nil {
+					log.Printf("[ERROR] No se pudo cerrar el reporte de dry-run: %v", err)
+				} else {
+					log.Printf("[DRY-RUN] Reporte escrito en %s", a.opts.DryRunOutput)
+				}
+			}()
+		}
+	}
+
+	// Resultados para el dead-letter: se aplican al final, solo si el ciclo terminó normalmente
+	type outcome struct {
+		cid string
+		err error
+	}
+	var outcomes []outcome
+
+	started := time.Now()
+	handle := func(res core.EnrichedData) {
+		summary.record(res)
+		a.rememberOLT(res)
+
+		// Log detallado para cada instancia (solo en modo verbose; los errores siempre)
+		if res.Error != nil {
+			log.Printf("[ERROR] CID %s: %v", res.CircuitID, res.Error)
+		} else if a.opts.Verbose {
+			log.Printf("[DEBUG] === INSTANCIA %d: CID=%s === OK", summary.Processed, res.CircuitID)
+			log.Printf("[DEBUG] PPPoEUser=%s, StatusGpon=%s, RxPower=%s",
+				redact.Username(res.PPPoEUsername), res.StatusGpon, res.RxPower)
+		}
+		if a.opts.LogCoverage {
+			logCoverage(res)
+		}
+		if a.opts.ProgressEvery > 0 && summary.Processed%a.opts.ProgressEvery == 0 {
+			log.Printf("📈 Progreso: %d/%d circuitos (exitosos: %d, con errores: %d) - %s transcurridos",
+				summary.Processed, summary.Total, summary.Success, summary.Errors, time.Since(started).Round(time.Second))
+		}
+
+		// Solo los errores propios del circuito cuentan para el dead-letter: una caída o
+		// credenciales inválidas fallan para todos y no dicen nada de cada circuito.
+		// En dry-run no se modifica el estado.
+		if a.dead != nil && !a.opts.DryRun && (res.Error == nil || core.IsDataError(res.Error)) {
+			outcomes = append(outcomes, outcome{res.CircuitID, res.Error})
+		}
+
+		writer.Add(writeCtx, res)
+	}
+
+	a.consume(ctx, resultsCh, handle)
+
+	if a.dead != nil && !a.opts.DryRun {
+		// Un ciclo abortado, interrumpido o vencido deja circuitos a medias: no se toca el estado
+		if ctx.Err() != nil || summary.Stats.Aborted() != nil {
+			log.Printf("[WARN] Ciclo incompleto: el dead-letter no se actualiza")
+		} else {
+			for _, o := range outcomes {
+				a.dead.Record(o.cid, o.err)
+			}
+			if err := a.dead.Save(); err != nil {
+				log.Printf("[ERROR] %v", err)
+			}
+		}
+	}
+
+	// Si hubo apagado, el pool dejó de tomar circuitos nuevos:
+	// guardamos lo que se alcanzó a procesar
+	summary.Interrupted = ctx.Err() != nil
+
+	// Guardar remanentes
+	writer.Flush(writeCtx)
+	summary.WriteFailures = writer.Failures()
+	summary.Unmatched = writer.Unmatched()
+	a.recordCaches(&summary)
+	summary.Changed = writer.Changed()
+	summary.Unchanged = writer.Unchanged()
+	summary.Published = writer.Published()
+	summary.PublishFailed = writer.PublishFailures()
+	if a.opts.DryRun {
+		log.Printf("[DRY-RUN] %d circuitos se habrían actualizado (NO se guardó nada)", writer.DryRunCount())
+	}
+
+	// Error fatal durante el enriquecimiento (ej: credenciales de Ubersmith inválidas):
+	// lo procesado ya se guardó, pero el ciclo se reporta como fallido
+	if err := summary.Stats.Aborted(); err != nil {
+		return summary, fmt.Errorf("ciclo detenido: %w", err)
+	}
+	if context.Cause(ctx) == ErrRunTimeout {
+		return summary, fmt.Errorf("%w (%s): se guardaron %d de %d circuitos", ErrRunTimeout, a.opts.MaxRunDuration, summary.Processed, summary.Total)
+	}
+	return summary, nil
+}
+
+// logStuck loguea los circuitos en curso al vencer la duración máxima y la etapa en la que estaban
+func logStuck(limit time.Duration, inFlight map[string]core.Stage) {
+	log.Printf("[ERROR] ⏱️  El ciclo superó MAX_RUN_DURATION (%s): se cancela. %d circuitos en curso", limit, len(inFlight))
+	byStage := make(map[core.Stage][]string)
+	for cid, stage := range inFlight {
+		byStage[stage] = append(byStage[stage], cid)
+	}
+	for _, stage := range []core.Stage{core.StageNotion, core.StageUbersmith, core.StageZabbix} {
+		if cids := byStage[stage]; len(cids) > 0 {
+			sort.Strings(cids)
+			log.Printf("[ERROR]   Esperando a %s: %s", stage, strings.Join(cids, ", "))
+		}
+	}
+}
+
+// consume lee resultados hasta que el canal se cierra. Ante un apagado (ctx cancelado) sigue
+// recibiendo los circuitos en curso como máximo DrainTimeout; los que lleguen después se descartan.
+// Con colas acotadas (QUEUE_BUFFER) un worker se bloquea hasta que su resultado se lee,
+// por eso el canal se vacía siempre hasta que se cierra.
+func (a *App) consume(ctx context.Context, resultsCh <-chan core.EnrichedData, handle func(core.EnrichedData)) {
+	for {
+		select {
+		case res, ok := <-resultsCh:
+			if !ok {
+				return
+			}
+			handle(res)
+		case <-ctx.Done():
+			a.drain(resultsCh, handle)
+			return
+		}
+	}
+}
+
+// drain procesa los resultados que lleguen hasta que el canal se cierre o venza DrainTimeout
+func (a *App) drain(resultsCh <-chan core.EnrichedData, handle func(core.EnrichedData)) {
+	var deadline <-chan time.Time
+	if a.opts.DrainTimeout > 0 {
+		timer := time.NewTimer(a.opts.DrainTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case res, ok := <-resultsCh:
+			if !ok {
+				return
+			}
+			handle(res)
+		case <-deadline:
+			log.Printf("[WARN] Se dejó de esperar circuitos en curso tras %s; se guarda lo recibido", a.opts.DrainTimeout)
+			go discard(resultsCh)
+			return
+		}
+	}
+}
+
+// discard vacía el canal de resultados sin procesarlos, para que los workers que siguen en curso
+// no queden bloqueados al enviar (y los workers persistentes puedan tomar el próximo ciclo)
+func discard(resultsCh <-chan core.EnrichedData) {
+	n := 0
+	for range resultsCh {
+		n++
+	}
+	if n > 0 {
+		log.Printf("[WARN] %d circuitos terminaron después del plazo de apagado y se descartaron", n)
+	}
+}
+
+// RunCircuit ejecuta el pipeline completo para un solo CID, sin consultar la lista de la DB,
+// e imprime el detalle de cada etapa. Respeta DryRun: solo escribe en la DB si no está activo.
+func (a *App) RunCircuit(ctx context.Context, cid string) (RunSummary, error) {
+	summary := newRunSummary()
+
+	if !a.acquire() {
+		return summary, ErrRunInProgress
+	}
+	defer a.release()
+
+	if a.zabbix != nil {
+		log.Println("Autenticando con Zabbix...")
+		if err := a.zabbix.Authenticate(ctx); err != nil {
+			return summary, fmt.Errorf("error autenticando con Zabbix: %w", err)
+		}
+	}
+
+	log.Printf("🔎 Procesando solo el CID %s", cid)
+	writer := a.newWriter(1)
+	resultsCh, stats := a.pool.Run(ctx, []core.Circuit{{CID: cid}})
+	summary.Stats = stats
+	for res := range resultsCh {
+		summary.record(res)
+		traceResult(res)
+		writer.Add(ctx, res)
+	}
+
+	writer.Flush(ctx)
+	summary.WriteFailures = writer.Failures()
+	summary.Unmatched = writer.Unmatched()
+	summary.Published = writer.Published()
+	summary.PublishFailed = writer.PublishFailures()
+	return summary, nil
+}
+
+// traceResult imprime todos los datos obtenidos en cada etapa (Notion → Ubersmith → Zabbix)
+func traceResult(res core.EnrichedData) {
+	log.Printf("[DEBUG] CID %s", res.CircuitID)
+	log.Printf("[DEBUG]   Notion:    OLT=%q ONT=%q", res.OLT, res.ONT)
+	log.Printf("[DEBUG]   Ubersmith: PPPoEUser=%q PPPoEPassword=%s", redact.Username(res.PPPoEUsername), redact.Secret(res.PPPoEPassword))
+	log.Printf("[DEBUG]   Zabbix:    StatusGpon=%q (raw %q) RxPower=%q", res.StatusGpon, res.StatusGponRaw, res.RxPower)
+	for _, w := range res.Warnings {
+		log.Printf("[DEBUG]   Advertencia: %v", w)
+	}
+	if res.Error != nil {
+		log.Printf("[DEBUG]   Error: %v", res.Error)
+	}
+}
+
+// Export ejecuta el enriquecimiento y escribe todos los resultados en un CSV, sin escribir en la DB.
+// La DB solo se lee para obtener la lista de circuitos.
+func (a *App) Export(ctx context.Context, path string) (summary RunSummary, err error) {
+	summary = newRunSummary()
+
+	if !a.acquire() {
+		return summary, ErrRunInProgress
+	}
+	defer a.release()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return summary, fmt.Errorf("no se pudo crear el export %s: %w", path, err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error cerrando el export %s: %w", path, closeErr)
+		}
+	}()
+
+	exp, err := report.NewEnrichedCSV(f)
+	if err != nil {
+		return summary, err
+	}
+
+	// Si falla la escritura se cancela el pool y se vacía el canal como en un apagado:
+	// los workers no deben quedar bloqueados enviando resultados que nadie lee
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resultsCh, err := a.start(ctx, &summary)
+	if err != nil {
+		return summary, err
+	}
+
+	// Sin circuitos el canal es nil: se escribe solo el encabezado
+	var writeErr error
+	a.consume(ctx, orEmpty(resultsCh), func(res core.EnrichedData) {
+		summary.record(res)
+		if writeErr != nil {
+			return
+		}
+		if writeErr = exp.Write(res); writeErr != nil {
+			cancel()
+		}
+	})
+	if writeErr != nil {
+		return summary, fmt.Errorf("error escribiendo el export: %w", writeErr)
+	}
+
+	if err := exp.Flush(); err != nil {
+		return summary, fmt.Errorf("error escribiendo el export: %w", err)
+	}
+	a.recordCaches(&summary)
+	log.Printf("📄 Export escrito en %s (%d circuitos)", path, summary.Processed)
+	return summary, nil
+}
+
+// orEmpty devuelve un canal cerrado si ch es nil, para poder recorrerlo con range sin bloquear
+func orEmpty(ch <-chan core.EnrichedData) <-chan core.EnrichedData {
+	if ch != nil {
+		return ch
+	}
+	closed := make(chan core.EnrichedData)
+	close(closed)
+	return closed
+}
+
+// logCoverage loguea las categorías de un circuito incompleto (LOG_COVERAGE=true), fácil de filtrar con grep
+func logCoverage(res core.EnrichedData) {
+	coverage := core.ClassifyCoverage(res)
+	if len(coverage) == 1 && coverage[0] == core.CoverageFull {
+		return
+	}
+	names := make([]string, len(coverage))
+	for i, c := range coverage {
+		names[i] = string(c)
+	}
+	log.Printf("[COVERAGE] CID %s: %s", res.CircuitID, strings.Join(names, ","))
+}
@@ -0,0 +1,421 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gpon-sync/internal/core"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRepo es un CircuitRepository en memoria que registra lo escrito.
+// Los CIDs de unmatched no tienen fila en la DB.
+type fakeRepo struct {
+	mu        sync.Mutex
+	circuits  []core.Circuit
+	unmatched map[string]bool
+	written   []core.EnrichedData
+}
+
+func (r *fakeRepo) FetchPendingCircuits(ctx context.Context) ([]core.Circuit, error) {
+	return r.circuits, nil
+}
+
+func (r *fakeRepo) UpdateCircuitBatch(ctx context.Context, data []core.EnrichedData) (core.BatchResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result core.BatchResult
+	for _, d := range data {
+		if r.unmatched[d.CircuitID] {
+			result.Unmatched = append(result.Unmatched, d.CircuitID)
+			continue
+		}
+		r.written = append(r.written, d)
+		result.Updated++
+	}
+	return result, nil
+}
+
+func (r *fakeRepo) FetchCurrentValues(ctx context.Context, cids []string) (map[string]core.EnrichedData, error) {
+	return map[string]core.EnrichedData{}, nil
+}
+
+func (r *fakeRepo) writtenCIDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cids := make([]string, len(r.written))
+	for i, d := range r.written {
+		cids[i] = d.CircuitID
+	}
+	return cids
+}
+
+// fakeNotion devuelve una OLT por CID; los CIDs de errs fallan y los de slow no responden
+// hasta que vence el contexto
+type fakeNotion struct {
+	slow map[string]bool
+	errs map[string]error
+}
+
+func (n *fakeNotion) GetNetworkInfo(ctx context.Context, cid string) (core.NetworkInfo, error) {
+	if n.slow[cid] {
+		<-ctx.Done()
+		return core.NetworkInfo{}, ctx.Err()
+	}
+	if err := n.errs[cid]; err != nil {
+		return core.NetworkInfo{}, err
+	}
+	return core.NetworkInfo{OLT: "OLT-" + cid, ONT: "1/2/3"}, nil
+}
+
+type fakeZabbix struct{}
+
+func (fakeZabbix) GetOpticalDetails(ctx context.Context, olt, ont string) (core.OpticalInfo, error) {
+	return core.OpticalInfo{Status: "1", RxPower: "-20.00", StatusFound: true, PowerFound: true}, nil
+}
+
+// fakeUbersmith devuelve credenciales por CID; los CIDs de errs fallan
+type fakeUbersmith struct {
+	errs map[string]error
+}
+
+func (u fakeUbersmith) GetServiceDetails(ctx context.Context, cid string) (string, string, error) {
+	if err := u.errs[cid]; err != nil {
+		return "", "", err
+	}
+	return "user-" + cid, "pass", nil
+}
+
+// fakeAuth es un ZabbixAuthenticator que devuelve err
+type fakeAuth struct {
+	err error
+}
+
+func (a fakeAuth) Authenticate(ctx context.Context) error {
+	return a.err
+}
+
+func circuits(cids ...string) []core.Circuit {
+	out := make([]core.Circuit, len(cids))
+	for i, cid := range cids {
+		out[i] = core.Circuit{ID: i + 1, CID: cid}
+	}
+	return out
+}
+
+func TestRunOnceWatchdogCancelsSlowRun(t *testing.T) {
+	repo := &fakeRepo{circuits: circuits("fast", "slow")}
+	pool := core.NewWorkerPool(2, &fakeNotion{slow: map[string]bool{"slow": true}}, fakeZabbix{}, fakeUbersmith{})
+	app := NewApp(repo, pool, nil, Options{
+		BatchSize:      10,
+		MaxRunDuration: 100 * time.Millisecond,
+		DrainTimeout:   time.Second,
+	})
+
+	done := make(chan struct{})
+	var summary RunSummary
+	var err error
+	go func() {
+		summary, err = app.RunOnce(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunOnce no terminó: el watchdog no canceló la llamada trabada")
+	}
+
+	if !errors.Is(err, ErrRunTimeout) {
+		t.Fatalf("err = %v, se esperaba ErrRunTimeout", err)
+	}
+	// La llamada trabada vence con el plazo del ciclo: su resultado llega antes de DrainTimeout
+	if summary.Processed != 2 || summary.Errors != 1 {
+		t.Errorf("procesados = %d, con errores = %d; se esperaban 2 y 1", summary.Processed, summary.Errors)
+	}
+	if !summary.Interrupted {
+		t.Error("el ciclo debería quedar marcado como interrumpido")
+	}
+	// El batch acumulado se guarda antes de abandonar el ciclo
+	if got := repo.writtenCIDs(); len(got) == 0 || got[0] != "fast" {
+		t.Errorf("escritos = %v, se esperaba el circuito fast", got)
+	}
+}
+
+func TestRunOnceSummary(t *testing.T) {
+	errNotion := errors.New("notion caído")
+	errUbersmith := errors.New("ubersmith caído")
+	tests := []struct {
+		name      string
+		circuits  []string
+		notion    map[string]error
+		ubersmith map[string]error
+		auth      error
+
+		wantErr       bool
+		wantProcessed int
+		wantSuccess   int
+		wantErrors    int
+		wantBySource  map[core.Stage]int
+		wantWarnings  map[core.Stage]int
+		wantWritten   int
+	}{
+		{
+			name:          "todos exitosos",
+			circuits:      []string{"100", "200", "300"},
+			wantProcessed: 3, wantSuccess: 3, wantWritten: 3,
+		},
+		{
+			name:          "error de Notion y advertencia de Ubersmith",
+			circuits:      []string{"100", "200", "300"},
+			notion:        map[string]error{"200": errNotion},
+			ubersmith:     map[string]error{"300": errUbersmith},
+			wantProcessed: 3, wantSuccess: 2, wantErrors: 1, wantWritten: 3,
+			wantBySource: map[core.Stage]int{core.StageNotion: 1},
+			wantWarnings: map[core.Stage]int{core.StageUbersmith: 1},
+		},
+		{
+			name:     "sin circuitos",
+			circuits: nil,
+		},
+		{
+			name:     "falla la autenticación de Zabbix",
+			circuits: []string{"100"},
+			auth:     errors.New("credenciales inválidas"),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{circuits: circuits(tt.circuits...)}
+			pool := core.NewWorkerPool(2, &fakeNotion{errs: tt.notion}, fakeZabbix{}, fakeUbersmith{errs: tt.ubersmith})
+			app := NewApp(repo, pool, fakeAuth{err: tt.auth}, Options{BatchSize: 2})
+
+			summary, err := app.RunOnce(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if summary.Processed != tt.wantProcessed || summary.Success != tt.wantSuccess || summary.Errors != tt.wantErrors {
+				t.Errorf("procesados/exitosos/errores = %d/%d/%d, se esperaba %d/%d/%d",
+					summary.Processed, summary.Success, summary.Errors, tt.wantProcessed, tt.wantSuccess, tt.wantErrors)
+			}
+			for stage, n := range tt.wantBySource {
+				if summary.ErrorsBySource[stage] != n {
+					t.Errorf("errores de %s = %d, se esperaba %d", stage, summary.ErrorsBySource[stage], n)
+				}
+			}
+			for stage, n := range tt.wantWarnings {
+				if summary.WarningsBySource[stage] != n {
+					t.Errorf("advertencias de %s = %d, se esperaba %d", stage, summary.WarningsBySource[stage], n)
+				}
+			}
+			if got := len(repo.writtenCIDs()); got != tt.wantWritten {
+				t.Errorf("escritos = %d, se esperaban %d", got, tt.wantWritten)
+			}
+			if summary.HasErrors() != (tt.wantErrors > 0) {
+				t.Errorf("HasErrors = %v", summary.HasErrors())
+			}
+		})
+	}
+}
+
+func TestExportDoesNotTouchTheDatabase(t *testing.T) {
+	repo := &fakeRepo{circuits: circuits("100", "200")}
+	pool := core.NewWorkerPool(2, &fakeNotion{}, fakeZabbix{}, fakeUbersmith{})
+	app := NewApp(repo, pool, nil, Options{BatchSize: 1})
+	path := filepath.Join(t.TempDir(), "export.csv")
+
+	summary, err := app.Export(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if summary.Processed != 2 {
+		t.Errorf("procesados = %d, se esperaban 2", summary.Processed)
+	}
+	if got := repo.writtenCIDs(); len(got) != 0 {
+		t.Errorf("el export no debe escribir en la DB, se escribió %v", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Errorf("el export tiene %d líneas, se esperaban encabezado + 2 circuitos", lines)
+	}
+}
+
+func TestExportWriteFailureStopsThePool(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("sin /dev/full para simular un disco lleno")
+	}
+	cids := make([]string, 2000)
+	for i := range cids {
+		cids[i] = fmt.Sprintf("%d", 100000+i)
+	}
+	repo := &fakeRepo{circuits: circuits(cids...)}
+	pool := core.NewWorkerPool(2, &fakeNotion{}, fakeZabbix{}, fakeUbersmith{})
+	app := NewApp(repo, pool, nil, Options{BatchSize: 10})
+	before := runtime.NumGoroutine()
+
+	done := make(chan struct{})
+	var summary RunSummary
+	var err error
+	go func() {
+		// Cada escritura en /dev/full falla con ENOSPC al vaciarse el buffer del CSV
+		summary, err = app.Export(context.Background(), "/dev/full")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Export no terminó tras fallar la escritura")
+	}
+
+	if err == nil || !strings.Contains(err.Error(), "error escribiendo el export") {
+		t.Fatalf("err = %v, se esperaba el error de escritura", err)
+	}
+	if summary.Processed >= len(cids) {
+		t.Errorf("se procesaron %d circuitos: el pool debería cancelarse al fallar la escritura", summary.Processed)
+	}
+	// Los workers terminan en lugar de quedar bloqueados enviando resultados
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("quedaron %d goroutines más que antes del export", n-before)
+	}
+	// El ciclo se liberó: se puede volver a ejecutar
+	if _, err := app.Export(context.Background(), filepath.Join(t.TempDir(), "export.csv")); err != nil {
+		t.Errorf("segundo export: %v", err)
+	}
+}
+
+func TestRunOnceRejectsOverlappingRun(t *testing.T) {
+	repo := &fakeRepo{circuits: circuits("slow")}
+	pool := core.NewWorkerPool(1, &fakeNotion{slow: map[string]bool{"slow": true}}, fakeZabbix{}, fakeUbersmith{})
+	app := NewApp(repo, pool, nil, Options{BatchSize: 1})
+
+	// La llamada trabada vence con el plazo del ciclo (un apagado no cancela las llamadas en curso)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		_, _ = app.RunOnce(ctx)
+		close(done)
+	}()
+	// Espera a que el primer ciclo tome el lock
+	for deadline := time.Now().Add(time.Second); !app.running.Load(); {
+		if time.Now().After(deadline) {
+			t.Fatal("el primer ciclo no arrancó")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := app.RunOnce(context.Background()); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("err = %v, se esperaba ErrRunInProgress", err)
+	}
+	<-done
+	if app.running.Load() {
+		t.Error("el lock debería liberarse al terminar el ciclo")
+	}
+}
+
+func TestRunOnceCountsCircuitsWithoutRow(t *testing.T) {
+	repo := &fakeRepo{circuits: circuits("100", "200", "300"), unmatched: map[string]bool{"200": true, "300": true}}
+	pool := core.NewWorkerPool(2, &fakeNotion{}, fakeZabbix{}, fakeUbersmith{})
+	app := NewApp(repo, pool, nil, Options{BatchSize: 2})
+
+	summary, err := app.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if summary.Unmatched != 2 {
+		t.Errorf("sin fila = %d, se esperaban 2", summary.Unmatched)
+	}
+	if got := repo.writtenCIDs(); len(got) != 1 || got[0] != "100" {
+		t.Errorf("escritos = %v, se esperaba solo 100", got)
+	}
+}
+
+// fakeDeadLetter registra lo que el ciclo reporta al dead-letter
+type fakeDeadLetter struct {
+	records map[string]error
+	saves   int
+}
+
+func (d *fakeDeadLetter) IsDead(cid string) bool { return false }
+
+func (d *fakeDeadLetter) Record(cid string, err error) {
+	if d.records == nil {
+		d.records = make(map[string]error)
+	}
+	d.records[cid] = err
+}
+
+func (d *fakeDeadLetter) Save() error {
+	d.saves++
+	return nil
+}
+
+func TestRunOnceDeadLetterRecordsOnlyCircuitErrors(t *testing.T) {
+	notFound := &core.DataError{Err: errors.New("circuit not found in notion")}
+	tests := []struct {
+		name        string
+		notion      map[string]error
+		maxDuration time.Duration
+		slow        map[string]bool
+		wantRecords map[string]error // nil = no se toca el dead-letter
+	}{
+		{
+			name:        "errores de datos y éxitos",
+			notion:      map[string]error{"200": notFound, "300": errors.New("notion api error: 503")},
+			wantRecords: map[string]error{"100": nil, "200": notFound},
+		},
+		{
+			name:   "credenciales de Notion inválidas",
+			notion: map[string]error{"100": core.ErrNotionAuth, "200": core.ErrNotionAuth, "300": core.ErrNotionAuth},
+		},
+		{
+			name:        "ciclo vencido",
+			notion:      map[string]error{"200": notFound},
+			slow:        map[string]bool{"300": true},
+			maxDuration: 50 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{circuits: circuits("100", "200", "300")}
+			pool := core.NewWorkerPool(1, &fakeNotion{errs: tt.notion, slow: tt.slow}, fakeZabbix{}, fakeUbersmith{})
+			app := NewApp(repo, pool, nil, Options{BatchSize: 10, MaxRunDuration: tt.maxDuration, DrainTimeout: time.Second})
+			dead := &fakeDeadLetter{}
+			app.SetDeadLetter(dead)
+
+			_, _ = app.RunOnce(context.Background())
+
+			if tt.wantRecords == nil {
+				if len(dead.records) != 0 || dead.saves != 0 {
+					t.Errorf("registros = %v, guardados = %d; no se esperaba tocar el dead-letter", dead.records, dead.saves)
+				}
+				return
+			}
+			if len(dead.records) != len(tt.wantRecords) {
+				t.Errorf("registros = %v, se esperaban %v", dead.records, tt.wantRecords)
+			}
+			for cid, want := range tt.wantRecords {
+				got, ok := dead.records[cid]
+				if !ok || !errors.Is(got, want) || (want == nil) != (got == nil) {
+					t.Errorf("CID %s: registrado %v (%v), se esperaba %v", cid, got, ok, want)
+				}
+			}
+			if dead.saves != 1 {
+				t.Errorf("guardados = %d, se esperaba 1", dead.saves)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+// aqui acumulamos los resultados y los escribimos en la DB por bloques
+package app
+
+import (
+	"context"
+	"fmt"
+	"gpon-sync/internal/core"
+	"gpon-sync/internal/redact"
+	"gpon-sync/internal/report"
+	"log"
+	"strings"
+)
+
+// BatchWriter acumula resultados y los escribe en bloques de tamaño fijo.
+// En dry-run imprime lo que se escribiría usando los mismos límites de bloque.
+type BatchWriter struct {
+	repo    core.CircuitRepository
+	size    int
+	dryRun  bool
+	pending []core.EnrichedData
+
+	// Reporte opcional de dry-run (una fila por circuito)
+	report report.Writer
+
+	// Auditoría opcional de cada circuito escrito, con sus valores anteriores
+	audit AuditSink
+
+	// Publicación opcional de cada resultado hacia un consumidor externo.
+	// Con sinkOnly los resultados se publican en lugar de escribirse en la DB.
+	sink     core.ResultSink
+	sinkOnly bool
+
+	// Campos que se comparan contra la DB antes de escribir (ver ChangeFields).
+	// Si está vacío se escriben todos los circuitos sin comparar.
+	changeFields []string
+
+	// El repositorio inserta los circuitos sin fila (solo cambia la acción del reporte de dry-run)
+	insertMissing bool
+
+	failures  int // Bloques que no se pudieron guardar
+	skipped   int // Circuitos que no se escribieron por estar en dry-run
+	unmatched int // Circuitos sin fila en la DB
+	changed   int // Circuitos escritos porque cambió algún campo comparado
+	unchanged int // Circuitos omitidos por no tener cambios
+
+	published       int // Resultados publicados en el sink
+	publishFailures int // Resultados que no se pudieron publicar
+}
+
+func NewBatchWriter(repo core.CircuitRepository, size int, dryRun bool) *BatchWriter {
+	if size < 1 {
+		size = 1
+	}
+	return &BatchWriter{
+		repo:    repo,
+		size:    size,
+		dryRun:  dryRun,
+		pending: make([]core.EnrichedData, 0, size),
+	}
+}
+
+// ChangeFields son los campos que se pueden comparar para detectar cambios
+var ChangeFields = []string{"RxPower", "StatusGpon", "PPPoEUsername", "PPPoEPassword"}
+
+// SetChangeDetection activa la escritura solo de circuitos con cambios en alguno de los campos dados
+func (w *BatchWriter) SetChangeDetection(fields []string) {
+	w.changeFields = fields
+}
+
+// SetInsertMissing indica que el repositorio inserta los circuitos sin fila (INSERT_MISSING)
+func (w *BatchWriter) SetInsertMissing(insert bool) {
+	w.insertMissing = insert
+}
+
+// AuditSink registra cada circuito escrito junto a sus valores anteriores (ver audit.Logger).
+// old es nil si el circuito no tenía fila o no se pudieron leer sus valores.
+type AuditSink interface {
+	Record(old *core.EnrichedData, updated core.EnrichedData, dryRun bool) error
+	Flush() error
+}
+
+// SetAudit habilita la auditoría de escrituras
+func (w *BatchWriter) SetAudit(a AuditSink) {
+	w.audit = a
+}
+
+// SetSink habilita la publicación de cada resultado después de la escritura en la DB,
+// o en lugar de ella si only es true
+func (w *BatchWriter) SetSink(s core.ResultSink, only bool) {
+	w.sink = s
+	w.sinkOnly = only
+}
+
+// SetReport define el destino del reporte de dry-run
+func (w *BatchWriter) SetReport(r report.Writer) {
+	w.report = r
+}
+
+// Add agrega un resultado y escribe el bloque cuando se llena
+func (w *BatchWriter) Add(ctx context.Context, res core.EnrichedData) {
+	w.pending = append(w.pending, res)
+	if len(w.pending) >= w.size {
+		w.flush(ctx, "batch")
+	}
+}
+
+// Flush escribe los resultados remanentes
+func (w *BatchWriter) Flush(ctx context.Context) {
+	if len(w.pending) > 0 {
+		w.flush(ctx, "batch final")
+	}
+}
+
+// Failures devuelve la cantidad de bloques que fallaron al guardarse
+func (w *BatchWriter) Failures() int {
+	return w.failures
+}
+
+// Changed devuelve la cantidad de circuitos escritos por tener cambios (solo con detección de cambios)
+func (w *BatchWriter) Changed() int {
+	return w.changed
+}
+
+// Unchanged devuelve la cantidad de circuitos que no se escribieron por no tener cambios
+func (w *BatchWriter) Unchanged() int {
+	return w.unchanged
+}
+
+// Unmatched devuelve la cantidad de circuitos que no tenían fila en la DB
+func (w *BatchWriter) Unmatched() int {
+	return w.unmatched
+}
+
+// Published devuelve la cantidad de resultados publicados en el sink
+func (w *BatchWriter) Published() int {
+	return w.published
+}
+
+// PublishFailures devuelve la cantidad de resultados que no se pudieron publicar en el sink
+func (w *BatchWriter) PublishFailures() int {
+	return w.publishFailures
+}
+
+// DryRunCount devuelve la cantidad de circuitos que se habrían escrito en dry-run
+func (w *BatchWriter) DryRunCount() int {
+	return w.skipped
+}
+
+func (w *BatchWriter) flush(ctx context.Context, label string) {
+	batch := w.pending
+	w.pending = make([]core.EnrichedData, 0, w.size)
+
+	if w.dryRun {
+		log.Printf("[DRY-RUN] Se actualizaría %s de %d items (NO se guardó)", label, len(batch))
+		w.skipped += len(batch)
+		current, err := w.repo.FetchCurrentValues(ctx, batchCIDs(batch))
+		if err != nil {
+			log.Printf("[WARN] [DRY-RUN] No se pudieron leer los valores actuales para comparar: %v", err)
+		} else {
+			w.logDiff(batch, current)
+		}
+		w.recordAudit(batch, current, nil)
+		if w.report != nil {
+			for _, item := range batch {
+				if err := w.report.Write(reportRow(item, w.reportAction(item, current, err == nil))); err != nil {
+					log.Printf("[ERROR] No se pudo escribir el reporte de dry-run: %v", err)
+					break
+				}
+			}
+		}
+		return
+	}
+
+	// Se publica el batch completo (también los circuitos sin cambios): el sink es un stream de resultados
+	defer w.publish(ctx, batch)
+	if w.sink != nil && w.sinkOnly {
+		return
+	}
+
+	// Valores actuales: para escribir solo lo que cambió y para la auditoría
+	var current map[string]core.EnrichedData
+	if len(w.changeFields) > 0 || w.audit != nil {
+		var err error
+		current, err = w.repo.FetchCurrentValues(ctx, batchCIDs(batch))
+		if err != nil {
+			log.Printf("[WARN] No se pudieron leer los valores actuales, se escribe el batch completo: %v", err)
+		}
+	}
+
+	if len(w.changeFields) > 0 && current != nil {
+		batch = w.onlyChanged(batch, current)
+		if len(batch) == 0 {
+			log.Printf("✅ %s sin cambios respecto de la DB, no se escribe", label)
+			return
+		}
+	}
+
+	result, err := w.repo.UpdateCircuitBatch(ctx, batch)
+	if err != nil {
+		log.Printf("[CRITICAL] Fallo al guardar %s: %v", label, err)
+		w.failures++
+		return
+	}
+	w.unmatched += len(result.Unmatched)
+	log.Printf("✅ Se guardó %s en DB (%d items)", label, len(batch))
+	w.recordAudit(batch, current, result.Unmatched)
+}
+
+// publish envía cada resultado del batch al sink. Los fallos se loguean y se cuentan,
+// pero no afectan la escritura en la DB.
+func (w *BatchWriter) publish(ctx context.Context, batch []core.EnrichedData) {
+	if w.sink == nil {
+		return
+	}
+	failed := 0
+	for _, item := range batch {
+		if err := w.sink.Publish(ctx, item); err != nil {
+			log.Printf("[WARN] %v", err)
+			failed++
+			continue
+		}
+		w.published++
+	}
+	w.publishFailures += failed
+	if failed > 0 {
+		log.Printf("[WARN] %d de %d resultados no se pudieron publicar en el sink", failed, len(batch))
+	}
+}
+
+// recordAudit registra en la auditoría los circuitos del batch, salvo los que no tenían fila (unmatched)
+func (w *BatchWriter) recordAudit(batch []core.EnrichedData, current map[string]core.EnrichedData, unmatched []string) {
+	if w.audit == nil {
+		return
+	}
+	skip := toSet(unmatched)
+	for _, item := range batch {
+		if skip[item.CircuitID] {
+			continue
+		}
+		var old *core.EnrichedData
+		if prev, ok := current[item.CircuitID]; ok {
+			old = &prev
+		}
+		if err := w.audit.Record(old, item, w.dryRun); err != nil {
+			log.Printf("[ERROR] %v", err)
+			return
+		}
+	}
+	if err := w.audit.Flush(); err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
+
+// onlyChanged descarta los circuitos cuyos campos comparados coinciden con los valores actuales.
+// Los circuitos sin fila se mantienen (el repositorio los reporta o inserta).
+func (w *BatchWriter) onlyChanged(batch []core.EnrichedData, current map[string]core.EnrichedData) []core.EnrichedData {
+	changed := make([]core.EnrichedData, 0, len(batch))
+	for _, item := range batch {
+		old, exists := current[item.CircuitID]
+		if exists && len(diffFields(old, item, w.changeFields)) == 0 {
+			w.unchanged++
+			continue
+		}
+		changed = append(changed, item)
+	}
+	w.changed += len(changed)
+	return changed
+}
+
+func batchCIDs(batch []core.EnrichedData) []string {
+	cids := make([]string, len(batch))
+	for i, item := range batch {
+		cids[i] = item.CircuitID
+	}
+	return cids
+}
+
+// logDiff imprime, para cada circuito del batch, solo los campos que cambiarían respecto de la DB.
+// Los circuitos sin cambios se resumen en un conteo.
+func (w *BatchWriter) logDiff(batch []core.EnrichedData, current map[string]core.EnrichedData) {
+
+	unchanged := 0
+	for _, item := range batch {
+		old, exists := current[item.CircuitID]
+		if !exists {
+			log.Printf("[DRY-RUN] CID %s: sin fila en la DB", item.CircuitID)
+			continue
+		}
+
+		fields := w.changeFields
+		if len(fields) == 0 {
+			fields = ChangeFields
+		}
+		changes := diffFields(old, item, fields)
+		if len(changes) == 0 {
+			unchanged++
+			continue
+		}
+		log.Printf("[DRY-RUN] CID %s: %s", item.CircuitID, strings.Join(changes, ", "))
+	}
+	if unchanged > 0 {
+		log.Printf("[DRY-RUN] %d circuitos sin cambios", unchanged)
+	}
+}
+
+// diffFields devuelve los campos (de fields) que cambian, con el formato "campo: viejo -> nuevo".
+// La contraseña se muestra enmascarada, y el usuario también si MASK_USERNAMES está activo.
+func diffFields(old, updated core.EnrichedData, fields []string) []string {
+	var changes []string
+	for _, field := range fields {
+		from, to := fieldValue(old, field), fieldValue(updated, field)
+		// Un valor descartado o no resuelto no se escribe: no cuenta como cambio
+		if from == to || updated.KeepsCurrent(field) {
+			continue
+		}
+		if field == "PPPoEPassword" {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", field, redact.Secret(from), redact.Secret(to)))
+			continue
+		}
+		if field == "PPPoEUsername" {
+			from, to = redact.Username(from), redact.Username(to)
+		}
+		changes = append(changes, fmt.Sprintf("%s: %q -> %q", field, from, to))
+	}
+	return changes
+}
+
+// fieldValue devuelve el valor de uno de los ChangeFields
+func fieldValue(d core.EnrichedData, field string) string {
+	switch field {
+	case "RxPower":
+		return d.RxPower
+	case "StatusGpon":
+		return d.StatusGpon
+	case "PPPoEUsername":
+		return d.PPPoEUsername
+	case "PPPoEPassword":
+		return d.PPPoEPassword
+	}
+	return ""
+}
+
+// reportAction es lo que haría la escritura real con el circuito. Sin los valores actuales
+// (known false) se reporta update, igual que la escritura real, que guarda el batch completo.
+func (w *BatchWriter) reportAction(item core.EnrichedData, current map[string]core.EnrichedData, known bool) string {
+	if item.Error != nil {
+		return report.ActionError
+	}
+	if !known {
+		return report.ActionUpdate
+	}
+	old, exists := current[item.CircuitID]
+	switch {
+	case !exists && w.insertMissing:
+		return report.ActionInsert
+	case !exists:
+		return report.ActionUnmatched
+	case len(w.changeFields) > 0 && len(diffFields(old, item, w.changeFields)) == 0:
+		return report.ActionUnchanged
+	}
+	return report.ActionUpdate
+}
+
+// reportRow convierte un resultado en una fila de reporte
+func reportRow(item core.EnrichedData, action string) report.Row {
+	row := report.Row{
+		CID:        item.CircuitID,
+		RxPower:    item.RxPower,
+		StatusGpon: item.StatusGpon,
+		PPPoEUser:  item.PPPoEUsername,
+		Action:     action,
+	}
+	if item.Error != nil {
+		row.Error = item.Error.Error()
+	}
+	return row
+}
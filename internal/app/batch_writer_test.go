@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"gpon-sync/internal/core"
+	"gpon-sync/internal/report"
+	"testing"
+)
+
+// currentRepo es un fakeRepo con valores guardados (los CIDs que no están no tienen fila)
+type currentRepo struct {
+	fakeRepo
+	current map[string]core.EnrichedData
+	err     error
+}
+
+func (r *currentRepo) FetchCurrentValues(ctx context.Context, cids []string) (map[string]core.EnrichedData, error) {
+	return r.current, r.err
+}
+
+// memReport guarda las filas del reporte en memoria
+type memReport struct {
+	rows []report.Row
+}
+
+func (m *memReport) Write(row report.Row) error {
+	m.rows = append(m.rows, row)
+	return nil
+}
+
+func (m *memReport) Close() error { return nil }
+
+func TestDryRunReportActions(t *testing.T) {
+	stored := core.EnrichedData{CircuitID: "100", RxPower: "-20.1 dBm", StatusGpon: "1", PPPoEUsername: "u100"}
+	changed := stored
+	changed.CircuitID, changed.RxPower = "200", "-25.0 dBm"
+	batch := []core.EnrichedData{
+		stored,
+		changed,
+		{CircuitID: "300", RxPower: "-19.0 dBm"},
+		{CircuitID: "400", Error: errors.New("circuit not found in notion")},
+	}
+	current := map[string]core.EnrichedData{"100": stored, "200": {CircuitID: "200", RxPower: "-20.1 dBm", StatusGpon: "1", PPPoEUsername: "u100"}}
+
+	tests := []struct {
+		name          string
+		changeFields  []string
+		insertMissing bool
+		fetchErr      error
+		want          []string
+	}{
+		{"sin detección de cambios", nil, false, nil,
+			[]string{report.ActionUpdate, report.ActionUpdate, report.ActionUnmatched, report.ActionError}},
+		{"con detección de cambios", ChangeFields, false, nil,
+			[]string{report.ActionUnchanged, report.ActionUpdate, report.ActionUnmatched, report.ActionError}},
+		{"con INSERT_MISSING", ChangeFields, true, nil,
+			[]string{report.ActionUnchanged, report.ActionUpdate, report.ActionInsert, report.ActionError}},
+		// Sin los valores actuales la escritura guarda el batch completo
+		{"sin valores actuales", ChangeFields, true, errors.New("db caída"),
+			[]string{report.ActionUpdate, report.ActionUpdate, report.ActionUpdate, report.ActionError}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &currentRepo{current: current, err: tt.fetchErr}
+			rep := &memReport{}
+			w := NewBatchWriter(repo, len(batch), true)
+			w.SetChangeDetection(tt.changeFields)
+			w.SetInsertMissing(tt.insertMissing)
+			w.SetReport(rep)
+
+			for _, item := range batch {
+				w.Add(context.Background(), item)
+			}
+			w.Flush(context.Background())
+
+			if len(rep.rows) != len(batch) {
+				t.Fatalf("filas = %d, se esperaban %d", len(rep.rows), len(batch))
+			}
+			for i, row := range rep.rows {
+				if row.CID != batch[i].CircuitID || row.Action != tt.want[i] {
+					t.Errorf("fila %d: CID %s acción %q, se esperaba %s %q", i, row.CID, row.Action, batch[i].CircuitID, tt.want[i])
+				}
+			}
+			if rep.rows[3].Error == "" {
+				t.Error("la fila con error debería incluir el detalle")
+			}
+			if len(repo.written) != 0 {
+				t.Errorf("dry-run escribió %d circuitos", len(repo.written))
+			}
+		})
+	}
+}
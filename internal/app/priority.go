@@ -0,0 +1,67 @@
+package app
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"gpon-sync/internal/core"
+)
+
+// La OLT de un circuito solo se conoce después de consultar Notion, así que la prioridad
+// por OLT usa la OLT que el circuito tuvo en ciclos anteriores. En el primer ciclo solo
+// cuenta PRIORITY_CIDS.
+
+// rememberOLT guarda la OLT de un resultado para ordenar los ciclos siguientes
+func (a *App) rememberOLT(res core.EnrichedData) {
+	if len(a.opts.PriorityOLTs) == 0 || res.OLT == "" {
+		return
+	}
+	a.oltMu.Lock()
+	defer a.oltMu.Unlock()
+	if a.knownOLT == nil {
+		a.knownOLT = make(map[string]string)
+	}
+	a.knownOLT[res.CircuitID] = res.OLT
+}
+
+// prioritize ordena los circuitos: primero los de PRIORITY_CIDS, luego los de una OLT de
+// PRIORITY_OLTS y después el resto, manteniendo el orden de la DB dentro de cada grupo.
+// El orden es el de envío al worker pool: con varios workers los circuitos se procesan
+// en paralelo, así que es una prioridad aproximada, no un orden estricto de finalización.
+func (a *App) prioritize(circuits []core.Circuit) []core.Circuit {
+	if len(a.opts.PriorityCIDs) == 0 && len(a.opts.PriorityOLTs) == 0 {
+		return circuits
+	}
+
+	cids := toSet(a.opts.PriorityCIDs)
+	olts := make(map[string]bool, len(a.opts.PriorityOLTs))
+	for _, olt := range a.opts.PriorityOLTs {
+		olts[strings.ToLower(olt)] = true
+	}
+
+	a.oltMu.Lock()
+	rank := make(map[string]int, len(circuits))
+	first := 0
+	for _, c := range circuits {
+		switch {
+		case cids[c.CID]:
+			rank[c.CID] = 0
+		case olts[strings.ToLower(a.knownOLT[c.CID])]:
+			rank[c.CID] = 1
+		default:
+			rank[c.CID] = 2
+			continue
+		}
+		first++
+	}
+	a.oltMu.Unlock()
+
+	sort.SliceStable(circuits, func(i, j int) bool {
+		return rank[circuits[i].CID] < rank[circuits[j].CID]
+	})
+	if first > 0 {
+		log.Printf("⏫ %d circuitos priorizados (PRIORITY_CIDS / PRIORITY_OLTS)", first)
+	}
+	return circuits
+}
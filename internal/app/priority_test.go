@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"gpon-sync/internal/core"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// orderNotion registra el orden en que el worker pool consulta los circuitos
+type orderNotion struct {
+	fakeNotion
+	mu    sync.Mutex
+	order []string
+}
+
+func (n *orderNotion) GetNetworkInfo(ctx context.Context, cid string) (core.NetworkInfo, error) {
+	n.mu.Lock()
+	n.order = append(n.order, cid)
+	n.mu.Unlock()
+	return n.fakeNotion.GetNetworkInfo(ctx, cid)
+}
+
+// reset devuelve el orden registrado y empieza uno nuevo
+func (n *orderNotion) reset() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	order := strings.Join(n.order, ",")
+	n.order = nil
+	return order
+}
+
+func TestRunOnceSendsPriorityCircuitsFirst(t *testing.T) {
+	repo := &fakeRepo{circuits: circuits("a", "b", "c", "d", "e")}
+	notion := &orderNotion{}
+	// Un solo worker: el orden de consulta es el orden de envío
+	pool := core.NewWorkerPool(1, notion, fakeZabbix{}, fakeUbersmith{})
+	app := NewApp(repo, pool, nil, Options{
+		BatchSize:    10,
+		PriorityCIDs: []string{"d"},
+		PriorityOLTs: []string{"olt-b"},
+	})
+
+	if _, err := app.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Primer ciclo: la OLT de cada circuito todavía no se conoce, solo cuenta PRIORITY_CIDS
+	if got := notion.reset(); got != "d,a,b,c,e" {
+		t.Errorf("primer ciclo = %s, se esperaba d,a,b,c,e", got)
+	}
+
+	if _, err := app.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Segundo ciclo: b tuvo la OLT OLT-b (sin distinguir mayúsculas); el resto conserva el orden de la DB
+	if got := notion.reset(); got != "d,b,a,c,e" {
+		t.Errorf("segundo ciclo = %s, se esperaba d,b,a,c,e", got)
+	}
+}
+
+func TestPrioritizeWithoutPriorities(t *testing.T) {
+	app := NewApp(&fakeRepo{}, nil, nil, Options{})
+	in := circuits("c", "a", "b")
+	out := app.prioritize(in)
+	for i := range in {
+		if out[i].CID != in[i].CID {
+			t.Fatalf("orden = %v, sin prioridades se esperaba el de la DB", out)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// aqui registramos cada escritura en la DB en un archivo JSON-lines (auditoría)
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"gpon-sync/internal/core"
+	"gpon-sync/internal/redact"
+	"os"
+	"sync"
+	"time"
+)
+
+// Values son los valores de las columnas enriquecidas de un circuito.
+// La contraseña PPPoE se guarda enmascarada (alcanza para ver que cambió).
+type Values struct {
+	RxPower       string `json:"rx_power"`
+	StatusGpon    string `json:"status_gpon"`
+	PPPoEUsername string `json:"pppoe_username"`
+	PPPoEPassword string `json:"pppoe_password"`
+}
+
+// Entry es una línea del archivo de auditoría
+type Entry struct {
+	Time   time.Time `json:"time"`
+	CID    string    `json:"cid"`
+	DryRun bool      `json:"dry_run"`
+	Old    *Values   `json:"old"` // null si el circuito no tenía fila o no se pudieron leer los valores anteriores
+	New    Values    `json:"new"`
+}
+
+// Logger agrega entradas a un archivo JSON-lines. El archivo se abre en modo append:
+// se acumula entre ciclos y reinicios (la rotación queda a cargo de logrotate o similar).
+type Logger struct {
+	mu sync.Mutex
+	f  *os.File
+	w  *bufio.Writer
+}
+
+// Open abre (o crea) el archivo de auditoría
+func Open(path string) (*Logger, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir el log de auditoría %s: %w", path, err)
+	}
+	return &Logger{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func values(d core.EnrichedData) Values {
+	return Values{
+		RxPower:       d.RxPower,
+		StatusGpon:    d.StatusGpon,
+		PPPoEUsername: d.PPPoEUsername,
+		PPPoEPassword: redact.Secret(d.PPPoEPassword),
+	}
+}
+
+// Record agrega la entrada de un circuito escrito (o que se habría escrito, en dry-run).
+// Queda en el buffer hasta el próximo Flush.
+func (l *Logger) Record(old *core.EnrichedData, updated core.EnrichedData, dryRun bool) error {
+	entry := Entry{
+		Time:   time.Now().UTC(),
+		CID:    updated.CircuitID,
+		DryRun: dryRun,
+		New:    values(updated),
+	}
+	if old != nil {
+		v := values(*old)
+		entry.Old = &v
+		// Las columnas que conservan su valor (descartado o no devuelto por Zabbix) no cambian
+		if updated.KeepsCurrent("RxPower") {
+			entry.New.RxPower = v.RxPower
+		}
+		if updated.KeepsCurrent("StatusGpon") {
+			entry.New.StatusGpon = v.StatusGpon
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("no se pudo escribir el log de auditoría: %w", err)
+	}
+	return nil
+}
+
+// Flush escribe el buffer en el archivo y lo sincroniza con el disco
+func (l *Logger) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.w.Flush(); err != nil {
+		return fmt.Errorf("no se pudo escribir el log de auditoría: %w", err)
+	}
+	return l.f.Sync()
+}
+
+// Close escribe lo pendiente y cierra el archivo
+func (l *Logger) Close() error {
+	if err := l.Flush(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"gpon-sync/internal/core"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// readLines decodifica cada línea del archivo como un objeto JSON genérico
+func readLines(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("línea no es JSON: %q (%v)", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func keys(m map[string]interface{}) string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
+func TestRecordEmitsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := core.EnrichedData{CircuitID: "100", RxPower: "-21.0 dBm", StatusGpon: "1", PPPoEUsername: "u100", PPPoEPassword: "clave-anterior"}
+	// La lectura descartada conserva el valor anterior
+	updated := core.EnrichedData{CircuitID: "100", RxPowerDiscarded: true, StatusGpon: "2", PPPoEUsername: "u100", PPPoEPassword: "clave-nueva-100"}
+	if err := l.Record(&old, updated, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(nil, core.EnrichedData{CircuitID: "200", RxPower: "-19.5 dBm"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readLines(t, path)
+	if len(lines) != 2 {
+		t.Fatalf("se escribieron %d líneas, se esperaban 2", len(lines))
+	}
+	first := lines[0]
+	if got := keys(first); got != "cid,dry_run,new,old,time" {
+		t.Errorf("campos = %s", got)
+	}
+	if first["cid"] != "100" || first["dry_run"] != false {
+		t.Errorf("entrada = %v", first)
+	}
+	newValues, _ := first["new"].(map[string]interface{})
+	if got := keys(newValues); got != "pppoe_password,pppoe_username,rx_power,status_gpon" {
+		t.Errorf("campos de new = %s", got)
+	}
+	if newValues["rx_power"] != "-21.0 dBm" || newValues["status_gpon"] != "2" {
+		t.Errorf("new = %v, se esperaba conservar el rx power anterior", newValues)
+	}
+	if pass, _ := newValues["pppoe_password"].(string); pass == "" || strings.Contains(pass, "clave-nueva") {
+		t.Errorf("la contraseña debería guardarse enmascarada (valor: %q)", pass)
+	}
+
+	second := lines[1]
+	if second["old"] != nil || second["dry_run"] != true {
+		t.Errorf("entrada sin valores anteriores = %v, se esperaba old null y dry_run true", second)
+	}
+}
+
+func TestOpenAppendsAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for _, cid := range []string{"100", "200"} {
+		l, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Record(nil, core.EnrichedData{CircuitID: cid}, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines := readLines(t, path); len(lines) != 2 || lines[0]["cid"] != "100" || lines[1]["cid"] != "200" {
+		t.Errorf("líneas = %v, se esperaba una por ciclo en orden", lines)
+	}
+}
@@ -3,8 +3,14 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"gpon-sync/internal/core"
+	"gpon-sync/internal/version"
 
 	"github.com/joho/godotenv"
 )
@@ -14,43 +20,331 @@ type Config struct {
 	// Base de Datos (DSN formateado)
 	DatabaseURL string
 
+	// Pool de conexiones de la DB
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBPingTimeout     time.Duration // Tiempo máximo para verificar la conexión al arrancar
+	DBWriteRetries    int           // Reintentos de escritura ante deadlocks o errores de conexión
+
+	// Insertar los circuitos que no existen en la tabla (INSERT_MISSING) y los valores
+	// fijos de las columnas NOT NULL que el enriquecimiento no completa (INSERT_EXTRA_COLUMNS=col=valor,...)
+	InsertMissing      bool
+	InsertExtraColumns map[string]string
+
+	// Esquema de la tabla de circuitos (por defecto circuitos con CID, RxPower, StatusGpon, PPPoEUsername, PPPoEPassword)
+	DBTable          string
+	ColCID           string
+	ColRxPower       string
+	ColStatusGpon    string
+	ColPPPoEUsername string
+	ColPPPoEPassword string
+	DBSchemaCheck    bool // Verificar al arrancar que la tabla y las columnas existan
+
 	// Notion
-	NotionKey  string
-	NotionDBID string
+	NotionKey   string
+	NotionDBIDs []string // Bases de Notion en orden de búsqueda
+	// Versión de la API de Notion (header Notion-Version). Vacío = la versión por defecto del adaptador
+	NotionVersion string
+	// URL base de la API de Notion (vacío = API pública). Solo para apuntar a un servidor de pruebas
+	NotionAPIURL string
+
+	// Nombres de las columnas de Notion (vacío = los de la base original: OLT, Description, "" o "</>")
+	NotionOLTProp  string
+	NotionDescProp string
+	NotionONTProps []string
+	NotionVLANProp string // Opcional: columna con la VLAN (vacío = la base no la tiene)
+
+	// Formatos adicionales del CID en la columna Description (ej: "fx-0{cid}-"), después de los de fábrica
+	NotionCIDFormats []string
+
+	// Un 401/403 de Notion detiene el ciclo en lugar de fallar circuito por circuito
+	NotionAuthFailFast bool
+
+	// Búsqueda en lote: un filtro "or" por cada NotionBatchSize CIDs antes de procesar el ciclo
+	NotionBatchQuery bool
+	NotionBatchSize  int
 
 	// Zabbix
 	ZabbixURL  string
 	ZabbixUser string
 	ZabbixPass string
+	// Token de API (Zabbix 5.4+): reemplaza el login con usuario y contraseña
+	ZabbixAPIToken string
+	// Dónde se envía el token de Zabbix: "field" (campo auth del JSON) o "header" (Authorization: Bearer, 6.4+)
+	ZabbixAuthMode string
+
+	// TLS de Zabbix (instalaciones con certificados autofirmados)
+	ZabbixInsecureSkipVerify bool
+	ZabbixCACert             string
+
+	// Plantillas de las keys de los items (placeholders {first}, {second}, {third} del ONT ID)
+	StatusKeyTemplate core.KeyTemplate
+	PowerKeyTemplate  core.KeyTemplate
+
+	// Verificar que la OLT exista en Zabbix antes de consultar items (una request extra por host)
+	ZabbixHostCheck bool
+
+	// Problemas activos de la OLT con severidad >= ZabbixProblemSeverity (0-5) explican lecturas vacías.
+	// Agrega requests por OLT en cada ciclo: apagado por defecto.
+	ZabbixProblemCheck    bool
+	ZabbixProblemSeverity int
+
+	// Tag (nombre o nombre:valor) para filtrar en Zabbix los items de potencia (vacío = todos los items del host)
+	ZabbixPowerTag string
+
+	// Traducción de códigos de status GPON a etiquetas legibles (GPON_STATUS_MAP=1=online,2=offline)
+	GponStatusMap map[string]string
 
 	// Ubersmith
 	UbersmithURL  string
 	UbersmithUser string
 	UbersmithPass string
 
+	// Campos de Ubersmith que deben encontrarse para cortar la búsqueda (user, pass)
+	UbersmithRequiredFields []string
+
+	// Nombres de custom fields de usuario y contraseña PPPoE (vacío = nombres por defecto del adaptador)
+	UbersmithUserFields []string
+	UbersmithPassFields []string
+
+	// Custom fields de Ubersmith con la VLAN (vacío = Ubersmith no aporta VLAN)
+	UbersmithVLANFields []string
+
+	// Fuentes de la VLAN en orden de preferencia (ubersmith, notion): se usa la primera que la tenga
+	VLANSource []string
+
+	// Detección aproximada de custom fields por substrings del nombre (apagada por defecto)
+	UbersmithHeuristicMatch bool
+
+	// Vigencia de la definición de custom fields de Ubersmith en cache (se comparte entre ciclos)
+	UbersmithFieldCacheTTL time.Duration
+
+	// Métodos de la API de Ubersmith a probar, en orden, como "método[:parámetro]" (vacío = todos los conocidos)
+	UbersmithMethods []string
+
+	// TLS de Ubersmith
+	UbersmithInsecureSkipVerify bool
+	UbersmithCACert             string
+
+	// Proxy de salida para todos los adaptadores. Si está vacío se usan HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	OutboundProxy string
+
+	// Log de cada request HTTP saliente (sin credenciales)
+	HTTPDebug bool
+
+	// User-Agent de los requests salientes (default "gpon-sync/<versión>")
+	UserAgent string
+
+	// Máximo de requests HTTP simultáneos entre todos los adaptadores (0 = sin límite)
+	MaxInflightHTTP int
+
+	// Reutilización de conexiones HTTP (keep-alive) de cada adaptador
+	HTTPMaxIdleConns        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeout     time.Duration
+
+	// Rate limit por adaptador (requests por segundo; 0 = sin límite)
+	NotionRPS    float64
+	NotionBurst  int // Requests a Notion que pueden salir en ráfaga antes de aplicar el RPS
+	ZabbixRPS    float64
+	UbersmithRPS float64
+
+	// Rango de VLANs válidas: valores fuera de rango se descartan antes de llegar a la DB
+	VLANMin int
+	VLANMax int
+
+	// Campos que se comparan contra la DB para escribir solo los circuitos que cambiaron
+	// (vacío = se escriben todos los circuitos)
+	ChangeDetectionFields []string
+
 	// Configuración del Worker
 	WorkerCount int
+	BatchSize   int // Cantidad de resultados por escritura en la DB
+
+	// Entregar resultados en el orden de los circuitos (logs y exports comparables entre ejecuciones)
+	OrderedResults bool
+
+	// Dead-letter: archivo con los fallos consecutivos por CID (vacío = deshabilitado)
+	// y cantidad de fallos seguidos para que un circuito se omita en los ciclos normales
+	DeadLetterFile      string
+	DeadLetterThreshold int
+
+	// Estado del último ciclo: archivo donde se persiste (vacío = solo en memoria)
+	// y dirección del endpoint HTTP /status (vacío = sin servidor)
+	StatusFile string
+	StatusAddr string
+
+	// Rango de rx power aceptado (dBm): las lecturas fuera de rango se descartan y no se escriben
+	RxPowerMin float64
+	RxPowerMax float64
+
+	// Log de progreso cada N circuitos (0 = deshabilitado) y detalle por circuito exitoso
+	ProgressEvery int
+	Verbose       bool
+
+	// Línea [COVERAGE] por cada circuito que no se enriqueció por completo
+	LogCoverage bool
+
+	// Archivo JSON-lines donde se registra cada circuito escrito con sus valores anteriores (vacío = deshabilitado)
+	AuditLog string
+
+	// Publicación de cada resultado en un webhook (POST JSON), además de la DB
+	// o en lugar de ella con ResultSinkOnly. El token se envía como Bearer.
+	ResultSinkEnabled bool
+	ResultSinkURL     string
+	ResultSinkToken   string
+	ResultSinkOnly    bool
+
+	// Tamaño de las colas del worker pool (0 = WORKER_COUNT). Acota la memoria en ciclos grandes.
+	QueueBuffer int
+
+	// Workers que viven todo el proceso en lugar de crearse en cada ciclo
+	PersistentWorkers bool
+
+	// Sincronización parcial: una etapa deshabilitada no se consulta y sus columnas no se escriben.
+	// Zabbix necesita la OLT/ONT de Notion, así que no se puede habilitar sin Notion.
+	EnableNotion    bool
+	EnableUbersmith bool
+	EnableZabbix    bool
+
+	// Filtro de circuitos (CIDs separados por comas): con allowlist solo se procesan esos CIDs,
+	// los de la denylist se omiten siempre
+	CircuitAllowlist []string
+	CircuitDenylist  []string
+
+	// Conservar en la DB el status / rx power que Zabbix no devolvió (en lugar de escribirlos vacíos)
+	ZabbixOnlyResolved bool
+
+	// Antigüedad máxima del lastvalue de Zabbix (0 = sin límite)
+	ZabbixMaxValueAge time.Duration
+
+	// Tiempo máximo por circuito (0 = sin límite) y su reparto entre etapas (pesos relativos)
+	CircuitTimeout       time.Duration
+	CircuitTimeoutShares map[core.Stage]int
+
+	// Prioridad (best-effort): CIDs y OLTs cuyos circuitos se procesan primero
+	PriorityCIDs []string
+	PriorityOLTs []string
 
 	// Modo de Prueba (Dry-Run): Si es true, no actualiza la base de datos
 	DryRun bool
+	// Ruta opcional del reporte de dry-run (.csv o .json)
+	DryRunOutput string
+
+	// Ruta del export CSV: si está definida se ejecuta el enriquecimiento una vez,
+	// se escribe el CSV y el proceso termina sin escribir en la DB
+	ExportCSV string
+
+	// Frecuencia de sincronización: cada SyncInterval más un desfase aleatorio de hasta SyncJitter
+	SyncInterval time.Duration
+	SyncJitter   time.Duration
+
+	// Espera máxima entre ciclos cuando fallan completos varias veces seguidas (0 = sin backoff)
+	SyncBackoffMax time.Duration
+
+	// Duración máxima de un ciclo: al vencer se cancela y se guarda lo procesado (0 = sin límite)
+	MaxRunDuration time.Duration
+
+	// Tiempo máximo para terminar los circuitos en curso y guardar el batch pendiente al apagar
+	ShutdownGrace time.Duration
+
+	// Ejecución única (RunOnce): ejecuta un solo ciclo y termina (para cron / Kubernetes CronJobs)
+	RunOnce bool
+
+	// ONT que se usa cuando Notion tiene varias para un circuito (active o worst_rx)
+	ONTPolicy string
+
+	// Formato del rx power: unidad, decimales (-1 = tal cual lo devuelve Zabbix) y si 0 cuenta como sin señal
+	RxPowerUnit        string
+	RxPowerPrecision   int
+	RxPowerZeroAsEmpty bool
+
+	// Enmascarado en logs: usuarios PPPoE (además de las contraseñas) y caracteres visibles en cada extremo
+	MaskUsernames bool
+	MaskReveal    int
+
+	// Loguear la configuración efectiva al arrancar, con los secretos enmascarados
+	ConfigDump bool
+
+	// Valores sensibles cargados (contraseñas, API keys), para enmascararlos en los logs
+	secrets []string
+}
+
+// WriteColumns devuelve las columnas enriquecidas que se escriben según las etapas habilitadas
+func (c *Config) WriteColumns() []string {
+	var cols []string
+	if c.EnableZabbix {
+		cols = append(cols, "RxPower", "StatusGpon")
+	}
+	if c.EnableUbersmith {
+		cols = append(cols, "PPPoEUsername", "PPPoEPassword")
+	}
+	return cols
+}
+
+// ChangeFields devuelve los campos de CHANGE_DETECTION_FIELDS que efectivamente se escriben
+// (comparar una columna que no se escribe marcaría cambios que nunca se guardan)
+func (c *Config) ChangeFields() []string {
+	written := make(map[string]bool)
+	for _, col := range c.WriteColumns() {
+		written[col] = true
+	}
+	var fields []string
+	for _, f := range c.ChangeDetectionFields {
+		if written[f] {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// Secrets devuelve los valores sensibles de la configuración (ver redact.Register)
+func (c *Config) Secrets() []string {
+	return c.secrets
+}
+
+// ValidationError agrupa todos los problemas de configuración encontrados,
+// para que el operador pueda corregirlos de una sola vez
+type ValidationError struct {
+	Problems []string
 }
 
-// Load lee el archivo .env y las variables de entorno del sistema
-func Load() *Config {
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("configuración inválida (%d problemas):\n  - %s",
+		len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Load lee el archivo .env y las variables de entorno del sistema.
+// Valida todo antes de retornar y reporta todos los problemas juntos en un *ValidationError.
+// La decisión de terminar el proceso queda en manos de quien llama.
+func Load() (*Config, error) {
 	// 1. Intentamos cargar el archivo .env
 	// Si no existe (producción con Docker envs), no pasa nada.
 	_ = godotenv.Load()
 
+	return LoadFrom(os.LookupEnv)
+}
+
+// LookupFunc obtiene el valor de una variable y si está definida (misma firma que os.LookupEnv)
+type LookupFunc func(key string) (string, bool)
+
+// LoadFrom construye la configuración a partir de una fuente de variables arbitraria.
+// Permite ejercitar entornos válidos e inválidos (ej: un map) sin tocar el entorno del proceso.
+func LoadFrom(lookup LookupFunc) (*Config, error) {
+	l := &loader{lookup: lookup}
+	l.resolver = l.newSecretResolver()
+
 	// 2. Construcción del DSN de MySQL
 	// Es mejor pedir host, user, pass por separado para evitar errores de formato en el string
-	dbHost := getEnvRequired("DB_HOST")
-	dbPort := getEnv("DB_PORT", "3306") // Puerto por defecto de MySQL
-	dbUser := getEnvRequired("DB_USER")
-	dbPass := getEnvRequired("DB_PASS")
-	dbName := getEnvRequired("DB_NAME")
+	dbHost := l.getEnvRequired("DB_HOST")
+	dbPort := l.getEnv("DB_PORT", "3306") // Puerto por defecto de MySQL
+	dbUser := l.getEnvRequired("DB_USER")
+	dbPass := l.getSecret("DB_PASS", true)
+	dbName := l.getEnvRequired("DB_NAME")
 
 	// Parámetros adicionales de MySQL (parseTime=true para manejar fechas correctamente)
-	dbParams := getEnv("DB_PARAMS", "parseTime=true&charset=utf8mb4")
+	dbParams := l.getEnv("DB_PARAMS", "parseTime=true&charset=utf8mb4")
 
 	// Formato MySQL: user:password@tcp(host:port)/dbname?params
 	databaseURL := fmt.Sprintf(
@@ -58,52 +352,561 @@ func Load() *Config {
 		dbUser, dbPass, dbHost, dbPort, dbName, dbParams,
 	)
 
-	// 3. Configuración de Workers
-	workersStr := getEnv("WORKER_COUNT", "5")
-	workers, err := strconv.Atoi(workersStr)
-	if err != nil {
-		workers = 5
-		log.Printf("Advertencia: WORKER_COUNT inválido, usando default: %d", workers)
+	// Pool de conexiones: los workers no tocan la DB, solo el escritor de batches,
+	// así que pocas conexiones son suficientes
+	dbMaxOpen := l.getEnvInt("DB_MAX_OPEN_CONNS", 10)
+	dbMaxIdle := l.getEnvInt("DB_MAX_IDLE_CONNS", 5)
+	dbConnLifetime := l.getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute)
+	dbPingTimeout := l.getEnvDuration("DB_PING_TIMEOUT", 10*time.Second)
+	dbWriteRetries := l.getEnvInt("DB_WRITE_RETRIES", 3)
+
+	// Notion: NOTION_DATABASE_IDS (lista separada por comas) o NOTION_DATABASE_ID (una sola base)
+	notionDBIDs := splitList(l.getEnv("NOTION_DATABASE_IDS", ""))
+	if len(notionDBIDs) == 0 {
+		notionDBIDs = []string{l.getEnvRequired("NOTION_DATABASE_ID")}
 	}
 
+	// Rate limit por adaptador: carga predecible en las APIs sin importar WORKER_COUNT
+	notionRPS := l.getEnvFloat("NOTION_RPS", 3)
+	notionBurst := l.getEnvInt("NOTION_BURST", 3)
+	zabbixRPS := l.getEnvFloat("ZABBIX_RPS", 10)
+	ubersmithRPS := l.getEnvFloat("UBERSMITH_RPS", 5)
+
+	// 3. Configuración de Workers
+	workers := l.getEnvInt("WORKER_COUNT", 5)
+	batchSize := l.getEnvInt("BATCH_SIZE", 100)
+
 	// 4. Modo Dry-Run (Prueba sin modificar DB)
-	dryRunStr := getEnv("DRY_RUN", "false")
-	dryRun := dryRunStr == "true" || dryRunStr == "1" || dryRunStr == "yes"
+	dryRun := l.getEnvBool("DRY_RUN", false)
+
+	// 5. Ejecución única (sin ticker interno)
+	runOnce := l.getEnvBool("RUN_ONCE", false)
+
+	cfg := &Config{
+		DatabaseURL:        databaseURL,
+		DBMaxOpenConns:     dbMaxOpen,
+		DBMaxIdleConns:     dbMaxIdle,
+		DBConnMaxLifetime:  dbConnLifetime,
+		DBPingTimeout:      dbPingTimeout,
+		DBWriteRetries:     dbWriteRetries,
+		InsertMissing:      l.getEnvBool("INSERT_MISSING", false),
+		InsertExtraColumns: l.getEnvMap("INSERT_EXTRA_COLUMNS"),
+		NotionKey:          l.getSecret("NOTION_API_KEY", true),
+		NotionDBIDs:        notionDBIDs,
+		NotionVersion:      l.getEnv("NOTION_VERSION", ""),
+		NotionAPIURL:       l.getEnv("NOTION_API_URL", ""),
+		NotionOLTProp:      l.getEnv("NOTION_OLT_PROP", ""),
+		NotionDescProp:     l.getEnv("NOTION_DESC_PROP", ""),
+		NotionVLANProp:     l.getEnv("NOTION_VLAN_PROP", ""),
+		NotionCIDFormats:   splitList(l.getEnv("NOTION_CID_FORMATS", "")),
+		NotionAuthFailFast: l.getEnvBool("NOTION_AUTH_FAIL_FAST", true),
+		NotionBatchQuery:   l.getEnvBool("NOTION_BATCH_QUERY", false),
+		NotionBatchSize:    l.getEnvInt("NOTION_BATCH_SIZE", 50),
+		ZabbixURL:          l.getEnvRequired("ZABBIX_URL"),
+		ZabbixUser:         l.getEnv("ZABBIX_USER", ""),
+		ZabbixPass:         l.getSecret("ZABBIX_PASS", false),
+		ZabbixAPIToken:     l.getSecret("ZABBIX_API_TOKEN", false),
+		ZabbixAuthMode:     l.getEnv("ZABBIX_AUTH_MODE", "field"),
+		UbersmithURL:       l.getEnvRequired("UBERSMITH_URL"),
+		UbersmithUser:      l.getEnvRequired("UBERSMITH_USER"),
+		UbersmithPass:      l.getSecret("UBERSMITH_PASS", true),
+
+		ZabbixInsecureSkipVerify:    l.getEnvBool("ZABBIX_INSECURE_SKIP_VERIFY", false),
+		ZabbixCACert:                l.getEnv("ZABBIX_CA_CERT", ""),
+		StatusKeyTemplate:           l.getEnvKeyTemplate("STATUS_KEY_TEMPLATE", core.DefaultStatusKeyTemplate),
+		PowerKeyTemplate:            l.getEnvKeyTemplate("POWER_KEY_TEMPLATE", core.DefaultPowerKeyTemplate),
+		ZabbixHostCheck:             l.getEnvBool("ZABBIX_HOST_CHECK", false),
+		GponStatusMap:               l.getEnvMap("GPON_STATUS_MAP"),
+		UbersmithInsecureSkipVerify: l.getEnvBool("UBERSMITH_INSECURE_SKIP_VERIFY", false),
+		UbersmithCACert:             l.getEnv("UBERSMITH_CA_CERT", ""),
+		UbersmithRequiredFields:     splitList(l.getEnv("UBERSMITH_REQUIRED_FIELDS", "user,pass")),
+		UbersmithUserFields:         splitList(l.getEnv("UBERSMITH_USER_FIELDS", "")),
+		UbersmithPassFields:         splitList(l.getEnv("UBERSMITH_PASS_FIELDS", "")),
+		UbersmithHeuristicMatch:     l.getEnvBool("UBERSMITH_HEURISTIC_MATCH", false),
+
+		OutboundProxy: l.getEnv("OUTBOUND_PROXY", ""),
+		HTTPDebug:     l.getEnvBool("HTTP_DEBUG", false),
+		UserAgent:     l.getEnv("USER_AGENT", version.UserAgent()),
+
+		MaxInflightHTTP: l.getEnvInt("MAX_INFLIGHT_HTTP", 0),
+
+		HTTPMaxIdleConns:        l.getEnvInt("HTTP_MAX_IDLE_CONNS", 100),
+		HTTPMaxIdleConnsPerHost: l.getEnvInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 10),
+		HTTPIdleConnTimeout:     l.getEnvDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+		NotionRPS:               notionRPS,
+		NotionBurst:             notionBurst,
+		ZabbixRPS:               zabbixRPS,
+		UbersmithRPS:            ubersmithRPS,
+		WorkerCount:             workers,
+		BatchSize:               batchSize,
+		DryRun:                  dryRun,
+		DryRunOutput:            l.getEnv("DRY_RUN_OUTPUT", ""),
+		RunOnce:                 runOnce,
+		ShutdownGrace:           l.getEnvDuration("SHUTDOWN_GRACE", 30*time.Second),
+		SyncInterval:            l.getEnvDuration("SYNC_INTERVAL", 5*time.Minute),
+		SyncJitter:              l.getEnvDuration("SYNC_JITTER", 0),
+		ExportCSV:               l.getEnv("EXPORT_CSV", ""),
+		VLANMin:                 l.getEnvInt("VLAN_MIN", core.DefaultVLANMin),
+		VLANMax:                 l.getEnvInt("VLAN_MAX", core.DefaultVLANMax),
+
+		CircuitAllowlist: splitList(l.getEnv("CIRCUIT_ALLOWLIST", "")),
+		CircuitDenylist:  splitList(l.getEnv("CIRCUIT_DENYLIST", "")),
+
+		ZabbixOnlyResolved: l.getEnvBool("ZABBIX_ONLY_RESOLVED", false),
+		ZabbixMaxValueAge:  l.getEnvDuration("ZABBIX_MAX_VALUE_AGE", 0),
+
+		CircuitTimeout:       l.getEnvDuration("CIRCUIT_TIMEOUT", 0),
+		CircuitTimeoutShares: l.getEnvStageShares("CIRCUIT_TIMEOUT_SHARES"),
+
+		PriorityCIDs: splitList(l.getEnv("PRIORITY_CIDS", "")),
+		PriorityOLTs: splitList(l.getEnv("PRIORITY_OLTS", "")),
+
+		// Por defecto se comparan todos los campos escritos: solo se hace UPDATE si algo cambió
+		ChangeDetectionFields: splitList(l.getEnv("CHANGE_DETECTION_FIELDS", "RxPower,StatusGpon,PPPoEUsername,PPPoEPassword")),
+		OrderedResults:        l.getEnvBool("ORDERED_RESULTS", false),
+
+		QueueBuffer: l.getEnvInt("QUEUE_BUFFER", 0),
+
+		PersistentWorkers: l.getEnvBool("PERSISTENT_WORKERS", false),
+		AuditLog:          l.getEnv("AUDIT_LOG", ""),
+
+		ProgressEvery: l.getEnvInt("PROGRESS_EVERY", 100),
+		Verbose:       l.getEnvBool("VERBOSE", false),
+		LogCoverage:   l.getEnvBool("LOG_COVERAGE", false),
+
+		RxPowerMin: l.getEnvFloat("RX_POWER_MIN", core.DefaultRxPowerMin),
+		RxPowerMax: l.getEnvFloat("RX_POWER_MAX", core.DefaultRxPowerMax),
+
+		StatusFile: l.getEnv("STATUS_FILE", ""),
+		StatusAddr: l.getEnv("STATUS_ADDR", ""),
+
+		DeadLetterFile:      l.getEnv("DEADLETTER_FILE", ""),
+		DeadLetterThreshold: l.getEnvInt("DEADLETTER_THRESHOLD", 5),
+
+		EnableNotion:    l.getEnvBool("ENABLE_NOTION", true),
+		EnableUbersmith: l.getEnvBool("ENABLE_UBERSMITH", true),
+		EnableZabbix:    l.getEnvBool("ENABLE_ZABBIX", true),
+
+		SyncBackoffMax: l.getEnvDuration("SYNC_BACKOFF_MAX", time.Hour),
+
+		VLANSource:          splitList(l.getEnv("VLAN_SOURCE", "ubersmith,notion")),
+		UbersmithVLANFields: splitList(l.getEnv("UBERSMITH_VLAN_FIELDS", "")),
+		UbersmithMethods:    splitList(l.getEnv("UBERSMITH_METHODS", "")),
+
+		UbersmithFieldCacheTTL: l.getEnvDuration("UBERSMITH_FIELD_CACHE_TTL", time.Hour),
+
+		ONTPolicy: l.getEnv("ONT_POLICY", string(core.ONTPolicyActive)),
+
+		ZabbixProblemCheck:    l.getEnvBool("ZABBIX_PROBLEM_CHECK", false),
+		ZabbixProblemSeverity: l.getEnvInt("ZABBIX_PROBLEM_MIN_SEVERITY", 4),
+		ZabbixPowerTag:        l.getEnv("ZABBIX_POWER_TAG", ""),
+
+		RxPowerUnit:        l.getEnv("RXPOWER_UNIT", "dBm"),
+		RxPowerPrecision:   l.getEnvInt("RXPOWER_PRECISION", -1),
+		RxPowerZeroAsEmpty: l.getEnvBool("RXPOWER_ZERO_AS_EMPTY", true),
+
+		MaskUsernames: l.getEnvBool("MASK_USERNAMES", false),
+		MaskReveal:    l.getEnvInt("MASK_REVEAL", 2),
+
+		ResultSinkEnabled: l.getEnvBool("RESULT_SINK_ENABLED", false),
+		ResultSinkURL:     l.getEnv("RESULT_SINK_URL", ""),
+		ResultSinkToken:   l.getSecret("RESULT_SINK_TOKEN", false),
+		ResultSinkOnly:    l.getEnvBool("RESULT_SINK_ONLY", false),
+		MaxRunDuration:    l.getEnvDuration("MAX_RUN_DURATION", 0),
+
+		DBTable:          l.getEnv("DB_TABLE", "circuitos"),
+		ColCID:           l.getEnv("COL_CID", "CID"),
+		ColRxPower:       l.getEnv("COL_RXPOWER", "RxPower"),
+		ColStatusGpon:    l.getEnv("COL_STATUSGPON", "StatusGpon"),
+		ColPPPoEUsername: l.getEnv("COL_PPPOE_USERNAME", "PPPoEUsername"),
+		ColPPPoEPassword: l.getEnv("COL_PPPOE_PASSWORD", "PPPoEPassword"),
+		DBSchemaCheck:    l.getEnvBool("DB_SCHEMA_CHECK", true),
+
+		ConfigDump: l.getEnvBool("CONFIG_DUMP", false),
+	}
+	cfg.secrets = []string{dbPass, cfg.NotionKey, cfg.ZabbixPass, cfg.ZabbixAPIToken, cfg.UbersmithPass, cfg.ResultSinkToken}
+	if vault, ok := l.resolver.(VaultResolver); ok {
+		cfg.secrets = append(cfg.secrets, vault.Token)
+	}
+
+	// La columna del ONT ID puede llamarse "" en Notion: solo se reemplaza el default si la variable existe
+	if ontProp, ok := l.lookup("NOTION_ONT_PROP"); ok {
+		cfg.NotionONTProps = []string{ontProp}
+	}
+
+	// USER_AGENT= vacío (como en env.template) también usa el default con la versión
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = version.UserAgent()
+	}
+
+	// 6. Validaciones semánticas
+	cfg.validate(l)
+
+	if len(l.problems) > 0 {
+		return nil, &ValidationError{Problems: l.problems}
+	}
+
 	if dryRun {
 		log.Println("⚠️  MODO PRUEBA ACTIVADO (DRY_RUN=true) - NO se actualizará la base de datos")
 	}
 
-	// 5. Retornar Configuración Validada
-	return &Config{
-		DatabaseURL:   databaseURL,
-		NotionKey:     getEnvRequired("NOTION_API_KEY"),
-		NotionDBID:    getEnvRequired("NOTION_DATABASE_ID"),
-		ZabbixURL:     getEnvRequired("ZABBIX_URL"),
-		ZabbixUser:    getEnvRequired("ZABBIX_USER"),
-		ZabbixPass:    getEnvRequired("ZABBIX_PASS"),
-		UbersmithURL:  getEnvRequired("UBERSMITH_URL"),
-		UbersmithUser: getEnvRequired("UBERSMITH_USER"),
-		UbersmithPass: getEnvRequired("UBERSMITH_PASS"),
-		WorkerCount:   workers,
-		DryRun:        dryRun,
+	return cfg, nil
+}
+
+// validate verifica restricciones entre valores que ya se pudieron leer
+func (c *Config) validate(l *loader) {
+	if c.WorkerCount < 1 {
+		l.addf("WORKER_COUNT debe ser al menos 1 (valor: %d)", c.WorkerCount)
+	}
+	if c.BatchSize < 1 {
+		l.addf("BATCH_SIZE debe ser al menos 1 (valor: %d)", c.BatchSize)
+	}
+	if c.DBMaxOpenConns < 0 || c.DBMaxIdleConns < 0 {
+		l.addf("DB_MAX_OPEN_CONNS y DB_MAX_IDLE_CONNS no pueden ser negativos")
+	}
+	if c.VLANMin < core.DefaultVLANMin || c.VLANMax > core.DefaultVLANMax || c.VLANMin > c.VLANMax {
+		l.addf("VLAN_MIN/VLAN_MAX deben definir un rango dentro de %d-%d (valor: %d-%d)",
+			core.DefaultVLANMin, core.DefaultVLANMax, c.VLANMin, c.VLANMax)
+	}
+
+	for _, field := range c.ChangeDetectionFields {
+		switch field {
+		case "RxPower", "StatusGpon", "PPPoEUsername", "PPPoEPassword":
+		default:
+			l.addf("CHANGE_DETECTION_FIELDS: campo desconocido %q (válidos: RxPower, StatusGpon, PPPoEUsername, PPPoEPassword)", field)
+		}
+	}
+
+	for _, field := range c.UbersmithRequiredFields {
+		if field != "user" && field != "pass" {
+			l.addf("UBERSMITH_REQUIRED_FIELDS: campo desconocido %q (válidos: user, pass)", field)
+		}
+	}
+
+	for _, spec := range c.UbersmithMethods {
+		name, param, _ := strings.Cut(spec, ":")
+		if name != "uber.metadata_bulk_get" && name != "client.service_get" {
+			l.addf("UBERSMITH_METHODS: método desconocido %q (válidos: uber.metadata_bulk_get, client.service_get)", name)
+		}
+		// El parámetro va dentro de la URL de la request: solo se aceptan identificadores simples
+		if strings.Contains(spec, ":") && !isIdentifier(param) {
+			l.addf("UBERSMITH_METHODS: parámetro inválido %q para %s", param, name)
+		}
+	}
+
+	// Los nombres de tabla y columnas se arman dentro de las queries: solo se aceptan identificadores simples
+	for _, ident := range []struct{ key, value string }{
+		{"DB_TABLE", c.DBTable},
+		{"COL_CID", c.ColCID},
+		{"COL_RXPOWER", c.ColRxPower},
+		{"COL_STATUSGPON", c.ColStatusGpon},
+		{"COL_PPPOE_USERNAME", c.ColPPPoEUsername},
+		{"COL_PPPOE_PASSWORD", c.ColPPPoEPassword},
+	} {
+		if !isIdentifier(ident.value) {
+			l.addf("%s: identificador inválido %q (solo letras, números y _)", ident.key, ident.value)
+		}
+	}
+
+	// Los nombres de columna van entre backticks en el INSERT: solo se aceptan identificadores simples
+	for col := range c.InsertExtraColumns {
+		if !isIdentifier(col) {
+			l.addf("INSERT_EXTRA_COLUMNS: nombre de columna inválido %q", col)
+		}
+	}
+	seenSources := make(map[string]bool)
+	for _, source := range c.VLANSource {
+		if source != string(core.StageUbersmith) && source != string(core.StageNotion) {
+			l.addf("VLAN_SOURCE: fuente desconocida %q (válidas: ubersmith, notion)", source)
+		} else if seenSources[source] {
+			l.addf("VLAN_SOURCE: fuente repetida %q", source)
+		}
+		seenSources[source] = true
+	}
+	if c.DeadLetterFile != "" && c.DeadLetterThreshold < 1 {
+		l.addf("DEADLETTER_THRESHOLD debe ser al menos 1 (valor: %d)", c.DeadLetterThreshold)
+	}
+	if c.RxPowerMin >= c.RxPowerMax {
+		l.addf("RX_POWER_MIN debe ser menor que RX_POWER_MAX (valor: %g a %g)", c.RxPowerMin, c.RxPowerMax)
+	}
+	if c.ProgressEvery < 0 {
+		l.addf("PROGRESS_EVERY no puede ser negativo (valor: %d)", c.ProgressEvery)
+	}
+	if c.QueueBuffer < 0 {
+		l.addf("QUEUE_BUFFER no puede ser negativo (valor: %d)", c.QueueBuffer)
+	}
+	if c.DBWriteRetries < 0 {
+		l.addf("DB_WRITE_RETRIES no puede ser negativo (valor: %d)", c.DBWriteRetries)
+	}
+	if c.NotionRPS < 0 || c.ZabbixRPS < 0 || c.UbersmithRPS < 0 {
+		l.addf("NOTION_RPS, ZABBIX_RPS y UBERSMITH_RPS no pueden ser negativos")
+	}
+	if c.SyncInterval <= 0 {
+		l.addf("SYNC_INTERVAL debe ser mayor que cero (valor: %s)", c.SyncInterval)
+	}
+	if c.ZabbixProblemSeverity < 0 || c.ZabbixProblemSeverity > 5 {
+		l.addf("ZABBIX_PROBLEM_MIN_SEVERITY debe estar entre 0 y 5 (valor: %d)", c.ZabbixProblemSeverity)
+	}
+	if c.ONTPolicy != string(core.ONTPolicyActive) && c.ONTPolicy != string(core.ONTPolicyWorstRx) {
+		l.addf("ONT_POLICY debe ser active o worst_rx (valor: %q)", c.ONTPolicy)
+	}
+	if c.RxPowerPrecision < -1 || c.RxPowerPrecision > 6 {
+		l.addf("RXPOWER_PRECISION debe estar entre -1 (tal cual) y 6 (valor: %d)", c.RxPowerPrecision)
+	}
+	if c.HTTPMaxIdleConns < 1 || c.HTTPMaxIdleConnsPerHost < 1 {
+		l.addf("HTTP_MAX_IDLE_CONNS y HTTP_MAX_IDLE_CONNS_PER_HOST deben ser al menos 1 (valores: %d, %d)",
+			c.HTTPMaxIdleConns, c.HTTPMaxIdleConnsPerHost)
+	}
+	if c.HTTPIdleConnTimeout <= 0 {
+		l.addf("HTTP_IDLE_CONN_TIMEOUT debe ser mayor que cero (valor: %s)", c.HTTPIdleConnTimeout)
+	}
+	for _, f := range c.NotionCIDFormats {
+		if !strings.Contains(f, "{cid}") {
+			l.addf("NOTION_CID_FORMATS: el formato %q no contiene {cid}", f)
+		}
+	}
+	if c.NotionBatchSize < 1 || c.NotionBatchSize > 100 {
+		l.addf("NOTION_BATCH_SIZE debe estar entre 1 y 100 (valor: %d)", c.NotionBatchSize)
+	}
+	if c.ZabbixMaxValueAge < 0 {
+		l.addf("ZABBIX_MAX_VALUE_AGE no puede ser negativo (valor: %s)", c.ZabbixMaxValueAge)
+	}
+	if c.CircuitTimeout < 0 {
+		l.addf("CIRCUIT_TIMEOUT no puede ser negativo (valor: %s)", c.CircuitTimeout)
+	}
+	if c.MaxInflightHTTP < 0 {
+		l.addf("MAX_INFLIGHT_HTTP no puede ser negativo (valor: %d)", c.MaxInflightHTTP)
+	}
+	if c.MaskReveal < 0 {
+		l.addf("MASK_REVEAL no puede ser negativo (valor: %d)", c.MaskReveal)
+	}
+	if c.UbersmithFieldCacheTTL <= 0 {
+		l.addf("UBERSMITH_FIELD_CACHE_TTL debe ser mayor que cero (valor: %s)", c.UbersmithFieldCacheTTL)
+	}
+	// Con 0 el apagado no esperaría ni a guardar el último batch
+	if c.ShutdownGrace <= 0 {
+		l.addf("SHUTDOWN_GRACE debe ser mayor que cero (valor: %s)", c.ShutdownGrace)
+	}
+	if c.MaxRunDuration < 0 {
+		l.addf("MAX_RUN_DURATION no puede ser negativo (valor: %s)", c.MaxRunDuration)
+	}
+	if c.SyncBackoffMax < 0 {
+		l.addf("SYNC_BACKOFF_MAX no puede ser negativo (valor: %s)", c.SyncBackoffMax)
+	}
+	if c.SyncJitter < 0 {
+		l.addf("SYNC_JITTER no puede ser negativo (valor: %s)", c.SyncJitter)
+	}
+	// Zabbix: token de API o usuario/contraseña, nunca ambos (no quedaría claro cuál se usa)
+	hasLogin := c.ZabbixUser != "" || c.ZabbixPass != ""
+	switch {
+	case !c.EnableZabbix:
+	case c.ZabbixAPIToken != "" && hasLogin:
+		l.addf("ZABBIX_API_TOKEN no se puede combinar con ZABBIX_USER/ZABBIX_PASS: configurar un solo método de autenticación")
+	case c.ZabbixAPIToken == "" && (c.ZabbixUser == "" || c.ZabbixPass == ""):
+		l.addf("Zabbix requiere ZABBIX_API_TOKEN o bien ZABBIX_USER y ZABBIX_PASS")
+	}
+
+	// Sincronización parcial: la OLT/ONT para consultar Zabbix solo se obtiene de Notion
+	if c.EnableZabbix && !c.EnableNotion {
+		l.addf("ENABLE_ZABBIX=true requiere ENABLE_NOTION=true: la OLT y el ONT ID de cada circuito solo se obtienen de Notion")
+	}
+	if !c.EnableZabbix && !c.EnableUbersmith {
+		l.addf("ENABLE_ZABBIX y ENABLE_UBERSMITH están deshabilitadas: no hay ninguna columna para sincronizar")
+	}
+
+	if c.ZabbixAuthMode != "field" && c.ZabbixAuthMode != "header" {
+		l.addf("ZABBIX_AUTH_MODE debe ser field o header (valor: %q)", c.ZabbixAuthMode)
+	}
+
+	if c.NotionVersion != "" {
+		if _, err := time.Parse("2006-01-02", c.NotionVersion); err != nil {
+			l.addf("NOTION_VERSION debe tener el formato AAAA-MM-DD (valor: %q)", c.NotionVersion)
+		}
+	}
+
+	l.checkURL("NOTION_API_URL", c.NotionAPIURL)
+	l.checkURL("ZABBIX_URL", c.ZabbixURL)
+	l.checkURL("UBERSMITH_URL", c.UbersmithURL)
+	if c.OutboundProxy != "" {
+		l.checkURL("OUTBOUND_PROXY", c.OutboundProxy)
+	}
+	if c.ResultSinkEnabled {
+		if c.ResultSinkURL == "" {
+			l.addf("RESULT_SINK_ENABLED=true requiere RESULT_SINK_URL")
+		}
+		l.checkURL("RESULT_SINK_URL", c.ResultSinkURL)
+	} else if c.ResultSinkOnly {
+		l.addf("RESULT_SINK_ONLY=true requiere RESULT_SINK_ENABLED=true")
 	}
 }
 
 // --- Helpers ---
 
+// loader lee variables de entorno y acumula los problemas encontrados en lugar de detener el programa
+type loader struct {
+	lookup   LookupFunc
+	resolver SecretResolver // nil = los secretos salen del entorno
+	problems []string
+}
+
+func (l *loader) addf(format string, args ...interface{}) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
 // getEnv obtiene una variable o retorna un valor por defecto
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
+func (l *loader) getEnv(key, fallback string) string {
+	if value, exists := l.lookup(key); exists {
 		return value
 	}
 	return fallback
 }
 
-// getEnvRequired obtiene una variable o detiene el programa si no existe (Fail Fast)
-func getEnvRequired(key string) string {
-	value, exists := os.LookupEnv(key)
+// getEnvRequired obtiene una variable y registra un problema si no existe (se reportan todos juntos)
+func (l *loader) getEnvRequired(key string) string {
+	value, exists := l.lookup(key)
 	if !exists || value == "" {
-		log.Fatalf("[FATAL] La variable de entorno requerida '%s' no está definida.", key)
+		l.addf("la variable de entorno requerida '%s' no está definida", key)
 	}
 	return value
 }
+
+// getEnvInt obtiene una variable entera o retorna el valor por defecto si no existe
+func (l *loader) getEnvInt(key string, fallback int) int {
+	value, exists := l.lookup(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		l.addf("%s debe ser un número entero (valor: %q)", key, value)
+		return fallback
+	}
+	return n
+}
+
+// getEnvFloat obtiene una variable numérica decimal o retorna el valor por defecto si no existe
+func (l *loader) getEnvFloat(key string, fallback float64) float64 {
+	value, exists := l.lookup(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.addf("%s debe ser un número (valor: %q)", key, value)
+		return fallback
+	}
+	return f
+}
+
+// getEnvDuration obtiene una duración (ej: "30s", "5m") o retorna el valor por defecto si no existe
+func (l *loader) getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, exists := l.lookup(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.addf("%s debe ser una duración como 30s o 5m (valor: %q)", key, value)
+		return fallback
+	}
+	return d
+}
+
+// getEnvBool obtiene una variable booleana ("true", "1" o "yes" se consideran verdadero)
+func (l *loader) getEnvBool(key string, fallback bool) bool {
+	value, exists := l.lookup(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	return value == "true" || value == "1" || value == "yes"
+}
+
+// getEnvMap obtiene una lista de pares clave=valor separados por comas (ej: 1=online,2=offline)
+func (l *loader) getEnvMap(key string) map[string]string {
+	value := l.getEnv(key, "")
+	if value == "" {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, pair := range splitList(value) {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			l.addf("%s: se esperaba clave=valor, se obtuvo %q", key, pair)
+			continue
+		}
+		if _, dup := m[k]; dup {
+			l.addf("%s: la clave %q está repetida", key, k)
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// getEnvStageShares parsea pesos por etapa ("notion=30,ubersmith=30,zabbix=40").
+// Vacío = core.DefaultStageShares; una etapa omitida no tiene plazo propio.
+func (l *loader) getEnvStageShares(key string) map[core.Stage]int {
+	raw := l.getEnvMap(key)
+	if len(raw) == 0 {
+		return core.DefaultStageShares
+	}
+	shares := make(map[core.Stage]int, len(raw))
+	for name, value := range raw {
+		stage := core.Stage(strings.ToLower(name))
+		if stage != core.StageNotion && stage != core.StageUbersmith && stage != core.StageZabbix {
+			l.addf("%s: etapa desconocida %q (use notion, ubersmith o zabbix)", key, name)
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			l.addf("%s: el peso de %s debe ser un entero positivo (valor: %q)", key, name, value)
+			continue
+		}
+		shares[stage] = n
+	}
+	return shares
+}
+
+// getEnvKeyTemplate obtiene y valida una plantilla de key de Zabbix (ver core.ParseKeyTemplate)
+func (l *loader) getEnvKeyTemplate(key, fallback string) core.KeyTemplate {
+	tmpl, err := core.ParseKeyTemplate(l.getEnv(key, fallback))
+	if err != nil {
+		l.addf("%s inválida: %v", key, err)
+	}
+	return tmpl
+}
+
+// checkURL registra un problema si value no es una URL http(s) con host
+func (l *loader) checkURL(key, value string) {
+	if value == "" {
+		return // Si era requerida, ya se reportó como faltante
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		l.addf("%s debe ser una URL http(s) válida (valor: %q)", key, value)
+	}
+}
+
+// isIdentifier indica si s es un nombre de columna simple (letras, dígitos y guión bajo)
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitList separa una lista separada por comas, descartando elementos vacíos
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validEnv es el mínimo de variables con el que LoadFrom no reporta problemas
+func validEnv() map[string]string {
+	return map[string]string{
+		"DB_HOST":            "db.local",
+		"DB_USER":            "gpon",
+		"DB_PASS":            "db-pass",
+		"DB_NAME":            "inventario",
+		"NOTION_API_KEY":     "secret_notion",
+		"NOTION_DATABASE_ID": "db-1",
+		"ZABBIX_URL":         "https://zabbix.local/api_jsonrpc.php",
+		"ZABBIX_USER":        "api",
+		"ZABBIX_PASS":        "zbx-pass",
+		"UBERSMITH_URL":      "https://ubersmith.local/api/2.0/",
+		"UBERSMITH_USER":     "api",
+		"UBERSMITH_PASS":     "uber-pass",
+	}
+}
+
+// lookupMap adapta un map a LookupFunc
+func lookupMap(env map[string]string) LookupFunc {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestLoadFromValidEnvironment(t *testing.T) {
+	env := validEnv()
+	env["WORKER_COUNT"] = "8"
+	env["SHUTDOWN_GRACE"] = "45s"
+	env["NOTION_DATABASE_IDS"] = "db-1, db-2"
+
+	cfg, err := LoadFrom(lookupMap(env))
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+
+	if want := "gpon:db-pass@tcp(db.local:3306)/inventario?parseTime=true&charset=utf8mb4"; cfg.DatabaseURL != want {
+		t.Errorf("DatabaseURL = %q, se esperaba %q", cfg.DatabaseURL, want)
+	}
+	if cfg.WorkerCount != 8 || cfg.ShutdownGrace != 45*time.Second {
+		t.Errorf("WorkerCount/ShutdownGrace = %d/%s, se esperaba 8/45s", cfg.WorkerCount, cfg.ShutdownGrace)
+	}
+	if len(cfg.NotionDBIDs) != 2 || cfg.NotionDBIDs[1] != "db-2" {
+		t.Errorf("NotionDBIDs = %v, se esperaba [db-1 db-2]", cfg.NotionDBIDs)
+	}
+	// Valores por defecto
+	if cfg.BatchSize != 100 || cfg.DBTable != "circuitos" || cfg.SyncInterval != 5*time.Minute || cfg.DryRun {
+		t.Errorf("defaults inesperados: BatchSize=%d DBTable=%q SyncInterval=%s DryRun=%v",
+			cfg.BatchSize, cfg.DBTable, cfg.SyncInterval, cfg.DryRun)
+	}
+}
+
+func TestLoadFromInvalidEnvironment(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(env map[string]string)
+		want   string // Fragmento del problema reportado
+	}{
+		{"falta DB_HOST", func(env map[string]string) { delete(env, "DB_HOST") }, "'DB_HOST' no está definida"},
+		{"falta la base de Notion", func(env map[string]string) { delete(env, "NOTION_DATABASE_ID") }, "'NOTION_DATABASE_ID'"},
+		{"WORKER_COUNT no numérico", func(env map[string]string) { env["WORKER_COUNT"] = "abc" }, "WORKER_COUNT debe ser un número entero"},
+		{"WORKER_COUNT en cero", func(env map[string]string) { env["WORKER_COUNT"] = "0" }, "WORKER_COUNT debe ser al menos 1"},
+		{"SHUTDOWN_GRACE en cero", func(env map[string]string) { env["SHUTDOWN_GRACE"] = "0s" }, "SHUTDOWN_GRACE debe ser mayor que cero"},
+		{"duración inválida", func(env map[string]string) { env["SYNC_INTERVAL"] = "5 minutos" }, "SYNC_INTERVAL debe ser una duración"},
+		{"URL sin esquema", func(env map[string]string) { env["ZABBIX_URL"] = "zabbix.local" }, "ZABBIX_URL debe ser una URL http(s)"},
+		{"token y usuario de Zabbix", func(env map[string]string) { env["ZABBIX_API_TOKEN"] = "tok" }, "ZABBIX_API_TOKEN no se puede combinar"},
+		{"Zabbix sin credenciales", func(env map[string]string) {
+			delete(env, "ZABBIX_USER")
+			delete(env, "ZABBIX_PASS")
+		}, "Zabbix requiere ZABBIX_API_TOKEN"},
+		{"tabla con caracteres inválidos", func(env map[string]string) { env["DB_TABLE"] = "circuitos;DROP" }, "DB_TABLE: identificador inválido"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := validEnv()
+			tt.change(env)
+
+			cfg, err := LoadFrom(lookupMap(env))
+			if cfg != nil {
+				t.Error("con problemas de validación no debería devolverse configuración")
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("error = %v, se esperaba *ValidationError", err)
+			}
+			if !strings.Contains(strings.Join(verr.Problems, "\n"), tt.want) {
+				t.Errorf("problemas = %q, se esperaba uno con %q", verr.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFromReportsAllProblemsTogether(t *testing.T) {
+	env := validEnv()
+	delete(env, "DB_USER")
+	env["BATCH_SIZE"] = "0"
+	env["UBERSMITH_URL"] = "ftp://ubersmith.local"
+
+	_, err := LoadFrom(lookupMap(env))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, se esperaba *ValidationError", err)
+	}
+	if len(verr.Problems) != 3 {
+		t.Errorf("se reportaron %d problemas, se esperaban 3: %q", len(verr.Problems), verr.Problems)
+	}
+}
+
+func TestDumpHidesEverySecret(t *testing.T) {
+	withToken := validEnv()
+	delete(withToken, "ZABBIX_USER")
+	delete(withToken, "ZABBIX_PASS")
+	withToken["ZABBIX_API_TOKEN"] = "zbx-api-token-8f3a1c"
+
+	withPass := validEnv()
+	withPass["RESULT_SINK_ENABLED"] = "true"
+	withPass["RESULT_SINK_URL"] = "https://sink.local/results"
+	withPass["RESULT_SINK_TOKEN"] = "sink-token-77d2e0"
+
+	for name, env := range map[string]map[string]string{"token de Zabbix": withToken, "usuario y contraseña": withPass} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := LoadFrom(lookupMap(env))
+			if err != nil {
+				t.Fatalf("LoadFrom: %v", err)
+			}
+			dump := strings.Join(cfg.Dump(), "\n")
+
+			for _, key := range []string{"DB_PASS", "NOTION_API_KEY", "ZABBIX_PASS", "ZABBIX_API_TOKEN", "UBERSMITH_PASS", "RESULT_SINK_TOKEN"} {
+				if secret := env[key]; secret != "" && strings.Contains(dump, secret) {
+					t.Errorf("%s aparece en claro en el dump:\n%s", key, dump)
+				}
+			}
+			// Los valores derivados quedan visibles para diagnosticar
+			for _, want := range []string{"DatabaseURL=gpon:****@tcp(db.local:3306)/inventario", "SyncInterval=5m0s", "ZabbixURL=https://zabbix.local/api_jsonrpc.php"} {
+				if !strings.Contains(dump, want) {
+					t.Errorf("se esperaba %q en el dump:\n%s", want, dump)
+				}
+			}
+		})
+	}
+}
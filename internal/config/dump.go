@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"gpon-sync/internal/redact"
+)
+
+// secretFields son los campos sensibles de Config. Se enmascaran por nombre además de por valor
+// (secrets ignora valores muy cortos); un campo sensible nuevo debe agregarse aquí.
+var secretFields = map[string]bool{
+	"NotionKey":       true,
+	"ZabbixPass":      true,
+	"ZabbixAPIToken":  true,
+	"UbersmithPass":   true,
+	"ResultSinkToken": true,
+}
+
+// Dump devuelve la configuración efectiva como líneas "Campo=valor" (CONFIG_DUMP=true), con los
+// valores derivados ya resueltos (DSN, intervalos, listas) y los secretos enmascarados.
+// El DSN se muestra sin contraseña: usuario, host, puerto, base y parámetros quedan visibles.
+func (c *Config) Dump() []string {
+	secret := make(map[string]bool, len(c.secrets))
+	for _, s := range c.secrets {
+		secret[s] = true
+	}
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	lines := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := fmt.Sprintf("%v", v.Field(i).Interface())
+
+		switch {
+		case field.Name == "DatabaseURL":
+			value = redact.DSN(value)
+		case value == "":
+			// Un secreto vacío no revela nada y ayuda a ver que falta
+		case secret[value] || secretFields[field.Name]:
+			value = redact.Secret(value)
+		default:
+			// Un secreto puede aparecer dentro de otro valor (ej: una URL con credenciales)
+			value = redact.String(value)
+		}
+		lines = append(lines, field.Name+"="+value)
+	}
+	return lines
+}
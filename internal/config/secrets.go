@@ -0,0 +1,166 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SecretResolver obtiene el valor de una variable sensible (DB_PASS, NOTION_API_KEY, etc.)
+// desde un backend distinto del entorno. found=false si el backend no tiene ese secreto.
+type SecretResolver interface {
+	Resolve(key string) (value string, found bool, err error)
+}
+
+// Proveedores de SECRETS_PROVIDER
+const (
+	SecretsProviderEnv   = "env"
+	SecretsProviderFile  = "file"
+	SecretsProviderVault = "vault"
+)
+
+// secretKeys son las variables que se resuelven con el SecretResolver
+var secretKeys = map[string]bool{
+	"DB_PASS":           true,
+	"NOTION_API_KEY":    true,
+	"ZABBIX_PASS":       true,
+	"ZABBIX_API_TOKEN":  true,
+	"UBERSMITH_PASS":    true,
+	"RESULT_SINK_TOKEN": true,
+}
+
+// FileResolver lee cada secreto de un archivo con el nombre de la variable dentro de Dir
+// (ej: /run/secrets/DB_PASS o /run/secrets/db_pass, como los secretos de Docker o Kubernetes).
+// Se ignora el salto de línea final.
+type FileResolver struct {
+	Dir string
+}
+
+func (r FileResolver) Resolve(key string) (string, bool, error) {
+	for _, name := range []string{key, strings.ToLower(key)} {
+		data, err := os.ReadFile(filepath.Join(r.Dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("no se pudo leer el secreto %s: %w", key, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	return "", false, nil
+}
+
+// VaultResolver lee cada secreto con un GET a URL/<variable> (KV v2 de Vault:
+// {"data":{"data":{"value":"..."}}}), autenticado con el header X-Vault-Token
+type VaultResolver struct {
+	URL    string
+	Token  string
+	Client *http.Client // nil = cliente con timeout de 10s
+}
+
+func (r VaultResolver) Resolve(key string) (string, bool, error) {
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequest("GET", strings.TrimSuffix(r.URL, "/")+"/"+key, nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("X-Vault-Token", r.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("no se pudo leer el secreto %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("no se pudo leer el secreto %s: HTTP %d", key, resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Data struct {
+				Value *string `json:"value"`
+			} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", false, fmt.Errorf("respuesta inválida para el secreto %s: %w", key, err)
+	}
+	if body.Data.Data.Value == nil {
+		return "", false, fmt.Errorf("el secreto %s no tiene el campo \"value\"", key)
+	}
+	return *body.Data.Data.Value, true, nil
+}
+
+// newSecretResolver arma el resolver según SECRETS_PROVIDER (nil = env, el comportamiento por defecto)
+func (l *loader) newSecretResolver() SecretResolver {
+	switch provider := strings.ToLower(l.getEnv("SECRETS_PROVIDER", SecretsProviderEnv)); provider {
+	case SecretsProviderEnv, "":
+		return nil
+	case SecretsProviderFile:
+		return FileResolver{Dir: l.getEnv("SECRETS_DIR", "/run/secrets")}
+	case SecretsProviderVault:
+		url := l.getEnvRequired("SECRETS_URL")
+		l.checkURL("SECRETS_URL", url)
+		return VaultResolver{URL: url, Token: l.getEnvRequired("SECRETS_TOKEN")}
+	default:
+		l.addf("SECRETS_PROVIDER inválido: %q (use env, file o vault)", provider)
+		return nil
+	}
+}
+
+// getSecret obtiene una variable sensible. Orden: archivo de <KEY>_FILE (convención de
+// Docker/Kubernetes), SecretResolver y por último el entorno, para poder migrar los secretos de a uno.
+func (l *loader) getSecret(key string, required bool) string {
+	if path, ok := l.lookup(key + "_FILE"); ok && path != "" {
+		return l.readSecretFile(key, path, required)
+	}
+	if l.resolver != nil && secretKeys[key] {
+		value, found, err := l.resolver.Resolve(key)
+		if err != nil {
+			l.addf("%v", err)
+			return ""
+		}
+		if found {
+			if required && value == "" {
+				l.addf("El secreto %s está vacío", key)
+			}
+			return value
+		}
+	}
+	if required {
+		return l.getEnvRequired(key)
+	}
+	return l.getEnv(key, "")
+}
+
+// readSecretFile lee el valor de <KEY>_FILE. Tiene prioridad sobre <KEY>: si ambas están
+// definidas se avisa, porque probablemente quedó una variable vieja en el entorno.
+func (l *loader) readSecretFile(key, path string, required bool) string {
+	if _, both := l.lookup(key); both {
+		log.Printf("[WARN] %s y %s_FILE están definidas: se usa el archivo %s", key, key, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.addf("%s_FILE: no se pudo leer %s: %v", key, path, err)
+		return ""
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if required && value == "" {
+		l.addf("%s_FILE: el archivo %s está vacío", key, path)
+	}
+	return value
+}
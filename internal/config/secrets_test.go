@@ -0,0 +1,211 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSecret crea dir/name con el contenido dado
+func writeSecret(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileResolver(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret(t, dir, "DB_PASS", "db-from-file\n")
+	writeSecret(t, dir, "notion_api_key", "secret_from_file\r\n")
+	// Un directorio con el nombre del secreto no se puede leer como archivo
+	if err := os.Mkdir(filepath.Join(dir, "ZABBIX_PASS"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	r := FileResolver{Dir: dir}
+
+	tests := []struct {
+		key       string
+		want      string
+		wantFound bool
+		wantErr   bool
+	}{
+		{"DB_PASS", "db-from-file", true, false},
+		{"NOTION_API_KEY", "secret_from_file", true, false}, // nombre en minúsculas, sin \r\n final
+		{"UBERSMITH_PASS", "", false, false},
+		{"ZABBIX_PASS", "", false, true},
+	}
+	for _, tt := range tests {
+		value, found, err := r.Resolve(tt.key)
+		if value != tt.want || found != tt.wantFound || (err != nil) != tt.wantErr {
+			t.Errorf("Resolve(%s) = %q, %v, %v; se esperaba %q, %v, error: %v", tt.key, value, found, err, tt.want, tt.wantFound, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadFromFileSecretsProvider(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret(t, dir, "DB_PASS", "db-from-file\n")
+	writeSecret(t, dir, "NOTION_API_KEY", "secret_from_file")
+
+	env := validEnv()
+	env["SECRETS_PROVIDER"] = "file"
+	env["SECRETS_DIR"] = dir
+	// UBERSMITH_PASS no está en el directorio: se sigue leyendo del entorno
+
+	cfg, err := LoadFrom(lookupMap(env))
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if !strings.Contains(cfg.DatabaseURL, ":db-from-file@") {
+		t.Errorf("DatabaseURL = %q, se esperaba la contraseña del archivo", cfg.DatabaseURL)
+	}
+	if cfg.NotionKey != "secret_from_file" || cfg.UbersmithPass != "uber-pass" {
+		t.Errorf("NotionKey = %q, UbersmithPass = %q", cfg.NotionKey, cfg.UbersmithPass)
+	}
+}
+
+func TestLoadFromSecretsProviderErrors(t *testing.T) {
+	emptyDir := t.TempDir()
+	writeSecret(t, emptyDir, "DB_PASS", "\n")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"proveedor desconocido", map[string]string{"SECRETS_PROVIDER": "aws"}, "SECRETS_PROVIDER inválido"},
+		{"vault sin URL", map[string]string{"SECRETS_PROVIDER": "vault", "SECRETS_TOKEN": "t"}, "'SECRETS_URL'"},
+		{"secreto vacío", map[string]string{"SECRETS_PROVIDER": "file", "SECRETS_DIR": emptyDir}, "El secreto DB_PASS está vacío"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := validEnv()
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			_, err := LoadFrom(lookupMap(env))
+			var verr *ValidationError
+			if !errors.As(err, &verr) || !strings.Contains(strings.Join(verr.Problems, "\n"), tt.want) {
+				t.Errorf("error = %v, se esperaba un problema con %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/gpon/DB_PASS":
+			_, _ = w.Write([]byte(`{"data":{"data":{"value":"db-from-vault"}}}`))
+		case "/v1/secret/data/gpon/ZABBIX_PASS":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"sin campo value"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	r := VaultResolver{URL: srv.URL + "/v1/secret/data/gpon/", Token: "vault-token", Client: srv.Client()}
+
+	if value, found, err := r.Resolve("DB_PASS"); value != "db-from-vault" || !found || err != nil {
+		t.Errorf("Resolve(DB_PASS) = %q, %v, %v", value, found, err)
+	}
+	if _, found, err := r.Resolve("UBERSMITH_PASS"); found || err != nil {
+		t.Errorf("un 404 es un secreto que no está en Vault (found: %v, error: %v)", found, err)
+	}
+	if _, _, err := r.Resolve("ZABBIX_PASS"); err == nil || !strings.Contains(err.Error(), `"value"`) {
+		t.Errorf("error = %v, se esperaba que falte el campo value", err)
+	}
+	r.Token = "otro"
+	if _, _, err := r.Resolve("DB_PASS"); err == nil || !strings.Contains(err.Error(), "HTTP 403") {
+		t.Errorf("error = %v, se esperaba HTTP 403", err)
+	}
+}
+
+func TestLoadFromSecretFileConvention(t *testing.T) {
+	dir := t.TempDir()
+	dbPassFile := writeSecret(t, dir, "db_pass", "db-from-file\n")
+
+	tests := []struct {
+		name     string
+		env      func(env map[string]string)
+		wantPass string // contraseña en el DSN; vacío si se espera un problema de validación
+		wantWarn bool
+		wantErr  string
+	}{
+		{"archivo presente", func(env map[string]string) {
+			delete(env, "DB_PASS")
+			env["DB_PASS_FILE"] = dbPassFile
+		}, "db-from-file", false, ""},
+		{"archivo y variable: gana el archivo", func(env map[string]string) {
+			env["DB_PASS_FILE"] = dbPassFile
+		}, "db-from-file", true, ""},
+		{"archivo inexistente", func(env map[string]string) {
+			env["DB_PASS_FILE"] = filepath.Join(dir, "no-existe")
+		}, "", false, "DB_PASS_FILE: no se pudo leer"},
+		{"archivo vacío", func(env map[string]string) {
+			delete(env, "DB_PASS")
+			env["DB_PASS_FILE"] = writeSecret(t, dir, "empty", "")
+		}, "", false, "DB_PASS_FILE: el archivo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := validEnv()
+			tt.env(env)
+
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			cfg, err := LoadFrom(lookupMap(env))
+			if tt.wantErr != "" {
+				var verr *ValidationError
+				if !errors.As(err, &verr) || !strings.Contains(strings.Join(verr.Problems, "\n"), tt.wantErr) {
+					t.Errorf("error = %v, se esperaba un problema con %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadFrom: %v", err)
+			}
+			if !strings.Contains(cfg.DatabaseURL, ":"+tt.wantPass+"@") {
+				t.Errorf("DatabaseURL = %q, se esperaba la contraseña %q", cfg.DatabaseURL, tt.wantPass)
+			}
+			warned := strings.Contains(logs.String(), "DB_PASS y DB_PASS_FILE están definidas")
+			if warned != tt.wantWarn {
+				t.Errorf("advertencia = %v, se esperaba %v (log: %q)", warned, tt.wantWarn, logs.String())
+			}
+		})
+	}
+}
+
+func TestSecretFileAppliesToEverySecret(t *testing.T) {
+	dir := t.TempDir()
+	env := validEnv()
+	for key := range secretKeys {
+		delete(env, key)
+		env[key+"_FILE"] = writeSecret(t, dir, key, strings.ToLower(key)+"-from-file")
+	}
+	// ZABBIX_API_TOKEN no se combina con usuario y contraseña
+	delete(env, "ZABBIX_API_TOKEN_FILE")
+
+	cfg, err := LoadFrom(lookupMap(env))
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if cfg.NotionKey != "notion_api_key-from-file" || cfg.ZabbixPass != "zabbix_pass-from-file" || cfg.UbersmithPass != "ubersmith_pass-from-file" {
+		t.Errorf("NotionKey = %q, ZabbixPass = %q, UbersmithPass = %q", cfg.NotionKey, cfg.ZabbixPass, cfg.UbersmithPass)
+	}
+}
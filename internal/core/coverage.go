@@ -0,0 +1,57 @@
+// aqui clasificamos cuánto se pudo enriquecer cada circuito
+package core
+
+// Coverage es una categoría de cobertura de datos de un circuito, según sus errores estructurados
+type Coverage string
+
+const (
+	CoverageNotionNotFound Coverage = "notion_not_found" // Sin página en Notion (o sin OLT/ONT utilizables)
+	CoverageNoUbersmith    Coverage = "no_ubersmith"     // Ubersmith no devolvió credenciales PPPoE
+	CoverageNoZabbix       Coverage = "no_zabbix"        // Zabbix no devolvió status ni rx power
+	CoverageFull           Coverage = "full"             // Todas las etapas habilitadas aportaron datos
+)
+
+// Coverages es el orden en que se reportan las categorías
+var Coverages = []Coverage{CoverageNotionNotFound, CoverageNoUbersmith, CoverageNoZabbix, CoverageFull}
+
+// ClassifyCoverage devuelve las categorías de un resultado. Un circuito puede caer en varias
+// (ej: sin Ubersmith y sin Zabbix); CoverageFull solo aparece si no cae en ninguna otra.
+// Se basa en Error y Warnings, así que una etapa deshabilitada no cuenta como faltante.
+func ClassifyCoverage(d EnrichedData) []Coverage {
+	var out []Coverage
+	switch ErrorStage(d.Error) {
+	case StageNotion:
+		// Sin Notion no se consulta Zabbix: no tiene sentido contarlo también ahí
+		out = append(out, CoverageNotionNotFound)
+	case StageZabbix:
+		out = append(out, CoverageNoZabbix)
+	}
+
+	zabbixWarned := false
+	for _, w := range d.Warnings {
+		switch ErrorStage(w) {
+		case StageUbersmith:
+			out = appendCoverage(out, CoverageNoUbersmith)
+		case StageZabbix:
+			zabbixWarned = true
+		}
+	}
+	// Advertencias de Zabbix con algún dato (ej: status sin rx power) no son "sin datos"
+	if zabbixWarned && d.StatusGponRaw == "" && d.RxPower == "" {
+		out = appendCoverage(out, CoverageNoZabbix)
+	}
+
+	if len(out) == 0 {
+		out = append(out, CoverageFull)
+	}
+	return out
+}
+
+func appendCoverage(list []Coverage, c Coverage) []Coverage {
+	for _, existing := range list {
+		if existing == c {
+			return list
+		}
+	}
+	return append(list, c)
+}
@@ -1,6 +1,8 @@
 // aqui estamos definiendo las entidades y las interfaces
 package core
 
+import "context"
+
 type Circuit struct {
 	ID           int
 	CID          string // El circuit_id de la DB
@@ -12,31 +14,143 @@ type Circuit struct {
 // EnrichedData representa los datos enriquecidos de un circuito después del procesamiento
 type EnrichedData struct {
 	CircuitID     string
-	VLAN          string
+	OLT           string // Hostname de la OLT según Notion
+	ONT           string // ONT ID según Notion (ej: 1/2/3)
+	VLAN          string // Vacío si no se obtuvo o si no pasó la validación de rango
+	VLANID        int    // VLAN ya validada; 0 = sin VLAN válida
 	PPPoEUsername string
 	PPPoEPassword string
-	StatusGpon    string
+	StatusGpon    string // Status ya traducido según GPON_STATUS_MAP (si hay mapeo)
+	StatusGponRaw string // Valor tal cual lo guarda Zabbix (lastvalue)
 	RxPower       string
-	Error         error
+	Error         error   // Error que impidió completar el circuito (un *StageError)
+	Warnings      []error // Errores parciales que no impidieron continuar (ej: Ubersmith)
+
+	// La lectura de rx power se descartó por estar fuera de rango: se conserva el valor de la DB
+	RxPowerDiscarded bool
+
+	// Zabbix no devolvió el item en este ciclo (solo con WorkerPool.SetKeepUnresolved):
+	// se conserva el valor de la DB en lugar de escribirlo vacío
+	StatusUnresolved  bool
+	RxPowerUnresolved bool
+
+	// Todas las ONT de Notion cuando el circuito tiene más de una; ONT es la elegida según la ONTPolicy
+	ONTCandidates []string
+
+	// Problema activo más grave de la OLT en Zabbix (vacío si no hay o si la verificación está apagada)
+	HostProblem string
+}
+
+// KeepsCurrent indica si la columna field ("RxPower", "StatusGpon") debe conservar el valor
+// de la DB porque este ciclo no obtuvo un valor válido para ella
+func (d EnrichedData) KeepsCurrent(field string) bool {
+	switch field {
+	case "RxPower":
+		return d.RxPowerDiscarded || d.RxPowerUnresolved
+	case "StatusGpon":
+		return d.StatusUnresolved
+	}
+	return false
 }
 
 // Interfaces (Ports)
 type CircuitRepository interface {
-	FetchPendingCircuits() ([]Circuit, error)
-	UpdateCircuitBatch(data []EnrichedData) error
+	FetchPendingCircuits(ctx context.Context) ([]Circuit, error)
+	UpdateCircuitBatch(ctx context.Context, data []EnrichedData) (BatchResult, error)
+	// Valores guardados actualmente para los CIDs dados (los CIDs sin fila no aparecen en el map)
+	FetchCurrentValues(ctx context.Context, cids []string) (map[string]EnrichedData, error)
+}
+
+// BatchResult resume una escritura de batch en la DB
+type BatchResult struct {
+	Updated   int      // CIDs que existían en la tabla
+	Inserted  int      // CIDs nuevos insertados (solo con INSERT_MISSING)
+	Unmatched []string // CIDs sin fila en la DB: sus datos no se guardaron
+}
+
+// ResultSink publica cada resultado enriquecido hacia un consumidor externo (webhook, bus de mensajes).
+// Es secundario a la DB: un fallo al publicar no afecta la escritura.
+type ResultSink interface {
+	Publish(ctx context.Context, data EnrichedData) error
+}
+
+// NetworkInfo son los datos de red de un circuito según Notion y la página de la que salieron.
+// Los campos nuevos se agregan acá sin cambiar la firma de NotionClient.
+type NetworkInfo struct {
+	OLT         string // Hostname de la OLT
+	ONT         string // ONT ID (ej: 1/2/3)
+	PageID      string // ID de la página (para write-back y para correlacionar logs)
+	Description string // Texto de la columna Description que coincidió con el CID
+	VLAN        string // VLAN cargada en Notion (vacío si la base no tiene la columna); sin validar
 }
 
 type NotionClient interface {
-	// Ahora devuelve el Hostname de la OLT y el ONT ID (ej: 1/2/3)
-	GetNetworkInfo(circuitID string) (olt, ont string, err error)
+	// Devuelve la OLT y el ONT ID del circuito. Si la página se encontró pero le faltan datos,
+	// el error viene acompañado de PageID y Description.
+	GetNetworkInfo(ctx context.Context, circuitID string) (NetworkInfo, error)
+}
+
+// NetworkBatchLookup es opcional para el cliente de Notion: resuelve varios CIDs en pocas consultas
+// y deja los resultados listos para GetNetworkInfo. Los CIDs que no devuelve se buscan de a uno.
+type NetworkBatchLookup interface {
+	GetNetworkInfoBatch(ctx context.Context, cids []string) (map[string]NetworkInfo, error)
 }
 
 type ZabbixClient interface {
-	// Procesa la lógica de los números del ONT ID
-	GetOpticalInfo(oltHost, ontID string) (status, rx string, err error)
+	// Procesa la lógica de los números del ONT ID e indica qué items se encontraron
+	GetOpticalDetails(ctx context.Context, oltHost, ontID string) (OpticalInfo, error)
 }
 
 type UbersmithClient interface {
 	// Obtiene los detalles del servicio: credenciales PPPoE
-	GetServiceDetails(cid string) (user, pass string, err error)
+	GetServiceDetails(ctx context.Context, cid string) (user, pass string, err error)
+}
+
+// VLANLookup es opcional para el cliente de Ubersmith: si lo implementa, puede aportar la VLAN del servicio.
+// Devuelve "" sin error si el servicio no tiene VLAN cargada.
+type VLANLookup interface {
+	GetVLAN(ctx context.Context, cid string) (string, error)
+}
+
+// Problem es un problema activo de Zabbix en un host (ej: una OLT con un corte conocido)
+type Problem struct {
+	Severity    int    // 0 (sin clasificar) a 5 (desastre), como en Zabbix
+	Description string // Nombre del problema
+}
+
+// ProblemLookup es opcional para el cliente de Zabbix: si lo implementa, el worker pool
+// puede explicar una lectura vacía con los problemas activos de la OLT
+type ProblemLookup interface {
+	GetActiveProblems(ctx context.Context, host string) ([]Problem, error)
+}
+
+// PowerSource indica de dónde salió el valor de rx power en Zabbix
+type PowerSource string
+
+const (
+	PowerSourceExactKey PowerSource = "exact_key"            // Item "rx power:2/3"
+	PowerSourceJSON     PowerSource = "ms_item_ont_rx_power" // JSON con todas las ONT del puerto
+)
+
+// OpticalInfo es el resultado de Zabbix con diagnóstico: permite distinguir
+// "no hay item en Zabbix" de "el item existe pero no hay señal"
+type OpticalInfo struct {
+	Status    string // Etiqueta según el mapeo de códigos, o el valor crudo si no hay mapeo
+	StatusRaw string // lastvalue del item de status tal cual viene de Zabbix
+	RxPower   string
+
+	StatusKey   string // Key consultada para el status (ej: gpon_2_status)
+	PowerKey    string // Key consultada para la potencia (ej: rx power:2/3)
+	StatusFound bool
+	PowerFound  bool
+	PowerSource PowerSource // Vacío si no se encontró potencia
+
+	// El item existe pero su último valor es más viejo que ZABBIX_MAX_VALUE_AGE: se descartó
+	// (StatusFound / PowerFound quedan en false)
+	StatusStale bool
+	PowerStale  bool
+
+	// Falló la consulta de potencia (red o API): el status vale igual, pero la potencia
+	// no se sabe (no es lo mismo que un item inexistente)
+	PowerErr error
 }
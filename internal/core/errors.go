@@ -0,0 +1,86 @@
+// aqui definimos los errores estructurados del pipeline de enriquecimiento
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Stage identifica la etapa (fuente de datos) donde ocurrió un error
+type Stage string
+
+const (
+	StageNotion    Stage = "notion"
+	StageUbersmith Stage = "ubersmith"
+	StageZabbix    Stage = "zabbix"
+)
+
+// StageError asocia un error con la etapa que lo produjo, para poder contabilizarlo por fuente
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s error: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// ErrZabbixHostNotFound indica que la OLT no existe en Zabbix (no está monitoreada),
+// en lugar de devolver status y potencia vacíos sin explicación
+type ErrZabbixHostNotFound struct {
+	Host string
+}
+
+func (e *ErrZabbixHostNotFound) Error() string {
+	return fmt.Sprintf("la OLT %q no está monitoreada en Zabbix (host no encontrado)", e.Host)
+}
+
+// Errores de Ubersmith. Una falla de autenticación es fatal para el ciclo
+// (todas las consultas fallarían igual); un servicio inexistente afecta solo a ese circuito.
+var (
+	ErrUbersmithAuth        = errors.New("ubersmith: credenciales inválidas o sin permisos")
+	ErrUbersmithNotFound    = errors.New("ubersmith: servicio no encontrado")
+	ErrUbersmithBadResponse = errors.New("ubersmith: respuesta inválida")
+)
+
+// ErrNotionAuth indica que Notion rechazó la API key (401/403): todas las búsquedas fallarían igual
+var ErrNotionAuth = errors.New("notion: API key inválida o sin acceso a la base")
+
+// DataError marca un error de los datos de un circuito (no encontrado, ONT ID inválido,
+// propiedades faltantes): afecta solo a ese circuito y reintentar no lo resuelve,
+// a diferencia de una caída o de credenciales inválidas, que fallan para todos a la vez
+type DataError struct {
+	Err error
+}
+
+func (e *DataError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DataError) Unwrap() error {
+	return e.Err
+}
+
+// IsDataError indica si el error es propio del circuito (ver DataError). Un host que no
+// existe en Zabbix o un servicio inexistente en Ubersmith también cuentan.
+func IsDataError(err error) bool {
+	var de *DataError
+	if errors.As(err, &de) {
+		return true
+	}
+	var hostErr *ErrZabbixHostNotFound
+	return errors.As(err, &hostErr) || errors.Is(err, ErrUbersmithNotFound)
+}
+
+// ErrorStage devuelve la etapa de un error del pipeline, o "" si no es un StageError
+func ErrorStage(err error) Stage {
+	var se *StageError
+	if errors.As(err, &se) {
+		return se.Stage
+	}
+	return ""
+}
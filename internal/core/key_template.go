@@ -0,0 +1,70 @@
+// aqui renderizamos las keys de los items de Zabbix a partir del ONT ID
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Plantillas por defecto: el template de Zabbix actual ("rx power:2/3" y "gpon_2_status")
+const (
+	DefaultStatusKeyTemplate = "gpon_{second}_status"
+	DefaultPowerKeyTemplate  = "rx power:{second}/{third}"
+)
+
+// KeyTemplate es una plantilla de key de Zabbix ya validada, con placeholders {first}, {second} y {third}
+type KeyTemplate struct {
+	raw string
+}
+
+// ParseKeyTemplate valida una plantilla: solo se aceptan los placeholders conocidos,
+// las llaves deben estar balanceadas y debe haber al menos un placeholder
+// (una key fija coincidiría con el mismo item para todos los circuitos)
+func ParseKeyTemplate(tmpl string) (KeyTemplate, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return KeyTemplate{}, fmt.Errorf("plantilla vacía")
+	}
+
+	placeholders := 0
+	rest := tmpl
+	for {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			break
+		}
+		if rest[open] == '}' {
+			return KeyTemplate{}, fmt.Errorf("plantilla %q: '}' sin '{' de apertura", tmpl)
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return KeyTemplate{}, fmt.Errorf("plantilla %q: '{' sin cerrar", tmpl)
+		}
+		name := rest[open+1 : open+end]
+		switch name {
+		case "first", "second", "third":
+			placeholders++
+		default:
+			return KeyTemplate{}, fmt.Errorf("plantilla %q: placeholder desconocido {%s} (válidos: {first}, {second}, {third})", tmpl, name)
+		}
+		rest = rest[open+end+1:]
+	}
+
+	if placeholders == 0 {
+		return KeyTemplate{}, fmt.Errorf("plantilla %q: no contiene ningún placeholder ({first}, {second}, {third})", tmpl)
+	}
+	return KeyTemplate{raw: tmpl}, nil
+}
+
+// Render reemplaza los placeholders con las partes del ONT ID
+func (t KeyTemplate) Render(ont ONTID) string {
+	return strings.NewReplacer(
+		"{first}", ont.First,
+		"{second}", ont.Second,
+		"{third}", ont.Third,
+	).Replace(t.raw)
+}
+
+// String devuelve la plantilla sin renderizar
+func (t KeyTemplate) String() string {
+	return t.raw
+}
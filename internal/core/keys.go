@@ -0,0 +1,33 @@
+// aqui ordenamos las claves de los objetos JSON de las APIs para recorrerlos siempre igual
+package core
+
+import (
+	"sort"
+	"strconv"
+)
+
+// SortedKeys devuelve las claves de m en un orden fijo: primero las numéricas de menor a mayor
+// (los IDs de Ubersmith: "5" antes que "12") y después las demás en orden alfabético.
+// El orden de un map cambia entre ejecuciones: cuando varias claves califican, recorrerlas
+// así hace que gane siempre la misma.
+func SortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.ParseUint(keys[i], 10, 64)
+		b, errB := strconv.ParseUint(keys[j], 10, 64)
+		switch {
+		case errA == nil && errB == nil && a != b:
+			return a < b
+		case errA == nil && errB != nil:
+			return true
+		case errA != nil && errB == nil:
+			return false
+		}
+		// Dos claves no numéricas, o el mismo número escrito distinto ("05" y "5")
+		return keys[i] < keys[j]
+	})
+	return keys
+}
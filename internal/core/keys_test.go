@@ -0,0 +1,30 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSortedKeys(t *testing.T) {
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"12", "5", "100", "7"}, "[5 7 12 100]"},
+		{[]string{"value", "interface", "rx"}, "[interface rx value]"},
+		// Las numéricas primero; el mismo número escrito distinto, en orden de texto
+		{[]string{"b", "10", "a", "2", "05", "5"}, "[2 05 5 10 a b]"},
+		{nil, "[]"},
+	}
+	for _, tt := range tests {
+		m := make(map[string]interface{}, len(tt.keys))
+		for _, k := range tt.keys {
+			m[k] = true
+		}
+		for i := 0; i < 10; i++ {
+			if got := fmt.Sprint(SortedKeys(m)); got != tt.want {
+				t.Fatalf("SortedKeys(%v) = %s, se esperaba %s", tt.keys, got, tt.want)
+			}
+		}
+	}
+}
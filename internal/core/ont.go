@@ -0,0 +1,87 @@
+// aqui validamos el formato del ONT ID que viene de Notion
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ONTID es el ONT ID de Notion ya validado (ej: 1/2/3)
+type ONTID struct {
+	First  string // El "1"
+	Second string // El "2": se usa para el status (gpon_2_status)
+	Third  string // El "3": junto al segundo forma la key de potencia (rx power:2/3)
+
+	// Partes adicionales tal cual vienen (ej: "4" en 1/2/3/4). Se aceptan como siempre se
+	// aceptaron, pero no intervienen en las keys de Zabbix.
+	Extra []string
+}
+
+// String devuelve el ONT ID en su formato original 1/2/3 (con las partes adicionales, si las hay)
+func (o ONTID) String() string {
+	return strings.Join(append([]string{o.First, o.Second, o.Third}, o.Extra...), "/")
+}
+
+// ErrInvalidONTFormat indica que el valor de la columna </> de Notion no tiene el formato N/N/N.
+// Es un error de datos de Notion, no de Zabbix.
+type ErrInvalidONTFormat struct {
+	Value  string // Valor recibido tal cual
+	Reason string
+}
+
+func (e *ErrInvalidONTFormat) Error() string {
+	return fmt.Sprintf("formato ONT ID inválido %q: %s", e.Value, e.Reason)
+}
+
+// SplitONTList separa una lista de ONT IDs (ONTs redundantes o en bonding) separados por "," o ";".
+// Un valor sin separadores devuelve una lista de un solo elemento.
+func SplitONTList(value string) []string {
+	var onts []string
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			onts = append(onts, part)
+		}
+	}
+	if len(onts) == 0 {
+		return []string{value}
+	}
+	return onts
+}
+
+// ONTPolicy decide qué ONT usar cuando un circuito tiene varias
+type ONTPolicy string
+
+const (
+	ONTPolicyActive  ONTPolicy = "active"   // La primera ONT con rx power (en el orden de Notion)
+	ONTPolicyWorstRx ONTPolicy = "worst_rx" // La ONT con peor rx power, para no ocultar una degradación
+)
+
+// ParseONTID valida y separa un ONT ID con formato N/N/N (tres números separados por "/").
+// Se aceptan partes adicionales (N/N/N/N): solo se validan las tres que se usan.
+func ParseONTID(value string) (ONTID, error) {
+	parts := strings.Split(strings.TrimSpace(value), "/")
+	if len(parts) < 3 {
+		return ONTID{}, &ErrInvalidONTFormat{
+			Value:  value,
+			Reason: fmt.Sprintf("se esperaban al menos 3 partes, se obtuvieron %d", len(parts)),
+		}
+	}
+
+	for i, p := range parts[:3] {
+		p = strings.TrimSpace(p)
+		if _, err := strconv.ParseUint(p, 10, 32); err != nil {
+			return ONTID{}, &ErrInvalidONTFormat{
+				Value:  value,
+				Reason: fmt.Sprintf("la parte %d (%q) no es numérica", i+1, p),
+			}
+		}
+		parts[i] = p
+	}
+
+	ont := ONTID{First: parts[0], Second: parts[1], Third: parts[2]}
+	if len(parts) > 3 {
+		ont.Extra = parts[3:]
+	}
+	return ont, nil
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseONTID(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string // ONTID.String(); vacío si se espera error
+		second  string
+		third   string
+		wantErr bool
+	}{
+		{name: "bien formado", value: "1/2/3", want: "1/2/3", second: "2", third: "3"},
+		{name: "con espacios", value: " 1 / 12 / 30 ", want: "1/12/30", second: "12", third: "30"},
+		{name: "partes adicionales", value: "1/2/3/4", want: "1/2/3/4", second: "2", third: "3"},
+		{name: "muy corto", value: "1/2", wantErr: true},
+		{name: "vacío", value: "", wantErr: true},
+		{name: "no numérico", value: "1/a/3", wantErr: true},
+		{name: "negativo", value: "1/-2/3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ont, err := ParseONTID(tt.value)
+			if tt.wantErr {
+				var formatErr *ErrInvalidONTFormat
+				if !errors.As(err, &formatErr) {
+					t.Fatalf("ParseONTID(%q) error = %v, se esperaba ErrInvalidONTFormat", tt.value, err)
+				}
+				if formatErr.Value != tt.value {
+					t.Errorf("Value = %q, se esperaba %q", formatErr.Value, tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseONTID(%q) error inesperado: %v", tt.value, err)
+			}
+			if ont.String() != tt.want || ont.Second != tt.second || ont.Third != tt.third {
+				t.Errorf("ParseONTID(%q) = %+v, se esperaba %s", tt.value, ont, tt.want)
+			}
+		})
+	}
+}
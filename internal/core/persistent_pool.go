@@ -0,0 +1,77 @@
+// aqui implementamos el modo de workers persistentes del pool
+package core
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// task es un circuito de un Run junto al estado de ese Run
+type task struct {
+	job
+	run *poolRun
+}
+
+// poolRun es el estado de un Run atendido por los workers persistentes
+type poolRun struct {
+	ctx     context.Context
+	stats   *RunStats
+	abort   context.CancelCauseFunc
+	raw     chan<- indexedResult
+	pending sync.WaitGroup // Circuitos entregados a los workers que todavía no terminaron
+}
+
+// Start lanza workerCount workers que viven hasta que ctx se cancela (el apagado del proceso).
+// Desde entonces Run no crea goroutines por ciclo: entrega los circuitos a estos workers.
+// Llamar antes del primer Run y una sola vez.
+func (wp *WorkerPool) Start(ctx context.Context) {
+	// Sin buffer: un circuito solo se entrega cuando un worker lo toma, así un Run cancelado
+	// no deja circuitos encolados que nadie va a procesar
+	wp.tasks = make(chan task)
+	for i := 0; i < wp.workerCount; i++ {
+		go wp.persistentWorker(ctx)
+	}
+	log.Printf("♻️  Worker pool persistente: %d workers reutilizados entre ciclos", wp.workerCount)
+}
+
+func (wp *WorkerPool) persistentWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-wp.tasks:
+			wp.runTask(t)
+		}
+	}
+}
+
+// runTask procesa un circuito de un Run. Un Run ya cancelado no procesa más circuitos.
+func (wp *WorkerPool) runTask(t task) {
+	defer t.run.pending.Done()
+	if t.run.ctx.Err() != nil {
+		return
+	}
+	callCtx, cancel := wp.callContext(t.run.ctx)
+	defer cancel()
+	wp.handleJob(callCtx, t.job, t.run.stats, t.run.abort, t.run.raw)
+}
+
+// submit entrega los circuitos de un Run a los workers persistentes y cierra raw
+// cuando todos los entregados terminaron
+func (wp *WorkerPool) submit(run *poolRun, circuits []Circuit) {
+	defer close(run.raw)
+	for i, c := range circuits {
+		run.pending.Add(1)
+		select {
+		case wp.tasks <- task{job: job{index: i, circuit: c}, run: run}:
+		case <-run.ctx.Done():
+			run.pending.Done()
+			run.pending.Wait()
+			run.abort(nil)
+			return
+		}
+	}
+	run.pending.Wait()
+	run.abort(nil)
+}
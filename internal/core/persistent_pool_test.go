@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// goroutinesSettleAt espera a que la cantidad de goroutines baje a want o menos
+func goroutinesSettleAt(want int) int {
+	deadline := time.Now().Add(2 * time.Second)
+	n := runtime.NumGoroutine()
+	for n > want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	return n
+}
+
+func TestPersistentPoolHandlesSequentialBatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notion := &stubNotion{}
+	pool := NewWorkerPool(4, notion, stubZabbix{}, stubUbersmith{})
+	pool.SetOrdered(true)
+	pool.Start(ctx)
+	started := runtime.NumGoroutine()
+
+	for batch := 1; batch <= 3; batch++ {
+		circuits := testCircuits(10 * batch)
+		results, stats := pool.Run(context.Background(), circuits)
+		got := collectCIDs(results)
+		if len(got) != len(circuits) {
+			t.Fatalf("batch %d: %d resultados, se esperaban %d", batch, len(got), len(circuits))
+		}
+		for i, c := range circuits {
+			if got[i] != c.CID {
+				t.Fatalf("batch %d: resultado %d = %s, se esperaba %s", batch, i, got[i], c.CID)
+			}
+		}
+		if stats.Aborted() != nil {
+			t.Fatalf("batch %d abortado: %v", batch, stats.Aborted())
+		}
+		// Al terminar un Run solo quedan los workers persistentes
+		if n := goroutinesSettleAt(started); n > started {
+			t.Errorf("batch %d: quedaron %d goroutines, se esperaban %d", batch, n, started)
+		}
+	}
+	if n := notion.calls.Load(); n != 60 {
+		t.Errorf("Notion se consultó %d veces, se esperaban 60", n)
+	}
+
+	// El apagado del proceso termina los 4 workers
+	cancel()
+	if n := goroutinesSettleAt(started - 4); n > started-4 {
+		t.Errorf("después del apagado quedaron %d goroutines, se esperaban %d", n, started-4)
+	}
+}
+
+func TestPersistentPoolCancelledRunDoesNotBlockTheNext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notion := &stubNotion{delay: func(string) time.Duration { return 20 * time.Millisecond }}
+	pool := NewWorkerPool(2, notion, stubZabbix{}, stubUbersmith{})
+	pool.Start(ctx)
+
+	// Un Run cancelado a mitad de camino no deja circuitos encolados para el siguiente
+	runCtx, cancelRun := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	results, _ := pool.Run(runCtx, testCircuits(50))
+	partial := len(collectCIDs(results))
+	cancelRun()
+	if partial >= 50 {
+		t.Fatalf("el Run cancelado procesó %d circuitos, se esperaba que se cortara antes", partial)
+	}
+
+	results, _ = pool.Run(context.Background(), testCircuits(5))
+	if got := collectCIDs(results); len(got) != 5 {
+		t.Errorf("el Run siguiente devolvió %d resultados, se esperaban 5", len(got))
+	}
+}
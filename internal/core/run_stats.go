@@ -0,0 +1,130 @@
+// aqui acumulamos métricas de una ejecución del worker pool
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// RunStats son las métricas de una ejecución de WorkerPool.Run.
+// Los workers las actualizan concurrentemente; los valores son definitivos cuando se cierra el canal de resultados.
+type RunStats struct {
+	mu              sync.Mutex
+	started         time.Time
+	duration        time.Duration
+	stageTotal      map[Stage]time.Duration
+	stageCalls      map[Stage]int
+	partialFailures int
+	aborted         error
+	inFlight        map[string]Stage // CIDs en proceso y la etapa en la que están
+}
+
+func newRunStats() *RunStats {
+	return &RunStats{
+		started:    time.Now(),
+		stageTotal: make(map[Stage]time.Duration),
+		stageCalls: make(map[Stage]int),
+		inFlight:   make(map[string]Stage),
+	}
+}
+
+// enter registra que el circuito está consultando una etapa
+func (s *RunStats) enter(cid string, stage Stage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight[cid] = stage
+}
+
+// leave registra que el circuito terminó de procesarse
+func (s *RunStats) leave(cid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, cid)
+}
+
+// InFlight devuelve los circuitos que se están procesando y la etapa en la que están
+// (ej: para saber dónde quedaron trabados los circuitos de un ciclo que no termina)
+func (s *RunStats) InFlight() map[string]Stage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := make(map[string]Stage, len(s.inFlight))
+	for cid, stage := range s.inFlight {
+		snapshot[cid] = stage
+	}
+	return snapshot
+}
+
+// observe registra la duración de una llamada a una etapa (exitosa o no)
+func (s *RunStats) observe(stage Stage, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stageTotal[stage] += d
+	s.stageCalls[stage]++
+}
+
+// recordResult contabiliza un circuito terminado con errores parciales
+func (s *RunStats) recordResult(res EnrichedData) {
+	if len(res.Warnings) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partialFailures++
+}
+
+// abort registra el error que detuvo la ejecución antes de procesar todos los circuitos
+func (s *RunStats) abort(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aborted == nil {
+		s.aborted = err
+	}
+}
+
+// Aborted devuelve el error fatal que detuvo la ejecución, o nil
+func (s *RunStats) Aborted() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.aborted
+}
+
+// finish fija la duración total (se llama al cerrar el canal de resultados)
+func (s *RunStats) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.duration = time.Since(s.started)
+}
+
+// Duration devuelve la duración total de la ejecución, o el tiempo transcurrido si sigue en curso
+func (s *RunStats) Duration() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.duration == 0 {
+		return time.Since(s.started)
+	}
+	return s.duration
+}
+
+// AvgLatency devuelve la latencia promedio de las llamadas a una etapa (0 si no hubo llamadas)
+func (s *RunStats) AvgLatency(stage Stage) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stageCalls[stage] == 0 {
+		return 0
+	}
+	return s.stageTotal[stage] / time.Duration(s.stageCalls[stage])
+}
+
+// Calls devuelve la cantidad de llamadas registradas para una etapa
+func (s *RunStats) Calls(stage Stage) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stageCalls[stage]
+}
+
+// PartialFailures devuelve la cantidad de circuitos con errores parciales (advertencias)
+func (s *RunStats) PartialFailures() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.partialFailures
+}
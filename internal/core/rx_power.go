@@ -0,0 +1,41 @@
+// aqui validamos la potencia óptica antes de que llegue a la DB
+package core
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// Rango de rx power plausible para una ONT GPON (dBm). Fuera de él suele ser una lectura vieja o un sensor roto.
+const (
+	DefaultRxPowerMin = -40.0
+	DefaultRxPowerMax = -5.0
+)
+
+// RxPowerRange es el rango de potencias aceptadas, en dBm
+type RxPowerRange struct {
+	Min float64
+	Max float64
+}
+
+// rxPowerNumber es el número al comienzo de un valor de rx power; lo que sigue (unidad, paréntesis,
+// saltos de línea) se ignora
+var rxPowerNumber = regexp.MustCompile(`^\s*([-+]?\d+(\.\d+)?)`)
+
+// ParseRxPower convierte un valor como "-20.4 dBm" (o "-20.4", o con otra unidad de RXPOWER_UNIT) a número
+func ParseRxPower(value string) (float64, bool) {
+	m := rxPowerNumber.FindStringSubmatch(value)
+	if m == nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// Contains indica si la potencia está dentro del rango
+func (r RxPowerRange) Contains(dbm float64) bool {
+	return dbm >= r.Min && dbm <= r.Max
+}
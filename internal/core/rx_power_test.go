@@ -0,0 +1,41 @@
+package core
+
+import "testing"
+
+func TestParseRxPower(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		ok    bool
+	}{
+		{"-20.4", -20.4, true},
+		{"-20.4 dBm", -20.4, true},
+		{"-21.5dBm", -21.5, true},
+		{"-21.5 dBm)", -21.5, true},
+		{"-21.5dBm\n", -21.5, true},
+		{"  -7 dBm", -7, true},
+		{"+3.25", 3.25, true},
+		{"", 0, false},
+		{"dBm", 0, false},
+		{"n/a", 0, false},
+		{"-.5", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRxPower(tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseRxPower(%q) = %v, %v; se esperaba %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRxPowerRangeContains(t *testing.T) {
+	r := RxPowerRange{Min: DefaultRxPowerMin, Max: DefaultRxPowerMax}
+	for _, tt := range []struct {
+		dbm  float64
+		want bool
+	}{{-40, true}, {-5, true}, {-20.4, true}, {-40.1, false}, {0, false}} {
+		if got := r.Contains(tt.dbm); got != tt.want {
+			t.Errorf("Contains(%v) = %v, se esperaba %v", tt.dbm, got, tt.want)
+		}
+	}
+}
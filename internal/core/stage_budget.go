@@ -0,0 +1,58 @@
+// aqui repartimos el tiempo máximo de un circuito entre las etapas
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// StageBudget reparte un tiempo total por circuito entre Notion, Ubersmith y Zabbix según Shares
+// (pesos relativos, ej: 30/30/40). Cada etapa tiene su propio plazo: si Notion agota su parte,
+// Ubersmith y Zabbix conservan la suya.
+type StageBudget struct {
+	Total  time.Duration // 0 = sin límite por circuito
+	Shares map[Stage]int
+}
+
+// DefaultStageShares da a Zabbix un poco más: puede consultar varias ONTs y los problemas de la OLT
+var DefaultStageShares = map[Stage]int{
+	StageNotion:    30,
+	StageUbersmith: 30,
+	StageZabbix:    40,
+}
+
+// SetStageBudget fija el tiempo máximo por circuito y su reparto entre etapas
+func (wp *WorkerPool) SetStageBudget(b StageBudget) {
+	wp.budget = b
+}
+
+// stageTimeout devuelve el plazo de una etapa (0 = sin límite). Las etapas deshabilitadas
+// no consumen presupuesto: su parte se reparte entre las demás.
+func (wp *WorkerPool) stageTimeout(stage Stage) time.Duration {
+	if wp.budget.Total <= 0 {
+		return 0
+	}
+	shares := wp.budget.Shares
+	if len(shares) == 0 {
+		shares = DefaultStageShares
+	}
+	sum := 0
+	for s, w := range shares {
+		if !wp.disabled[s] {
+			sum += w
+		}
+	}
+	if sum <= 0 || shares[stage] <= 0 {
+		return 0
+	}
+	return wp.budget.Total * time.Duration(shares[stage]) / time.Duration(sum)
+}
+
+// stageContext deriva del contexto del circuito el contexto de una etapa, con su plazo
+func (wp *WorkerPool) stageContext(ctx context.Context, stage Stage) (context.Context, context.CancelFunc) {
+	d := wp.stageTimeout(stage)
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
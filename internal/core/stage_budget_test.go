@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deadlines registra el tiempo que le quedaba a cada etapa al empezar (0 = sin plazo)
+type deadlines struct {
+	mu        sync.Mutex
+	remaining map[Stage]time.Duration
+}
+
+func (d *deadlines) record(ctx context.Context, stage Stage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.remaining == nil {
+		d.remaining = make(map[Stage]time.Duration)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		d.remaining[stage] = time.Until(deadline)
+	}
+}
+
+// budgetNotion tarda use; budgetUbersmith agota su plazo completo
+type budgetNotion struct {
+	d   *deadlines
+	use time.Duration
+}
+
+func (n budgetNotion) GetNetworkInfo(ctx context.Context, cid string) (NetworkInfo, error) {
+	n.d.record(ctx, StageNotion)
+	time.Sleep(n.use)
+	return NetworkInfo{OLT: "OLT-" + cid, ONT: "1/2/3"}, nil
+}
+
+type budgetUbersmith struct{ d *deadlines }
+
+func (u budgetUbersmith) GetServiceDetails(ctx context.Context, cid string) (string, string, error) {
+	u.d.record(ctx, StageUbersmith)
+	<-ctx.Done()
+	return "", "", ctx.Err()
+}
+
+type budgetZabbix struct{ d *deadlines }
+
+func (z budgetZabbix) GetOpticalDetails(ctx context.Context, olt, ont string) (OpticalInfo, error) {
+	z.d.record(ctx, StageZabbix)
+	return OpticalInfo{Status: "1", RxPower: "-20.00", StatusFound: true, PowerFound: true}, nil
+}
+
+func TestStageBudgetGivesEachStageItsDeadline(t *testing.T) {
+	d := &deadlines{}
+	pool := NewWorkerPool(1, budgetNotion{d: d, use: 200 * time.Millisecond}, budgetZabbix{d: d}, budgetUbersmith{d: d})
+	pool.SetStageBudget(StageBudget{Total: time.Second}) // 30/30/40: 300ms, 300ms, 400ms
+
+	results, _ := pool.Run(context.Background(), testCircuits(1))
+	res := <-results
+	if res.Error != nil {
+		t.Fatalf("error inesperado: %v", res.Error)
+	}
+	// Ubersmith agotó su plazo: es una advertencia y Zabbix igual se consulta
+	if len(res.Warnings) != 1 || ErrorStage(res.Warnings[0]) != StageUbersmith {
+		t.Errorf("advertencias = %v, se esperaba el timeout de Ubersmith", res.Warnings)
+	}
+
+	// Cada plazo se cuenta desde que empieza la etapa: lo que usó la anterior no se descuenta
+	want := map[Stage]time.Duration{StageNotion: 300 * time.Millisecond, StageUbersmith: 300 * time.Millisecond, StageZabbix: 400 * time.Millisecond}
+	for stage, w := range want {
+		got, ok := d.remaining[stage]
+		if !ok {
+			t.Errorf("%s: sin plazo, se esperaba %s", stage, w)
+			continue
+		}
+		if got > w || got < w-50*time.Millisecond {
+			t.Errorf("%s: plazo = %s, se esperaba ~%s", stage, got.Round(time.Millisecond), w)
+		}
+	}
+}
+
+func TestStageTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   StageBudget
+		disabled Stage
+		want     map[Stage]time.Duration
+	}{
+		{"sin presupuesto", StageBudget{}, "",
+			map[Stage]time.Duration{StageNotion: 0, StageUbersmith: 0, StageZabbix: 0}},
+		{"reparto por defecto", StageBudget{Total: 10 * time.Second}, "",
+			map[Stage]time.Duration{StageNotion: 3 * time.Second, StageUbersmith: 3 * time.Second, StageZabbix: 4 * time.Second}},
+		{"reparto configurado", StageBudget{Total: 10 * time.Second, Shares: map[Stage]int{StageNotion: 1, StageUbersmith: 1, StageZabbix: 2}}, "",
+			map[Stage]time.Duration{StageNotion: 2500 * time.Millisecond, StageUbersmith: 2500 * time.Millisecond, StageZabbix: 5 * time.Second}},
+		// La parte de una etapa deshabilitada se reparte entre las demás
+		{"Ubersmith deshabilitada", StageBudget{Total: 7 * time.Second}, StageUbersmith,
+			map[Stage]time.Duration{StageNotion: 3 * time.Second, StageZabbix: 4 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewWorkerPool(1, &stubNotion{}, stubZabbix{}, stubUbersmith{})
+			pool.SetStageBudget(tt.budget)
+			if tt.disabled != "" {
+				pool.SetStageEnabled(tt.disabled, false)
+			}
+			for stage, want := range tt.want {
+				if got := pool.stageTimeout(stage); got != want {
+					t.Errorf("%s: plazo = %s, se esperaba %s", stage, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// aqui validamos la VLAN antes de que llegue a la DB
+package core
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Rango válido de VLAN IDs según 802.1Q (0 y 4095 están reservados)
+const (
+	DefaultVLANMin = 1
+	DefaultVLANMax = 4094
+)
+
+// DefaultVLANSources es el orden de preferencia por defecto: Ubersmith y, si no tiene VLAN, Notion
+var DefaultVLANSources = []Stage{StageUbersmith, StageNotion}
+
+// VLANRange es el rango de VLAN IDs aceptados
+type VLANRange struct {
+	Min int
+	Max int
+}
+
+// ParseVLAN convierte la VLAN a entero. ok es false si no es un número o está fuera de rango
+// (ej: un teléfono o un custom field largo capturado por error).
+func (r VLANRange) ParseVLAN(value string) (vlan int, ok bool) {
+	vlan, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || vlan < r.Min || vlan > r.Max {
+		return 0, false
+	}
+	return vlan, true
+}
@@ -2,9 +2,13 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
+	"time"
 )
 
 type WorkerPool struct {
@@ -12,83 +16,622 @@ type WorkerPool struct {
 	notion      NotionClient
 	zabbix      ZabbixClient
 	ubersmith   UbersmithClient
+	vlanRange   VLANRange
+	rxRange     RxPowerRange
+
+	// Fuentes de la VLAN en orden de preferencia (StageUbersmith, StageNotion)
+	vlanSources []Stage
+
+	// Qué ONT se usa cuando un circuito tiene varias (por defecto ONTPolicyActive)
+	ontPolicy ONTPolicy
+
+	// Severidad mínima de los problemas activos de la OLT que se reportan (-1 = no se consultan)
+	problemSeverity int
+
+	// Entregar los resultados en el orden de entrada en lugar del orden en que terminan
+	ordered bool
+
+	// Etapas deshabilitadas: no se consultan y sus campos quedan vacíos
+	disabled map[Stage]bool
+
+	// Tamaño de los buffers de circuitos y resultados (0 = workerCount)
+	queueBuffer int
+
+	// Tiempo máximo por circuito repartido entre etapas (Total 0 = sin límite)
+	budget StageBudget
+
+	// Resolver Notion en lote antes de repartir los circuitos (ver Prefetch)
+	prefetch bool
+
+	// Conservar en la DB los campos que Zabbix no devolvió en lugar de escribirlos vacíos
+	keepUnresolved bool
+
+	// Cola de los workers persistentes (nil = Run crea sus propios workers, ver Start)
+	tasks chan task
+
+	// Un rechazo de la API key de Notion no detiene el ciclo (por defecto sí lo detiene)
+	notionAuthNonFatal bool
+
+	// Margen que tienen las llamadas en curso después del plazo del ciclo (ver callContext)
+	callGrace time.Duration
+
+	// Logs [DEBUG] por circuito (VERBOSE)
+	verbose bool
+}
+
+// job es un circuito junto a su posición en la entrada
+type job struct {
+	index   int
+	circuit Circuit
 }
 
+// indexedResult es un resultado junto a la posición de su circuito en la entrada
+type indexedResult struct {
+	index int
+	res   EnrichedData
+}
+
+// NewWorkerPool crea el pool. count se lleva a 1 si es menor: con 0 workers Run terminaría
+// sin procesar nada y sin error (la config ya lo valida; esto cubre a otros llamadores).
 func NewWorkerPool(count int, n NotionClient, z ZabbixClient, u UbersmithClient) *WorkerPool {
+	if count < 1 {
+		log.Printf("[WARN] WorkerPool: cantidad de workers inválida (%d), se usa 1", count)
+		count = 1
+	}
 	return &WorkerPool{
 		workerCount: count,
 		notion:      n,
 		zabbix:      z,
 		ubersmith:   u,
+		vlanRange:   VLANRange{Min: DefaultVLANMin, Max: DefaultVLANMax},
+		rxRange:     RxPowerRange{Min: DefaultRxPowerMin, Max: DefaultRxPowerMax},
+		vlanSources: DefaultVLANSources,
+		ontPolicy:   ONTPolicyActive,
+
+		problemSeverity: -1,
 	}
 }
 
-func (wp *WorkerPool) Run(circuits []Circuit) <-chan EnrichedData {
-	jobs := make(chan Circuit, len(circuits))
-	results := make(chan EnrichedData, len(circuits))
+// SetVLANRange cambia el rango de VLANs aceptadas (por defecto 1-4094)
+func (wp *WorkerPool) SetVLANRange(r VLANRange) {
+	wp.vlanRange = r
+}
 
-	for _, c := range circuits {
-		jobs <- c
+// SetVLANSources define de qué etapas se toma la VLAN, en orden de preferencia:
+// se usa la primera que tenga un valor (por defecto DefaultVLANSources)
+func (wp *WorkerPool) SetVLANSources(sources []Stage) {
+	wp.vlanSources = sources
+}
+
+// SetONTPolicy define qué ONT se usa cuando Notion tiene varias para un circuito
+func (wp *WorkerPool) SetONTPolicy(p ONTPolicy) {
+	wp.ontPolicy = p
+}
+
+// SetProblemCheck habilita la consulta de problemas activos de la OLT con severidad >= minSeverity.
+// Un valor negativo la deshabilita (es una request extra por OLT en cada ciclo).
+func (wp *WorkerPool) SetProblemCheck(minSeverity int) {
+	wp.problemSeverity = minSeverity
+}
+
+// SetRxPowerRange cambia el rango de rx power aceptado (por defecto -40 a -5 dBm)
+func (wp *WorkerPool) SetRxPowerRange(r RxPowerRange) {
+	wp.rxRange = r
+}
+
+// SetOrdered hace que Run entregue los resultados en el mismo orden que los circuitos de entrada.
+// Costo: un resultado que termina antes que los anteriores queda en memoria hasta que estos terminen;
+// en el peor caso (el primer circuito es el más lento) se retienen casi todos los resultados del ciclo.
+func (wp *WorkerPool) SetOrdered(ordered bool) {
+	wp.ordered = ordered
+}
+
+// SetNotionAuthFatal define si un 401/403 de Notion detiene el ciclo (por defecto sí):
+// con una key inválida cada circuito fallaría con el mismo error
+func (wp *WorkerPool) SetNotionAuthFatal(fatal bool) {
+	wp.notionAuthNonFatal = !fatal
+}
+
+// SetVerbose habilita los logs [DEBUG] por circuito (página de Notion elegida, ONT seleccionada)
+func (wp *WorkerPool) SetVerbose(verbose bool) {
+	wp.verbose = verbose
+}
+
+// SetKeepUnresolved hace que un status o rx power que Zabbix no devolvió (item inexistente
+// o sin dato) conserve el valor de la DB en lugar de escribirse vacío
+func (wp *WorkerPool) SetKeepUnresolved(keep bool) {
+	wp.keepUnresolved = keep
+}
+
+// SetNetworkPrefetch habilita la búsqueda en lote en Notion antes de cada Run,
+// si el cliente implementa NetworkBatchLookup
+func (wp *WorkerPool) SetNetworkPrefetch(enabled bool) {
+	wp.prefetch = enabled
+}
+
+// Prefetch resuelve en lote los datos de Notion de los circuitos. Un fallo no detiene el ciclo:
+// los circuitos que no se resolvieron se buscan de a uno en el worker.
+func (wp *WorkerPool) Prefetch(ctx context.Context, circuits []Circuit) {
+	if !wp.prefetch || wp.disabled[StageNotion] {
+		return
+	}
+	lookup, ok := wp.notion.(NetworkBatchLookup)
+	if !ok {
+		return
 	}
-	close(jobs)
+	cids := make([]string, len(circuits))
+	for i, c := range circuits {
+		cids[i] = c.CID
+	}
+
+	start := time.Now()
+	found, err := lookup.GetNetworkInfoBatch(ctx, cids)
+	if err != nil {
+		log.Printf("[WARN] Notion: la búsqueda en lote falló, se continúa de a uno: %v", err)
+	}
+	log.Printf("📚 Notion: %d de %d circuitos resueltos en lote (%s); el resto se busca de a uno",
+		len(found), len(cids), time.Since(start).Round(time.Millisecond))
+}
+
+// SetCallGrace define cuánto pueden seguir las llamadas en curso después del plazo del ciclo
+// (MAX_RUN_DURATION). Conviene que coincida con el tiempo que la App espera los circuitos en curso.
+func (wp *WorkerPool) SetCallGrace(d time.Duration) {
+	wp.callGrace = d
+}
+
+// callContext es el contexto de las llamadas a las APIs de un circuito: no hereda la cancelación
+// (un apagado deja terminar el circuito en curso), pero sí el plazo del ciclo más callGrace,
+// para que una llamada trabada no sobreviva al watchdog
+func (wp *WorkerPool) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	callCtx := context.WithoutCancel(ctx)
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return callCtx, func() {}
+	}
+	return context.WithDeadline(callCtx, deadline.Add(wp.callGrace))
+}
+
+// SetQueueBuffer fija el tamaño de las colas de circuitos y resultados. Con colas acotadas
+// los circuitos se entregan a medida que los workers avanzan y los resultados esperan
+// a que se consuman, en lugar de retener todo el ciclo en memoria.
+func (wp *WorkerPool) SetQueueBuffer(n int) {
+	wp.queueBuffer = n
+}
+
+// SetStageEnabled habilita o deshabilita una etapa (sincronización parcial).
+// Sin Notion no hay OLT/ONT: deshabilitarla solo tiene sentido si Zabbix también está deshabilitada.
+func (wp *WorkerPool) SetStageEnabled(stage Stage, enabled bool) {
+	if wp.disabled == nil {
+		wp.disabled = make(map[Stage]bool)
+	}
+	wp.disabled[stage] = !enabled
+}
+
+// resolveVLAN toma la VLAN de la primera fuente habilitada que la tenga y la valida.
+// Un fallo al consultar una fuente es una advertencia: se sigue con la siguiente.
+func (wp *WorkerPool) resolveVLAN(ctx context.Context, enriched *EnrichedData, network NetworkInfo) {
+	for _, source := range wp.vlanSources {
+		if wp.disabled[source] {
+			continue
+		}
+		var vlan string
+		switch source {
+		case StageNotion:
+			vlan = network.VLAN
+		case StageUbersmith:
+			lookup, ok := wp.ubersmith.(VLANLookup)
+			if !ok {
+				continue
+			}
+			var err error
+			if vlan, err = lookup.GetVLAN(ctx, enriched.CircuitID); err != nil {
+				log.Printf("[WARN] CID %s - Ubersmith (VLAN): %v", enriched.CircuitID, err)
+				enriched.Warnings = append(enriched.Warnings, &StageError{Stage: StageUbersmith, Err: err})
+				continue
+			}
+		}
+		if vlan != "" {
+			enriched.VLAN = vlan
+			wp.validateVLAN(enriched, source)
+			return
+		}
+	}
+}
+
+// validateVLAN descarta una VLAN fuera de rango antes de que llegue a la DB
+func (wp *WorkerPool) validateVLAN(enriched *EnrichedData, source Stage) {
+	vlan, ok := wp.vlanRange.ParseVLAN(enriched.VLAN)
+	if !ok {
+		log.Printf("[WARN] CID %s - VLAN %q de %s descartada: fuera del rango %d-%d", enriched.CircuitID, enriched.VLAN, source, wp.vlanRange.Min, wp.vlanRange.Max)
+		enriched.Warnings = append(enriched.Warnings, &StageError{Stage: source,
+			Err: fmt.Errorf("VLAN %q fuera del rango %d-%d", enriched.VLAN, wp.vlanRange.Min, wp.vlanRange.Max)})
+		enriched.VLAN = ""
+		return
+	}
+	enriched.VLANID = vlan
+}
+
+// validateRxPower descarta una lectura de rx power fuera de rango (vale para la key exacta
+// y para el valor en centésimas de ms_item_ont_rx_power). El valor de la DB queda intacto.
+func (wp *WorkerPool) validateRxPower(enriched *EnrichedData, source PowerSource) {
+	if enriched.RxPower == "" {
+		return
+	}
+	dbm, ok := ParseRxPower(enriched.RxPower)
+	if ok && wp.rxRange.Contains(dbm) {
+		return
+	}
+	log.Printf("[WARN] CID %s - Zabbix: rx power %q (%s) descartado: fuera del rango %.1f a %.1f dBm",
+		enriched.CircuitID, enriched.RxPower, source, wp.rxRange.Min, wp.rxRange.Max)
+	enriched.Warnings = append(enriched.Warnings, &StageError{Stage: StageZabbix,
+		Err: fmt.Errorf("rx power %q fuera del rango %.1f a %.1f dBm", enriched.RxPower, wp.rxRange.Min, wp.rxRange.Max)})
+	enriched.RxPower = ""
+	enriched.RxPowerDiscarded = true
+}
+
+// Run procesa los circuitos con workerCount workers. Las métricas de la ejecución
+// quedan completas cuando se cierra el canal de resultados.
+func (wp *WorkerPool) Run(ctx context.Context, circuits []Circuit) (<-chan EnrichedData, *RunStats) {
+	buffer := wp.queueBuffer
+	if buffer <= 0 {
+		buffer = wp.workerCount
+	}
+	jobs := make(chan job, buffer)
+	raw := make(chan indexedResult, buffer)
+	results := make(chan EnrichedData, buffer)
+	stats := newRunStats()
+
+	// runCtx se cancela además ante errores fatales para el ciclo (ej: credenciales de Ubersmith inválidas)
+	runCtx, abort := context.WithCancelCause(ctx)
+
+	if wp.tasks != nil {
+		go wp.submit(&poolRun{ctx: runCtx, stats: stats, abort: abort, raw: raw}, circuits)
+		go func() {
+			wp.collect(raw, results)
+			stats.finish()
+			close(results)
+		}()
+		return results, stats
+	}
+
+	// El productor se bloquea cuando la cola está llena: avanza al ritmo de los workers.
+	// Si el ciclo se cancela deja de encolar (los workers ya no toman circuitos nuevos).
+	go func() {
+		defer close(jobs)
+		for i, c := range circuits {
+			select {
+			case jobs <- job{index: i, circuit: c}:
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
 
 	var wg sync.WaitGroup
 	for i := 0; i < wp.workerCount; i++ {
 		wg.Add(1)
-		go wp.worker(jobs, results, &wg)
+		go wp.worker(runCtx, jobs, raw, stats, abort, &wg)
 	}
 
 	go func() {
 		wg.Wait()
+		abort(nil)
+		close(raw)
+	}()
+
+	go func() {
+		wp.collect(raw, results)
+		stats.finish()
 		close(results)
 	}()
 
-	return results
+	return results, stats
+}
+
+// collect reenvía los resultados de los workers, reordenándolos si SetOrdered está activo
+func (wp *WorkerPool) collect(raw <-chan indexedResult, results chan<- EnrichedData) {
+	if !wp.ordered {
+		for r := range raw {
+			results <- r.res
+		}
+		return
+	}
+
+	// Buffer por índice: se emite un resultado solo cuando ya se emitieron todos los anteriores
+	pending := make(map[int]EnrichedData)
+	next := 0
+	for r := range raw {
+		pending[r.index] = r.res
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			results <- res
+			delete(pending, next)
+			next++
+		}
+	}
+
+	// Tras un apagado pueden faltar índices (circuitos no procesados): se emite el resto en orden
+	remaining := make([]int, 0, len(pending))
+	for idx := range pending {
+		remaining = append(remaining, idx)
+	}
+	sort.Ints(remaining)
+	for _, idx := range remaining {
+		results <- pending[idx]
+	}
 }
 
-// worker: Procesa un circuito por vez, siguiendo el flujo de trabajo requerido
-func (wp *WorkerPool) worker(jobs <-chan Circuit, results chan<- EnrichedData, wg *sync.WaitGroup) {
+// worker: Procesa un circuito por vez, siguiendo el flujo de trabajo requerido.
+// Cuando ctx se cancela (apagado) deja de tomar circuitos nuevos, pero termina el que está en curso:
+// las llamadas a las APIs usan un contexto que no hereda la cancelación para no perder ese resultado
+// (sí el plazo del ciclo, ver callContext).
+func (wp *WorkerPool) worker(ctx context.Context, jobs <-chan job, results chan<- indexedResult, stats *RunStats,
+	abort context.CancelCauseFunc, wg *sync.WaitGroup) {
 	defer wg.Done()
-	for c := range jobs {
-		enriched := EnrichedData{
-			CircuitID: c.CID,
+	callCtx, cancel := wp.callContext(ctx)
+	defer cancel()
+
+	for {
+		if ctx.Err() != nil {
+			return
 		}
+		select {
+		case <-ctx.Done():
+			return
+		case j, ok := <-jobs:
+			if !ok {
+				return
+			}
+			wp.handleJob(callCtx, j, stats, abort, results)
+		}
+	}
+}
+
+// handleJob procesa un circuito y envía su resultado (puede tener errores parciales)
+func (wp *WorkerPool) handleJob(ctx context.Context, j job, stats *RunStats, abort context.CancelCauseFunc,
+	results chan<- indexedResult) {
+	c := j.circuit
+	res := wp.process(ctx, c, stats)
+	stats.leave(c.CID)
+	stats.recordResult(res)
+	if err := wp.fatalError(res); err != nil {
+		// No tiene sentido seguir consultando con credenciales inválidas
+		log.Printf("[CRITICAL] CID %s: %v. Se detiene el ciclo", c.CID, err)
+		stats.abort(err)
+		abort(err)
+	}
+	results <- indexedResult{index: j.index, res: res}
+}
+
+// fatalError devuelve el error del resultado que invalida el resto del ciclo, si lo hay
+func (wp *WorkerPool) fatalError(res EnrichedData) error {
+	for _, err := range append([]error{res.Error}, res.Warnings...) {
+		if errors.Is(err, ErrUbersmithAuth) {
+			return err
+		}
+		if errors.Is(err, ErrNotionAuth) && !wp.notionAuthNonFatal {
+			return err
+		}
+	}
+	return nil
+}
 
-		// 1. Notion: Obtenemos OLT y ONT ID usando CID en formato fx-CID-nombre
-		olt, ont, err := wp.notion.GetNetworkInfo(c.CID)
+// process enriquece un circuito: Notion → Ubersmith → Zabbix.
+// Cada llamada se mide aunque falle, para que los timeouts se vean en las latencias.
+func (wp *WorkerPool) process(ctx context.Context, c Circuit, stats *RunStats) EnrichedData {
+	enriched := EnrichedData{
+		CircuitID: c.CID,
+	}
+	// Mientras Zabbix no responda, status y rx power se desconocen: si la etapa no llega
+	// a correr o falla (Notion caído, Zabbix caído) se conservan los valores de la DB
+	if wp.keepUnresolved {
+		enriched.StatusUnresolved = true
+		enriched.RxPowerUnresolved = true
+	}
+
+	// 1. Notion: Obtenemos OLT y ONT ID usando CID en formato fx-CID-nombre
+	var network NetworkInfo
+	if !wp.disabled[StageNotion] {
+		stats.enter(c.CID, StageNotion)
+		start := time.Now()
+		var err error
+		stageCtx, cancel := wp.stageContext(ctx, StageNotion)
+		network, err = wp.notion.GetNetworkInfo(stageCtx, c.CID)
+		cancel()
+		stats.observe(StageNotion, time.Since(start))
+		if wp.verbose && network.PageID != "" {
+			// Permite ver cuándo una coincidencia parcial del CID eligió la página equivocada
+			log.Printf("[DEBUG] CID %s - Notion: página %s %q", c.CID, network.PageID, network.Description)
+		}
 		if err != nil {
 			log.Printf("[ERROR] CID %s - Notion: %v", c.CID, err)
-			enriched.Error = fmt.Errorf("notion error: %w", err)
-			results <- enriched
-			continue
+			enriched.Error = &StageError{Stage: StageNotion, Err: err}
+			return enriched
 		}
+		enriched.OLT = network.OLT
+		enriched.ONT = network.ONT
+	}
 
-		// 2. Ubersmith: Obtenemos PPPoEUsername y PPPoEPassword usando CID
-		p_user, p_pass, err := wp.ubersmith.GetServiceDetails(c.CID)
+	// 2. Ubersmith: Obtenemos PPPoEUsername y PPPoEPassword usando CID
+	if !wp.disabled[StageUbersmith] {
+		stats.enter(c.CID, StageUbersmith)
+		start := time.Now()
+		stageCtx, cancel := wp.stageContext(ctx, StageUbersmith)
+		p_user, p_pass, err := wp.ubersmith.GetServiceDetails(stageCtx, c.CID)
+		cancel()
+		stats.observe(StageUbersmith, time.Since(start))
 		if err != nil {
 			log.Printf("[WARN] CID %s - Ubersmith: %v (continuando...)", c.CID, err)
+			enriched.Warnings = append(enriched.Warnings, &StageError{Stage: StageUbersmith, Err: err})
 			// Continuamos aunque falle Ubersmith para obtener al menos datos de Zabbix
 		} else {
 			enriched.PPPoEUsername = p_user
 			enriched.PPPoEPassword = p_pass
 		}
+	}
+
+	wp.resolveVLAN(ctx, &enriched, network)
 
-		// 3. Zabbix: Consultamos rx power y status gpon usando OLT y ONT
-		// El formato ONT (1/2/3) se procesa dentro de GetOpticalInfo
-		status, rx, err := wp.zabbix.GetOpticalInfo(olt, ont)
+	if wp.disabled[StageZabbix] {
+		return enriched
+	}
+	if wp.disabled[StageNotion] {
+		// Zabbix necesita OLT y ONT, que solo vienen de Notion
+		enriched.Error = &StageError{Stage: StageNotion, Err: errors.New("etapa deshabilitada: sin OLT/ONT no se puede consultar Zabbix")}
+		return enriched
+	}
+
+	olt := network.OLT
+	onts := SplitONTList(network.ONT)
+
+	// 3. Validamos el ONT ID de Notion antes de consultar Zabbix.
+	// Un formato inválido es un error de datos de Notion, no de Zabbix.
+	for _, ont := range onts {
+		if _, err := ParseONTID(ont); err != nil {
+			log.Printf("[ERROR] CID %s - Notion (ONT ID inválido): %v", c.CID, err)
+			enriched.Error = &StageError{Stage: StageNotion, Err: &DataError{Err: err}}
+			return enriched
+		}
+	}
+
+	// 4. Zabbix: Consultamos rx power y status gpon usando OLT y ONT (cada una, si hay varias).
+	// El plazo de la etapa cubre todas las ONTs y la consulta de problemas de la OLT.
+	zabbixCtx, cancel := wp.stageContext(ctx, StageZabbix)
+	defer cancel()
+	infos := make([]OpticalInfo, len(onts))
+	queried := make([]bool, len(onts))
+	var lastErr error
+	failed := 0
+	for i, ont := range onts {
+		stats.enter(c.CID, StageZabbix)
+		start := time.Now()
+		info, err := wp.zabbix.GetOpticalDetails(zabbixCtx, olt, ont)
+		stats.observe(StageZabbix, time.Since(start))
 		if err != nil {
 			log.Printf("[ERROR] CID %s - Zabbix (OLT:%s, ONT:%s): %v", c.CID, olt, ont, err)
-			if enriched.Error == nil {
-				enriched.Error = fmt.Errorf("zabbix error: %w", err)
-			} else {
-				enriched.Error = fmt.Errorf("%v; zabbix error: %w", enriched.Error, err)
-			}
-		} else {
-			enriched.StatusGpon = status
-			enriched.RxPower = rx
+			lastErr = err
+			failed++
+			continue
+		}
+		infos[i], queried[i] = info, true
+	}
+	if failed == len(onts) {
+		enriched.Error = &StageError{Stage: StageZabbix, Err: lastErr}
+		return enriched
+	}
+	if failed > 0 {
+		enriched.Warnings = append(enriched.Warnings, &StageError{Stage: StageZabbix,
+			Err: fmt.Errorf("%d de %d ONTs no se pudieron consultar: %w", failed, len(onts), lastErr)})
+	}
+
+	selected := 0
+	if len(onts) > 1 {
+		selected = wp.selectONT(infos, queried)
+		enriched.ONTCandidates = onts
+		enriched.ONT = onts[selected]
+		if wp.verbose {
+			log.Printf("[DEBUG] CID %s - Zabbix: %d ONTs, se usa %s (política %s)", c.CID, len(onts), onts[selected], wp.ontPolicy)
+		}
+	}
+	info := infos[selected]
+
+	enriched.StatusGpon = info.Status
+	enriched.StatusGponRaw = info.StatusRaw
+	enriched.RxPower = info.RxPower
+	if wp.keepUnresolved {
+		enriched.StatusUnresolved = !info.StatusFound
+		enriched.RxPowerUnresolved = !info.PowerFound
+	}
+	// Una consulta fallida no dice nada del rx power: se conserva el valor de la DB
+	if info.PowerErr != nil {
+		enriched.RxPowerUnresolved = true
+	}
+	wp.validateRxPower(&enriched, info.PowerSource)
+	wp.checkProblems(zabbixCtx, &enriched, olt)
+	if info.StatusStale {
+		log.Printf("[WARN] CID %s - Zabbix: datos desactualizados: el status %q del host %s no se actualiza hace demasiado (descartado)", c.CID, info.StatusKey, olt)
+		enriched.Warnings = append(enriched.Warnings, &StageError{Stage: StageZabbix,
+			Err: fmt.Errorf("datos de Zabbix desactualizados: status %q en el host %s", info.StatusKey, olt)})
+	} else if !info.StatusFound {
+		log.Printf("[WARN] CID %s - Zabbix: ningún item coincide con la key %q en el host %s", c.CID, info.StatusKey, olt)
+		enriched.Warnings = append(enriched.Warnings, &StageError{Stage: StageZabbix,
+			Err: fmt.Errorf("item %q no encontrado en el host %s", info.StatusKey, olt)})
+	}
+	if info.PowerErr != nil {
+		log.Printf("[WARN] CID %s - Zabbix: %v (se conserva el rx power actual)", c.CID, info.PowerErr)
+		enriched.Warnings = append(enriched.Warnings, &StageError{Stage: StageZabbix, Err: info.PowerErr})
+	} else if info.PowerStale {
+		log.Printf("[WARN] CID %s - Zabbix: datos desactualizados: el rx power %q del host %s no se actualiza hace demasiado (descartado)", c.CID, info.PowerKey, olt)
+		enriched.Warnings = append(enriched.Warnings, &StageError{Stage: StageZabbix,
+			Err: fmt.Errorf("datos de Zabbix desactualizados: rx power %q en el host %s", info.PowerKey, olt)})
+	} else if !info.PowerFound {
+		log.Printf("[WARN] CID %s - Zabbix: sin rx power para la key %q (ni en ms_item_ont_rx_power) en el host %s", c.CID, info.PowerKey, olt)
+		err := fmt.Errorf("rx power %q no encontrado en el host %s", info.PowerKey, olt)
+		if enriched.HostProblem != "" {
+			// Una lectura vacía durante un corte conocido no es un dato faltante
+			log.Printf("[WARN] CID %s - Zabbix: %s", c.CID, enriched.HostProblem)
+			err = fmt.Errorf("%w; %s", err, enriched.HostProblem)
 		}
+		enriched.Warnings = append(enriched.Warnings, &StageError{Stage: StageZabbix, Err: err})
+	}
+
+	return enriched
+}
 
-		// Enviamos datos enriquecidos (pueden tener errores parciales)
-		results <- enriched
+// selectONT elige entre las ONTs de un circuito según la ONTPolicy. Solo cuentan las que
+// tienen rx power; si ninguna tiene señal (todas offline) se usa la primera que se pudo consultar.
+func (wp *WorkerPool) selectONT(infos []OpticalInfo, queried []bool) int {
+	selected, worst := -1, 0.0
+	for i, info := range infos {
+		if !queried[i] || !info.PowerFound {
+			continue
+		}
+		if wp.ontPolicy != ONTPolicyWorstRx {
+			return i
+		}
+		dbm, ok := ParseRxPower(info.RxPower)
+		if ok && (selected < 0 || dbm < worst) {
+			selected, worst = i, dbm
+		}
+	}
+	if selected >= 0 {
+		return selected
+	}
+	for i := range infos {
+		if queried[i] {
+			return i
+		}
+	}
+	return 0
+}
+
+// checkProblems anota el problema activo más grave de la OLT, para que una lectura vacía
+// se explique por un corte conocido. Un fallo de la consulta no afecta al circuito.
+func (wp *WorkerPool) checkProblems(ctx context.Context, enriched *EnrichedData, olt string) {
+	if wp.problemSeverity < 0 {
+		return
+	}
+	lookup, ok := wp.zabbix.(ProblemLookup)
+	if !ok {
+		return
+	}
+	problems, err := lookup.GetActiveProblems(ctx, olt)
+	if err != nil {
+		log.Printf("[WARN] CID %s - Zabbix: no se pudieron consultar los problemas de %s: %v", enriched.CircuitID, olt, err)
+		return
+	}
+
+	var worst *Problem
+	for i, p := range problems {
+		if p.Severity >= wp.problemSeverity && (worst == nil || p.Severity > worst.Severity) {
+			worst = &problems[i]
+		}
+	}
+	if worst == nil {
+		return
 	}
+	enriched.HostProblem = fmt.Sprintf("la OLT %s tiene un problema activo (severidad %d): %s", olt, worst.Severity, worst.Description)
 }
@@ -0,0 +1,460 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubNotion devuelve una OLT por CID después de delay(cid); cuenta las llamadas
+type stubNotion struct {
+	delay func(cid string) time.Duration
+	calls atomic.Int32
+}
+
+func (n *stubNotion) GetNetworkInfo(ctx context.Context, cid string) (NetworkInfo, error) {
+	n.calls.Add(1)
+	if n.delay != nil {
+		select {
+		case <-time.After(n.delay(cid)):
+		case <-ctx.Done():
+			return NetworkInfo{}, ctx.Err()
+		}
+	}
+	return NetworkInfo{OLT: "OLT-" + cid, ONT: "1/2/3"}, nil
+}
+
+type stubZabbix struct{}
+
+func (stubZabbix) GetOpticalDetails(ctx context.Context, olt, ont string) (OpticalInfo, error) {
+	return OpticalInfo{Status: "1", RxPower: "-20.00", StatusFound: true, PowerFound: true}, nil
+}
+
+type stubUbersmith struct{}
+
+func (stubUbersmith) GetServiceDetails(ctx context.Context, cid string) (string, string, error) {
+	return "user-" + cid, "pass", nil
+}
+
+func testCircuits(n int) []Circuit {
+	circuits := make([]Circuit, n)
+	for i := range circuits {
+		circuits[i] = Circuit{ID: i + 1, CID: fmt.Sprintf("%d", 100+i)}
+	}
+	return circuits
+}
+
+// collectCIDs consume el canal de resultados y devuelve los CIDs en el orden recibido
+func collectCIDs(results <-chan EnrichedData) []string {
+	var cids []string
+	for res := range results {
+		cids = append(cids, res.CircuitID)
+	}
+	return cids
+}
+
+func TestRunOrderedPreservesInputOrder(t *testing.T) {
+	circuits := testCircuits(20)
+	// Los primeros circuitos son los más lentos: sin reordenar llegarían al final
+	notion := &stubNotion{delay: func(cid string) time.Duration {
+		n, _ := strconv.Atoi(cid)
+		return time.Duration(119-n) * 2 * time.Millisecond
+	}}
+	pool := NewWorkerPool(8, notion, stubZabbix{}, stubUbersmith{})
+	pool.SetOrdered(true)
+
+	results, _ := pool.Run(context.Background(), circuits)
+	got := collectCIDs(results)
+
+	if len(got) != len(circuits) {
+		t.Fatalf("se obtuvieron %d resultados, se esperaban %d", len(got), len(circuits))
+	}
+	for i, c := range circuits {
+		if got[i] != c.CID {
+			t.Fatalf("resultado %d = %s, se esperaba %s (orden: %v)", i, got[i], c.CID, got)
+		}
+	}
+}
+
+func TestRunUnorderedDeliversInCompletionOrder(t *testing.T) {
+	circuits := testCircuits(4)
+	notion := &stubNotion{delay: func(cid string) time.Duration {
+		if cid == "100" {
+			return 100 * time.Millisecond
+		}
+		return 0
+	}}
+	pool := NewWorkerPool(4, notion, stubZabbix{}, stubUbersmith{})
+
+	results, _ := pool.Run(context.Background(), circuits)
+	got := collectCIDs(results)
+
+	// Sin SetOrdered el circuito lento no retiene a los demás
+	if len(got) != 4 || got[3] != "100" {
+		t.Errorf("orden = %v, se esperaba el circuito lento al final", got)
+	}
+}
+
+// powerZabbix devuelve siempre la misma lectura de rx power
+type powerZabbix struct {
+	rx     string
+	source PowerSource
+}
+
+func (z powerZabbix) GetOpticalDetails(ctx context.Context, olt, ont string) (OpticalInfo, error) {
+	return OpticalInfo{Status: "1", RxPower: z.rx, StatusFound: true, PowerFound: true, PowerSource: z.source}, nil
+}
+
+func TestRunDiscardsOutOfRangeRxPower(t *testing.T) {
+	tests := []struct {
+		name          string
+		rx            string
+		source        PowerSource
+		wantRx        string
+		wantDiscarded bool
+	}{
+		{"dentro del rango", "-20.4 dBm", PowerSourceExactKey, "-20.4 dBm", false},
+		{"en el límite inferior", "-40", PowerSourceExactKey, "-40", false},
+		{"bajo el piso", "-80.0 dBm", PowerSourceExactKey, "", true},
+		{"sobre el techo", "+300 dBm", PowerSourceExactKey, "", true},
+		{"JSON en décimas fuera de rango", "-45.2 dBm", PowerSourceJSON, "", true},
+		{"JSON en décimas dentro del rango", "-15.8 dBm", PowerSourceJSON, "-15.8 dBm", false},
+		{"no numérico", "n/a", PowerSourceExactKey, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewWorkerPool(1, &stubNotion{}, powerZabbix{rx: tt.rx, source: tt.source}, stubUbersmith{})
+			results, _ := pool.Run(context.Background(), testCircuits(1))
+			res := <-results
+
+			if res.RxPower != tt.wantRx || res.RxPowerDiscarded != tt.wantDiscarded {
+				t.Errorf("RxPower = %q (descartado: %v), se esperaba %q (%v)", res.RxPower, res.RxPowerDiscarded, tt.wantRx, tt.wantDiscarded)
+			}
+			// Una lectura descartada es una advertencia de Zabbix, no un error del circuito
+			if tt.wantDiscarded && (res.Error != nil || len(res.Warnings) != 1 || ErrorStage(res.Warnings[0]) != StageZabbix) {
+				t.Errorf("error = %v, advertencias = %v; se esperaba una advertencia de Zabbix", res.Error, res.Warnings)
+			}
+		})
+	}
+}
+
+func TestRunUsesConfiguredRxPowerRange(t *testing.T) {
+	pool := NewWorkerPool(1, &stubNotion{}, powerZabbix{rx: "-30 dBm", source: PowerSourceExactKey}, stubUbersmith{})
+	pool.SetRxPowerRange(RxPowerRange{Min: -28, Max: -8})
+
+	results, _ := pool.Run(context.Background(), testCircuits(1))
+	if res := <-results; !res.RxPowerDiscarded {
+		t.Errorf("RxPower = %q, se esperaba descartar -30 dBm con RX_POWER_MIN=-28", res.RxPower)
+	}
+}
+
+// vlanNotion devuelve la VLAN cargada en Notion
+type vlanNotion struct{ vlan string }
+
+func (n vlanNotion) GetNetworkInfo(ctx context.Context, cid string) (NetworkInfo, error) {
+	return NetworkInfo{OLT: "OLT-" + cid, ONT: "1/2/3", VLAN: n.vlan}, nil
+}
+
+// vlanUbersmith implementa VLANLookup con la VLAN cargada en Ubersmith
+type vlanUbersmith struct {
+	stubUbersmith
+	vlan string
+}
+
+func (u vlanUbersmith) GetVLAN(ctx context.Context, cid string) (string, error) {
+	return u.vlan, nil
+}
+
+func TestRunVLANSourcePrecedence(t *testing.T) {
+	ubersmithFirst := []Stage{StageUbersmith, StageNotion}
+	tests := []struct {
+		name      string
+		sources   []Stage
+		notion    string
+		ubersmith string
+		want      int
+	}{
+		{"ambas: gana Ubersmith", ubersmithFirst, "200", "100", 100},
+		{"solo Notion", ubersmithFirst, "200", "", 200},
+		{"solo Ubersmith", ubersmithFirst, "", "100", 100},
+		{"ninguna", ubersmithFirst, "", "", 0},
+		{"ambas con Notion primero", []Stage{StageNotion, StageUbersmith}, "200", "100", 200},
+		{"Notion no configurada como fuente", []Stage{StageUbersmith}, "200", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewWorkerPool(1, vlanNotion{vlan: tt.notion}, stubZabbix{}, vlanUbersmith{vlan: tt.ubersmith})
+			pool.SetVLANSources(tt.sources)
+
+			results, _ := pool.Run(context.Background(), testCircuits(1))
+			if res := <-results; res.VLANID != tt.want {
+				t.Errorf("VLAN = %d (%q), se esperaba %d", res.VLANID, res.VLAN, tt.want)
+			}
+		})
+	}
+}
+
+// ontsNotion devuelve la lista de ONTs tal como está en la columna </> de Notion
+type ontsNotion struct{ onts string }
+
+func (n ontsNotion) GetNetworkInfo(ctx context.Context, cid string) (NetworkInfo, error) {
+	return NetworkInfo{OLT: "OLT-" + cid, ONT: n.onts}, nil
+}
+
+// ontsZabbix devuelve el rx power de cada ONT ("" = offline, sin lectura); cuenta las consultas
+type ontsZabbix struct {
+	rx      map[string]string
+	queries *atomic.Int32
+}
+
+func (z ontsZabbix) GetOpticalDetails(ctx context.Context, olt, ont string) (OpticalInfo, error) {
+	z.queries.Add(1)
+	rx := z.rx[ont]
+	return OpticalInfo{Status: "1", RxPower: rx, StatusFound: true, PowerFound: rx != "", PowerSource: PowerSourceExactKey}, nil
+}
+
+func TestRunSelectsONT(t *testing.T) {
+	tests := []struct {
+		name           string
+		onts           string
+		rx             map[string]string
+		policy         ONTPolicy
+		wantONT        string
+		wantRx         string
+		wantCandidates int
+	}{
+		{"una sola ONT", "1/2/3", map[string]string{"1/2/3": "-20.1 dBm"}, ONTPolicyActive, "1/2/3", "-20.1 dBm", 0},
+		{"dos ONTs: la primera activa", "1/2/3, 1/2/4", map[string]string{"1/2/3": "-18 dBm", "1/2/4": "-25 dBm"}, ONTPolicyActive, "1/2/3", "-18 dBm", 2},
+		{"dos ONTs: la primera offline", "1/2/3;1/2/4", map[string]string{"1/2/4": "-25 dBm"}, ONTPolicyActive, "1/2/4", "-25 dBm", 2},
+		{"dos ONTs: la de peor rx", "1/2/3,1/2/4", map[string]string{"1/2/3": "-18 dBm", "1/2/4": "-25 dBm"}, ONTPolicyWorstRx, "1/2/4", "-25 dBm", 2},
+		{"todas offline", "1/2/3,1/2/4", nil, ONTPolicyWorstRx, "1/2/3", "", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries atomic.Int32
+			pool := NewWorkerPool(1, ontsNotion{onts: tt.onts}, ontsZabbix{rx: tt.rx, queries: &queries}, stubUbersmith{})
+			pool.SetONTPolicy(tt.policy)
+
+			results, _ := pool.Run(context.Background(), testCircuits(1))
+			res := <-results
+			if res.Error != nil {
+				t.Fatalf("error inesperado: %v", res.Error)
+			}
+			if res.ONT != tt.wantONT || res.RxPower != tt.wantRx {
+				t.Errorf("ONT = %s con rx %q, se esperaba %s con %q", res.ONT, res.RxPower, tt.wantONT, tt.wantRx)
+			}
+			if len(res.ONTCandidates) != tt.wantCandidates {
+				t.Errorf("candidatas = %v, se esperaban %d", res.ONTCandidates, tt.wantCandidates)
+			}
+			// Se consulta cada ONT de la lista
+			if want := int32(len(SplitONTList(tt.onts))); queries.Load() != want {
+				t.Errorf("consultas a Zabbix = %d, se esperaban %d", queries.Load(), want)
+			}
+			if tt.wantRx == "" && (len(res.Warnings) == 0 || ErrorStage(res.Warnings[0]) != StageZabbix) {
+				t.Errorf("advertencias = %v, se esperaba una de Zabbix por el rx power faltante", res.Warnings)
+			}
+		})
+	}
+}
+
+// problemZabbix no tiene rx power para ninguna ONT y reporta los problemas dados para la OLT
+type problemZabbix struct {
+	problems []Problem
+	lookups  *atomic.Int32
+}
+
+func (z problemZabbix) GetOpticalDetails(ctx context.Context, olt, ont string) (OpticalInfo, error) {
+	return OpticalInfo{Status: "1", StatusFound: true}, nil
+}
+
+func (z problemZabbix) GetActiveProblems(ctx context.Context, host string) ([]Problem, error) {
+	z.lookups.Add(1)
+	return z.problems, nil
+}
+
+func TestRunAnnotatesHostProblem(t *testing.T) {
+	outage := []Problem{{Severity: 2, Description: "temperatura alta"}, {Severity: 5, Description: "puerto 0/1 caído"}}
+	tests := []struct {
+		name        string
+		problems    []Problem
+		minSeverity int
+		want        string
+		wantLookups int32
+	}{
+		{"deshabilitado", outage, -1, "", 0},
+		{"el más grave", outage, 4, "la OLT OLT-100 tiene un problema activo (severidad 5): puerto 0/1 caído", 1},
+		{"ninguno alcanza la severidad", outage[:1], 4, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookups atomic.Int32
+			pool := NewWorkerPool(1, &stubNotion{}, problemZabbix{problems: tt.problems, lookups: &lookups}, stubUbersmith{})
+			pool.SetProblemCheck(tt.minSeverity)
+
+			results, _ := pool.Run(context.Background(), testCircuits(1))
+			res := <-results
+			if res.HostProblem != tt.want {
+				t.Errorf("HostProblem = %q, se esperaba %q", res.HostProblem, tt.want)
+			}
+			// Deshabilitado no hace la request extra
+			if lookups.Load() != tt.wantLookups {
+				t.Errorf("consultas de problemas = %d, se esperaban %d", lookups.Load(), tt.wantLookups)
+			}
+			// El rx power vacío se explica con el problema
+			if tt.want != "" && (len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0].Error(), tt.want)) {
+				t.Errorf("advertencias = %v, se esperaba el problema de la OLT", res.Warnings)
+			}
+		})
+	}
+}
+
+// partialZabbix devuelve solo los items indicados
+type partialZabbix struct{ status, power bool }
+
+func (z partialZabbix) GetOpticalDetails(ctx context.Context, olt, ont string) (OpticalInfo, error) {
+	info := OpticalInfo{StatusFound: z.status, PowerFound: z.power, PowerSource: PowerSourceExactKey}
+	if z.status {
+		info.Status = "1"
+	}
+	if z.power {
+		info.RxPower = "-20.1 dBm"
+	}
+	return info, nil
+}
+
+func TestRunKeepsFieldsZabbixDidNotReturn(t *testing.T) {
+	tests := []struct {
+		name        string
+		zabbix      partialZabbix
+		keep        bool
+		keepStatus  bool
+		keepRxPower bool
+	}{
+		{"solo status", partialZabbix{status: true}, true, false, true},
+		{"solo rx power", partialZabbix{power: true}, true, true, false},
+		{"ambos", partialZabbix{status: true, power: true}, true, false, false},
+		// Sin ZABBIX_ONLY_RESOLVED se escribe lo que haya, aunque sea vacío
+		{"solo status sin el modo", partialZabbix{status: true}, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewWorkerPool(1, &stubNotion{}, tt.zabbix, stubUbersmith{})
+			pool.SetKeepUnresolved(tt.keep)
+
+			results, _ := pool.Run(context.Background(), testCircuits(1))
+			res := <-results
+			if got := res.KeepsCurrent("StatusGpon"); got != tt.keepStatus {
+				t.Errorf("conservar StatusGpon = %v, se esperaba %v", got, tt.keepStatus)
+			}
+			if got := res.KeepsCurrent("RxPower"); got != tt.keepRxPower {
+				t.Errorf("conservar RxPower = %v, se esperaba %v", got, tt.keepRxPower)
+			}
+			// Las demás columnas siempre se escriben
+			if res.KeepsCurrent("PPPoEUsername") {
+				t.Error("PPPoEUsername no depende de Zabbix")
+			}
+		})
+	}
+}
+
+// failingZabbix falla en todas las consultas (ej: Zabbix caído)
+type failingZabbix struct{}
+
+func (failingZabbix) GetOpticalDetails(ctx context.Context, olt, ont string) (OpticalInfo, error) {
+	return OpticalInfo{}, errors.New("zabbix: connection refused")
+}
+
+// failingNotion falla en todas las búsquedas: Zabbix no llega a consultarse
+type failingNotion struct{}
+
+func (failingNotion) GetNetworkInfo(ctx context.Context, cid string) (NetworkInfo, error) {
+	return NetworkInfo{}, errors.New("notion api error: 503")
+}
+
+func TestRunKeepsFieldsWhenZabbixStageFails(t *testing.T) {
+	tests := []struct {
+		name   string
+		notion NotionClient
+		zabbix ZabbixClient
+		keep   bool
+	}{
+		{"Zabbix falla", &stubNotion{}, failingZabbix{}, true},
+		{"Notion falla: Zabbix no corre", failingNotion{}, stubZabbix{}, true},
+		{"Zabbix falla sin el modo", &stubNotion{}, failingZabbix{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewWorkerPool(1, tt.notion, tt.zabbix, stubUbersmith{})
+			pool.SetKeepUnresolved(tt.keep)
+
+			results, _ := pool.Run(context.Background(), testCircuits(1))
+			res := <-results
+			if res.Error == nil {
+				t.Fatal("se esperaba un error del circuito")
+			}
+			if got := res.KeepsCurrent("StatusGpon"); got != tt.keep {
+				t.Errorf("conservar StatusGpon = %v, se esperaba %v", got, tt.keep)
+			}
+			if got := res.KeepsCurrent("RxPower"); got != tt.keep {
+				t.Errorf("conservar RxPower = %v, se esperaba %v", got, tt.keep)
+			}
+		})
+	}
+}
+
+// pageNotion devuelve una página de Notion con dos ONTs
+type pageNotion struct{}
+
+func (pageNotion) GetNetworkInfo(ctx context.Context, cid string) (NetworkInfo, error) {
+	return NetworkInfo{OLT: "OLT-" + cid, ONT: "1/2/3, 1/2/4", PageID: "page-" + cid, Description: "fx-" + cid + "-Cliente"}, nil
+}
+
+func TestRunLogsDebugOnlyWhenVerbose(t *testing.T) {
+	for _, verbose := range []bool{false, true} {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		pool := NewWorkerPool(1, pageNotion{}, stubZabbix{}, stubUbersmith{})
+		pool.SetVerbose(verbose)
+
+		results, _ := pool.Run(context.Background(), testCircuits(3))
+		collectCIDs(results)
+		log.SetOutput(os.Stderr)
+
+		if got := strings.Count(buf.String(), "[DEBUG]"); (got > 0) != verbose {
+			t.Errorf("verbose=%v: %d líneas [DEBUG]:\n%s", verbose, got, buf.String())
+		}
+	}
+}
+
+func TestRunWithInvalidWorkerCountUsesOneWorker(t *testing.T) {
+	for _, count := range []int{0, -3} {
+		t.Run(fmt.Sprintf("WORKER_COUNT=%d", count), func(t *testing.T) {
+			var active, maxActive atomic.Int32
+			notion := &stubNotion{delay: func(string) time.Duration {
+				if n := active.Add(1); n > maxActive.Load() {
+					maxActive.Store(n)
+				}
+				defer active.Add(-1)
+				time.Sleep(time.Millisecond)
+				return 0
+			}}
+			pool := NewWorkerPool(count, notion, stubZabbix{}, stubUbersmith{})
+
+			results, _ := pool.Run(context.Background(), testCircuits(10))
+			if got := collectCIDs(results); len(got) != 10 {
+				t.Errorf("se procesaron %d circuitos, se esperaban 10", len(got))
+			}
+			if maxActive.Load() != 1 {
+				t.Errorf("hubo %d workers simultáneos, se esperaba uno solo", maxActive.Load())
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// aqui llevamos la cuenta de fallos consecutivos por circuito (dead-letter)
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry es el estado de un circuito que viene fallando
+type Entry struct {
+	CID         string    `json:"cid"`
+	Failures    int       `json:"failures"` // Fallos consecutivos
+	LastError   string    `json:"last_error"`
+	LastFailure time.Time `json:"last_failure"`
+	Dead        bool      `json:"dead"` // Superó el umbral: se omite en los ciclos normales
+}
+
+// Store guarda los fallos consecutivos de cada CID en un archivo JSON.
+// Un circuito que falla threshold veces seguidas pasa a dead-letter; un ciclo exitoso lo saca.
+type Store struct {
+	path      string
+	threshold int
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+// Open carga el archivo de estado (si no existe se empieza vacío)
+func Open(path string, threshold int) (*Store, error) {
+	s := &Store{path: path, threshold: threshold, entries: make(map[string]*Entry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer el archivo de dead-letter %s: %w", path, err)
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("archivo de dead-letter %s inválido: %w", path, err)
+	}
+	for _, e := range entries {
+		s.entries[e.CID] = e
+	}
+	return s, nil
+}
+
+// IsDead indica si el circuito está en dead-letter
+func (s *Store) IsDead(cid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[cid]
+	return ok && e.Dead
+}
+
+// Record registra el resultado de un circuito: err nil lo da por sano (se borra su historial)
+func (s *Store) Record(cid string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		if e, ok := s.entries[cid]; ok && e.Dead {
+			log.Printf("♻️  CID %s sale de dead-letter: se procesó sin errores", cid)
+		}
+		delete(s.entries, cid)
+		return
+	}
+
+	e, ok := s.entries[cid]
+	if !ok {
+		e = &Entry{CID: cid}
+		s.entries[cid] = e
+	}
+	e.Failures++
+	e.LastError = err.Error()
+	e.LastFailure = time.Now()
+	if !e.Dead && e.Failures >= s.threshold {
+		e.Dead = true
+		log.Printf("[WARN] 🪦 CID %s pasa a dead-letter tras %d fallos consecutivos: %s", cid, e.Failures, e.LastError)
+	}
+}
+
+// Dead devuelve los circuitos en dead-letter ordenados por CID
+func (s *Store) Dead() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var dead []Entry
+	for _, e := range s.entries {
+		if e.Dead {
+			dead = append(dead, *e)
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool { return dead[i].CID < dead[j].CID })
+	return dead
+}
+
+// Save escribe el estado en el archivo (a un temporal y luego rename, para no dejarlo a medias)
+func (s *Store) Save() error {
+	s.mu.Lock()
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CID < entries[j].CID })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".deadletter-*")
+	if err != nil {
+		return fmt.Errorf("no se pudo guardar el dead-letter: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("no se pudo guardar el dead-letter: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("no se pudo guardar el dead-letter: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("no se pudo guardar el dead-letter: %w", err)
+	}
+	return nil
+}
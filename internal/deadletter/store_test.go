@@ -0,0 +1,104 @@
+package deadletter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordMarksDeadAtThreshold(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "deadletter.json"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("circuit not found in notion")
+
+	for i := 1; i <= 3; i++ {
+		if s.IsDead("100") {
+			t.Fatalf("tras %d fallos el CID ya está en dead-letter (umbral 3)", i-1)
+		}
+		s.Record("100", failure)
+	}
+	if !s.IsDead("100") {
+		t.Fatal("tras 3 fallos consecutivos el CID debería estar en dead-letter")
+	}
+	dead := s.Dead()
+	if len(dead) != 1 || dead[0].CID != "100" || dead[0].Failures != 3 || dead[0].LastError != failure.Error() {
+		t.Errorf("Dead = %+v", dead)
+	}
+}
+
+func TestRecordNilClearsEntry(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "deadletter.json"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("ONT ID inválido")
+
+	// Un éxito entre fallos reinicia la cuenta: los fallos deben ser consecutivos
+	s.Record("100", failure)
+	s.Record("100", nil)
+	s.Record("100", failure)
+	if s.IsDead("100") {
+		t.Fatal("un éxito debería reiniciar la cuenta de fallos")
+	}
+
+	// Un éxito saca al circuito del dead-letter
+	s.Record("100", failure)
+	if !s.IsDead("100") {
+		t.Fatal("tras 2 fallos consecutivos el CID debería estar en dead-letter")
+	}
+	s.Record("100", nil)
+	if s.IsDead("100") || len(s.Dead()) != 0 {
+		t.Errorf("un éxito debería sacar al CID del dead-letter: %+v", s.Dead())
+	}
+}
+
+func TestSavePersistsState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletter.json")
+	s, err := Open(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("circuit not found in notion")
+	s.Record("100", failure)
+	s.Record("100", failure)
+	s.Record("200", failure)
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reopened, err := Open(path, 2)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !reopened.IsDead("100") || reopened.IsDead("200") {
+		t.Errorf("estado recargado: 100 muerto = %v, 200 muerto = %v; se esperaba true, false",
+			reopened.IsDead("100"), reopened.IsDead("200"))
+	}
+	// La cuenta de 200 sigue desde el archivo: un fallo más lo pasa a dead-letter
+	reopened.Record("200", failure)
+	if !reopened.IsDead("200") {
+		t.Error("la cuenta de fallos debería conservarse entre ciclos")
+	}
+}
+
+func TestOpenMissingAndInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "no-existe.json"), 5)
+	if err != nil {
+		t.Fatalf("un archivo inexistente debería empezar vacío: %v", err)
+	}
+	if len(s.Dead()) != 0 {
+		t.Errorf("Dead = %+v, se esperaba vacío", s.Dead())
+	}
+
+	path := filepath.Join(dir, "roto.json")
+	if err := os.WriteFile(path, []byte("{no es json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, 5); err == nil {
+		t.Error("se esperaba error con un archivo inválido")
+	}
+}
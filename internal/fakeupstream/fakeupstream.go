@@ -0,0 +1,309 @@
+// aqui imitamos a Notion, Zabbix y Ubersmith con respuestas grabadas (ver fixtures/),
+// para probar el pipeline completo sin tocar las APIs reales (cmd/fakeupstream y los tests de integración)
+package fakeupstream
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// Circuit es un circuito de fixtures/circuits.json con los datos de las tres fuentes
+type Circuit struct {
+	CID         string `json:"cid"`
+	Description string `json:"description"`
+	OLT         string `json:"olt"`
+	ONT         string `json:"ont"`
+	PPPoEUser   string `json:"pppoe_user"`
+	PPPoEPass   string `json:"pppoe_pass"`
+}
+
+// ZabbixToken es el token de sesión que devuelve user.login
+const ZabbixToken = "fake-zabbix-token"
+
+// Upstreams son los tres servicios falsos sobre los mismos circuitos de prueba
+type Upstreams struct {
+	Circuits []Circuit
+
+	// Credenciales aceptadas (vacío = se acepta cualquier valor no vacío)
+	NotionKey     string
+	UbersmithPass string
+
+	items           []map[string]string
+	fieldList       map[string]interface{}
+	serviceTemplate []byte
+}
+
+// New carga los fixtures embebidos
+func New() (*Upstreams, error) {
+	u := &Upstreams{}
+	if err := load("fixtures/circuits.json", &u.Circuits); err != nil {
+		return nil, err
+	}
+	if err := load("fixtures/zabbix_items.json", &u.items); err != nil {
+		return nil, err
+	}
+	if err := load("fixtures/ubersmith_metadata_field_list.json", &u.fieldList); err != nil {
+		return nil, err
+	}
+	var err error
+	if u.serviceTemplate, err = fixtures.ReadFile("fixtures/ubersmith_service_get.json"); err != nil {
+		return nil, fmt.Errorf("fixture ubersmith_service_get.json: %w", err)
+	}
+	return u, nil
+}
+
+// load decodifica un fixture embebido
+func load(name string, v interface{}) error {
+	data, err := fixtures.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("fixture %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("fixture %s: %w", name, err)
+	}
+	return nil
+}
+
+// Mux sirve los tres servicios en un solo servidor: /notion/v1, /zabbix/api_jsonrpc.php y /ubersmith/api/2.0/
+func (u *Upstreams) Mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/notion/", http.StripPrefix("/notion", u.Notion()))
+	mux.Handle("/zabbix/", http.StripPrefix("/zabbix", u.Zabbix()))
+	mux.Handle("/ubersmith/", http.StripPrefix("/ubersmith", u.Ubersmith()))
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// notionFilter es el filtro de una consulta: una condición sobre una columna o un "or" de varias
+type notionFilter struct {
+	Property string            `json:"property"`
+	Title    map[string]string `json:"title"`
+	RichText map[string]string `json:"rich_text"`
+	Or       []notionFilter    `json:"or"`
+}
+
+// matches indica si la descripción cumple el filtro. Como la API real, un filtro rich_text
+// sobre una columna title no devuelve resultados.
+func (f notionFilter) matches(description string) bool {
+	for _, sub := range f.Or {
+		if sub.matches(description) {
+			return true
+		}
+	}
+	needle, ok := f.Title["contains"]
+	return ok && strings.Contains(strings.ToLower(description), strings.ToLower(needle))
+}
+
+// valid indica si todas las condiciones del filtro usan la columna Description
+func (f notionFilter) valid() bool {
+	if len(f.Or) > 0 {
+		for _, sub := range f.Or {
+			if !sub.valid() {
+				return false
+			}
+		}
+		return true
+	}
+	return f.Property == "Description"
+}
+
+// Notion imita POST /v1/databases/{id}/query con filtros "contains" (o un "or" de ellos) sobre Description
+func (u *Upstreams) Notion() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/databases/{id}/query", func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if key == "" || (u.NotionKey != "" && key != u.NotionKey) {
+			writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+				"object": "error", "status": 401, "code": "unauthorized", "message": "API token is invalid.",
+			})
+			return
+		}
+		if r.Header.Get("Notion-Version") == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"object": "error", "code": "missing_version", "message": "Notion-Version header failed validation",
+			})
+			return
+		}
+
+		var body struct {
+			Filter *notionFilter `json:"filter"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || (body.Filter != nil && !body.Filter.valid()) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"object": "error", "code": "validation_error", "message": "Could not find property with name or id",
+			})
+			return
+		}
+
+		results := []interface{}{}
+		for _, c := range u.Circuits {
+			if body.Filter == nil || body.Filter.matches(c.Description) {
+				results = append(results, notionPage(c))
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"object": "list", "results": results, "has_more": false})
+	})
+	return mux
+}
+
+// notionPage arma una página con la forma real de la base: Description (title), OLT (select)
+// y la columna </> que la API devuelve con nombre vacío (rich_text)
+func notionPage(c Circuit) map[string]interface{} {
+	return map[string]interface{}{
+		"object": "page",
+		"id":     "page-" + c.CID,
+		"properties": map[string]interface{}{
+			"Description": map[string]interface{}{"type": "title", "title": []map[string]string{{"plain_text": c.Description}}},
+			"OLT":         map[string]interface{}{"type": "select", "select": map[string]string{"name": c.OLT}},
+			"":            map[string]interface{}{"type": "rich_text", "rich_text": []map[string]string{{"plain_text": c.ONT}}},
+		},
+	}
+}
+
+// Zabbix imita la API JSON-RPC de Zabbix (POST /api_jsonrpc.php): user.login, host.get, item.get y history.get
+func (u *Upstreams) Zabbix() http.Handler {
+	hosts := make(map[string]bool)
+	for _, c := range u.Circuits {
+		hosts[c.OLT] = true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api_jsonrpc.php", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+			ID     int                    `json:"id"`
+			Auth   string                 `json:"auth"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusOK, RPCError(0, -32700, "Parse error"))
+			return
+		}
+
+		authorized := req.Auth == ZabbixToken || r.Header.Get("Authorization") == "Bearer "+ZabbixToken
+		if req.Method != "user.login" && !authorized {
+			writeJSON(w, http.StatusOK, RPCError(req.ID, -32602, "Not authorised."))
+			return
+		}
+
+		var result interface{}
+		switch req.Method {
+		case "user.login":
+			result = ZabbixToken
+		case "host.get":
+			found := []map[string]string{}
+			if filter, ok := req.Params["filter"].(map[string]interface{}); ok {
+				names, _ := filter["host"].([]interface{})
+				for _, name := range names {
+					if s, _ := name.(string); hosts[s] {
+						found = append(found, map[string]string{"hostid": "10" + s})
+					}
+				}
+			}
+			result = found
+		case "item.get":
+			host, _ := req.Params["host"].(string)
+			found := []map[string]string{}
+			if hosts[host] {
+				key := ""
+				if filter, ok := req.Params["filter"].(map[string]interface{}); ok {
+					key, _ = filter["key_"].(string)
+				}
+				for _, item := range u.items {
+					if key == "" || item["key_"] == key {
+						found = append(found, item)
+					}
+				}
+			}
+			result = found
+		case "history.get":
+			result = []interface{}{}
+		default:
+			writeJSON(w, http.StatusOK, RPCError(req.ID, -32601, "Method not found."))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"jsonrpc": "2.0", "result": result, "id": req.ID})
+	})
+	return mux
+}
+
+// RPCError es una respuesta de error JSON-RPC de Zabbix
+func RPCError(id, code int, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"error":   map[string]interface{}{"code": code, "message": message, "data": ""},
+		"id":      id,
+	}
+}
+
+// Ubersmith imita la API 2.0 de Ubersmith (GET /api/2.0/): client.service_get, uber.metadata_field_list
+// y uber.metadata_bulk_get (este último devuelve el valor de la variable para todos los servicios)
+func (u *Upstreams) Ubersmith() http.Handler {
+	byCID := make(map[string]Circuit)
+	for _, c := range u.Circuits {
+		byCID[c.CID] = c
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/2.0/", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user == "" || (u.UbersmithPass != "" && pass != u.UbersmithPass) {
+			writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+				"status": false, "error_code": 1, "error_message": "Authentication required",
+			})
+			return
+		}
+
+		q := r.URL.Query()
+		switch q.Get("method") {
+		case "client.service_get":
+			c, ok := byCID[q.Get("service_id")]
+			if !ok {
+				writeJSON(w, http.StatusOK, map[string]interface{}{
+					"status": false, "error_code": 2, "error_message": "Invalid service id specified",
+				})
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(strings.ReplaceAll(string(u.serviceTemplate), "{{cid}}", c.CID)))
+
+		case "uber.metadata_field_list":
+			writeJSON(w, http.StatusOK, u.fieldList)
+
+		case "uber.metadata_bulk_get":
+			variable := strings.ToLower(q.Get("variable"))
+			data := map[string]string{}
+			for _, c := range u.Circuits {
+				value := ""
+				switch {
+				case strings.Contains(variable, "pass"):
+					value = c.PPPoEPass
+				case strings.Contains(variable, "user"):
+					value = c.PPPoEUser
+				}
+				if value != "" {
+					data[c.CID] = value
+				}
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"status": true, "data": data})
+
+		default:
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"status": false, "error_code": 3, "error_message": "Unknown method " + q.Get("method"),
+			})
+		}
+	})
+	return mux
+}
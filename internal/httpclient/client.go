@@ -0,0 +1,90 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// Valores por defecto de los clientes HTTP de los adaptadores
+const (
+	DefaultTimeout             = 10 * time.Second
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 10
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// Options son los ajustes comunes de un cliente HTTP de adaptador
+type Options struct {
+	Name string // Nombre del adaptador, solo para logs (ej: "Zabbix")
+
+	Timeout time.Duration // Timeout total por request (0 = DefaultTimeout)
+
+	// TLS
+	InsecureSkipVerify bool
+	CACertPath         string
+
+	// Proxy explícito (OUTBOUND_PROXY). Vacío = HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	ProxyURL string
+
+	// Conexiones inactivas reutilizables (0 = default). Cada adaptador habla con un solo host,
+	// así que MaxIdleConnsPerHost debería acompañar a WORKER_COUNT para no repetir handshakes TLS.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration // Tiempo que una conexión inactiva queda abierta (0 = default)
+
+	// Debug loguea cada request (método, URL sin credenciales, status y latencia)
+	Debug bool
+
+	// UserAgent de todos los requests (vacío = el default de Go)
+	UserAgent string
+
+	// Límite de requests simultáneos compartido entre adaptadores (nil = sin límite)
+	InFlight *InFlightLimit
+}
+
+// New crea un *http.Client configurado. Es el único lugar donde se construyen
+// los clientes de Notion, Zabbix y Ubersmith, para que todos se comporten igual.
+func New(opts Options) (*http.Client, error) {
+	tlsConfig, err := TLSConfig(opts.Name, opts.InsecureSkipVerify, opts.CACertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := NewTransport(tlsConfig, opts.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	transport.MaxIdleConns = DefaultMaxIdleConns
+	if opts.MaxIdleConns > 0 {
+		transport.MaxIdleConns = opts.MaxIdleConns
+	}
+	transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	if opts.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = DefaultIdleConnTimeout
+	if opts.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = opts.IdleConnTimeout
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	// DisableCompression queda en false: el transport envía Accept-Encoding: gzip y descomprime
+	// las respuestas. Los adaptadores no deben definir ese header a mano o pierden la descompresión.
+	var rt http.RoundTripper = transport
+	if opts.InFlight != nil {
+		rt = WithInFlightLimit(opts.InFlight, rt)
+	}
+	if opts.UserAgent != "" {
+		rt = WithUserAgent(opts.UserAgent, rt)
+	}
+	if opts.Debug {
+		rt = WithLogging(opts.Name, rt)
+	}
+
+	return &http.Client{Timeout: timeout, Transport: rt}, nil
+}
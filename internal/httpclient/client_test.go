@@ -0,0 +1,119 @@
+package httpclient
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer cuenta las conexiones TCP nuevas que recibe
+func countingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv, &conns
+}
+
+// get hace un request y consume el cuerpo para que la conexión vuelva al pool
+func get(t *testing.T, client *http.Client, url string) {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	srv, conns := countingServer(t)
+	client, err := New(Options{Name: "Zabbix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		get(t, client, srv.URL)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("se abrieron %d conexiones para 20 requests secuenciales, se esperaba 1", n)
+	}
+}
+
+func TestClientKeepsIdleConnectionsPerHost(t *testing.T) {
+	const workers = 8
+	tests := []struct {
+		name      string
+		perHost   int
+		wantReuse bool
+	}{
+		// Con el pool del tamaño de WORKER_COUNT cada ronda reutiliza las conexiones de la anterior
+		{"pool del tamaño de los workers", workers, true},
+		// Con 2 conexiones inactivas por host el resto se cierra y se vuelve a abrir en cada ronda
+		{"pool chico", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, conns := countingServer(t)
+			client, err := New(Options{Name: "Ubersmith", MaxIdleConnsPerHost: tt.perHost})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for round := 0; round < 3; round++ {
+				var wg sync.WaitGroup
+				for i := 0; i < workers; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						get(t, client, srv.URL)
+					}()
+				}
+				wg.Wait()
+			}
+			n := conns.Load()
+			if tt.wantReuse && n > workers {
+				t.Errorf("se abrieron %d conexiones en 3 rondas, se esperaban a lo sumo %d", n, workers)
+			}
+			if !tt.wantReuse && n <= workers {
+				t.Errorf("se abrieron %d conexiones: con MaxIdleConnsPerHost=%d se esperaba tener que reabrir", n, tt.perHost)
+			}
+		})
+	}
+}
+
+func TestNewAppliesIdleSettings(t *testing.T) {
+	client, err := New(Options{Name: "Notion", MaxIdleConns: 7, MaxIdleConnsPerHost: 5, IdleConnTimeout: 30 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport = %T, se esperaba *http.Transport sin envoltorios", client.Transport)
+	}
+	if transport.MaxIdleConns != 7 || transport.MaxIdleConnsPerHost != 5 || transport.IdleConnTimeout != 30*time.Second {
+		t.Errorf("MaxIdleConns=%d MaxIdleConnsPerHost=%d IdleConnTimeout=%s", transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+
+	// Sin valores se usan los defaults
+	client, _ = New(Options{Name: "Notion"})
+	transport = client.Transport.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost || transport.IdleConnTimeout != DefaultIdleConnTimeout {
+		t.Errorf("MaxIdleConnsPerHost=%d IdleConnTimeout=%s, se esperaban los defaults", transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+}
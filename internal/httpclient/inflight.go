@@ -0,0 +1,66 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// InFlightLimit es un semáforo compartido por los clientes de todos los adaptadores (MAX_INFLIGHT_HTTP):
+// acota los requests simultáneos del proceso sin importar WORKER_COUNT.
+type InFlightLimit struct {
+	slots chan struct{}
+}
+
+// NewInFlightLimit crea el límite. max <= 0 devuelve nil (sin límite).
+func NewInFlightLimit(max int) *InFlightLimit {
+	if max <= 0 {
+		return nil
+	}
+	return &InFlightLimit{slots: make(chan struct{}, max)}
+}
+
+// WithInFlightLimit envuelve un RoundTripper para que cada request ocupe un lugar del límite
+// hasta que se cierra el cuerpo de la respuesta. La espera respeta el contexto del request,
+// así un apagado no queda bloqueado detrás de requests lentos.
+func WithInFlightLimit(limit *InFlightLimit, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &inFlightTransport{limit: limit, next: next}
+}
+
+type inFlightTransport struct {
+	limit *InFlightLimit
+	next  http.RoundTripper
+}
+
+func (t *inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.limit.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		<-t.limit.slots
+		return nil, err
+	}
+	// La conexión sigue ocupada mientras el adaptador lee el cuerpo (ej: item.get de varios MB)
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: func() { <-t.limit.slots }}
+	return resp, nil
+}
+
+// releaseOnClose libera el lugar del límite una sola vez, al cerrar el cuerpo
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInFlightLimitIsNeverExceeded(t *testing.T) {
+	var active, maxActive atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			old := maxActive.Load()
+			if n <= old || maxActive.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// Dos adaptadores con el mismo límite: el tope es del proceso, no de cada cliente
+	limit := NewInFlightLimit(3)
+	var clients []*http.Client
+	for _, name := range []string{"Notion", "Zabbix"} {
+		client, err := New(Options{Name: name, InFlight: limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, client)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(client *http.Client) {
+			defer wg.Done()
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}(clients[i%2])
+	}
+	wg.Wait()
+
+	if got := maxActive.Load(); got > 3 {
+		t.Errorf("hubo %d requests simultáneos, el límite es 3", got)
+	} else if got < 2 {
+		t.Errorf("hubo %d requests simultáneos: el límite no debería serializar todo", got)
+	}
+	if n := len(limit.slots); n != 0 {
+		t.Errorf("quedaron %d lugares ocupados después de cerrar todas las respuestas", n)
+	}
+}
+
+func TestInFlightLimitWaitHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client, err := New(Options{Name: "Ubersmith", InFlight: NewInFlightLimit(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// El único lugar queda ocupado por un request que no termina
+	go func() {
+		if resp, err := client.Get(srv.URL); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	start := time.Now()
+	_, err = client.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, se esperaba context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("la espera del lugar tardó %s: debería cortar con el contexto", elapsed)
+	}
+}
+
+func TestNewInFlightLimitWithoutMax(t *testing.T) {
+	if limit := NewInFlightLimit(0); limit != nil {
+		t.Error("MAX_INFLIGHT_HTTP=0 no debería limitar")
+	}
+}
@@ -0,0 +1,47 @@
+package httpclient
+
+import (
+	"gpon-sync/internal/redact"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// loggingTransport es un RoundTripper que loguea cada request saliente (HTTP_DEBUG=true):
+// método, URL sin credenciales, status y latencia.
+type loggingTransport struct {
+	name string
+	next http.RoundTripper
+}
+
+// WithLogging envuelve un RoundTripper con el log de requests
+func WithLogging(name string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &loggingTransport{name: name, next: next}
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	auth := authScheme(req.Header.Get("Authorization"))
+	if err != nil {
+		log.Printf("[HTTP %s] %s %s%s → error: %v (%s)", t.name, req.Method, redact.URL(req.URL), auth, err, elapsed)
+		return nil, err
+	}
+	log.Printf("[HTTP %s] %s %s%s → %d (%s)", t.name, req.Method, redact.URL(req.URL), auth, resp.StatusCode, elapsed)
+	return resp, nil
+}
+
+// authScheme describe el header Authorization sin exponer la credencial (ej: " auth=Bearer ****")
+func authScheme(header string) string {
+	if header == "" {
+		return ""
+	}
+	scheme, _, _ := strings.Cut(header, " ")
+	return " auth=" + scheme + " ****"
+}
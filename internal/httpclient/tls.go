@@ -0,0 +1,39 @@
+// aqui centralizamos la configuración de los clientes HTTP de los adaptadores
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+)
+
+// TLSConfig arma la configuración TLS de un adaptador.
+// Por defecto la verificación de certificados está activa; caCertPath permite agregar una CA propia
+// (instalaciones internas con certificados autofirmados). name se usa solo para los logs.
+func TLSConfig(name string, insecureSkipVerify bool, caCertPath string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caCertPath != "" {
+		pem, err := os.ReadFile(caCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: no se pudo leer la CA %s: %w", name, caCertPath, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: el archivo %s no contiene certificados PEM válidos", name, caCertPath)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if insecureSkipVerify {
+		log.Printf("[WARN] ⚠️  %s: VERIFICACIÓN TLS DESACTIVADA (INSECURE_SKIP_VERIFY=true). No usar en producción.", name)
+		cfg.InsecureSkipVerify = true
+	}
+
+	return cfg, nil
+}
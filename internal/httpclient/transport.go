@@ -0,0 +1,36 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// NewTransport crea el transport de un adaptador con su configuración TLS y de proxy.
+// Si proxyURL está vacío se respetan HTTP_PROXY / HTTPS_PROXY / NO_PROXY del entorno;
+// si está definido (OUTBOUND_PROXY) se usa siempre ese proxy.
+func NewTransport(tlsConfig *tls.Config, proxyURL string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	proxy, err := Proxy(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	return transport, nil
+}
+
+// Proxy devuelve la función de proxy a usar en un transport
+func Proxy(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("OUTBOUND_PROXY inválido: %q", proxyURL)
+	}
+	return http.ProxyURL(u), nil
+}
@@ -0,0 +1,61 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://api.notion.com/v1/databases", nil)
+
+	proxy, err := Proxy("http://proxy.corp:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := proxy(req)
+	if err != nil || u == nil || u.String() != "http://proxy.corp:3128" {
+		t.Errorf("proxy = %v, %v; se esperaba http://proxy.corp:3128", u, err)
+	}
+
+	for _, invalid := range []string{"proxy.corp:3128", "://", "http://"} {
+		if _, err := Proxy(invalid); err == nil {
+			t.Errorf("Proxy(%q) debería fallar", invalid)
+		}
+	}
+}
+
+func TestTransportWithoutOverrideUsesEnvironment(t *testing.T) {
+	transport, err := NewTransport(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sin OUTBOUND_PROXY se delega en HTTP_PROXY / HTTPS_PROXY / NO_PROXY
+	if transport.Proxy == nil {
+		t.Fatal("el transport debe usar http.ProxyFromEnvironment")
+	}
+}
+
+func TestClientUsesConfiguredProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Un proxy HTTP recibe la URL absoluta del destino
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	client, err := New(Options{Name: "Test", ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("http://ubersmith.example/api/2.0/?method=uber.method_list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if proxied != "http://ubersmith.example/api/2.0/?method=uber.method_list" {
+		t.Errorf("el proxy recibió %q", proxied)
+	}
+}
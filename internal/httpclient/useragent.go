@@ -0,0 +1,29 @@
+package httpclient
+
+import "net/http"
+
+// userAgentTransport define el User-Agent de cada request saliente, para que los
+// administradores de Notion/Zabbix/Ubersmith puedan identificar el tráfico de este worker.
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+// WithUserAgent envuelve un RoundTripper para que todos sus requests lleven userAgent.
+// Un User-Agent definido explícitamente en el request no se pisa.
+func WithUserAgent(userAgent string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &userAgentTransport{userAgent: userAgent, next: next}
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") != "" {
+		return t.next.RoundTrip(req)
+	}
+	// Un RoundTripper no debe modificar el request original
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,200 @@
+// aqui probamos el pipeline completo (WorkerPool.Run con los adaptadores reales)
+// contra Notion, Zabbix y Ubersmith falsos servidos con httptest
+package integration
+
+import (
+	"context"
+	"errors"
+	"gpon-sync/internal/adapters/notion"
+	"gpon-sync/internal/adapters/ubersmith"
+	"gpon-sync/internal/adapters/zabbix"
+	"gpon-sync/internal/core"
+	"gpon-sync/internal/fakeupstream"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// pipeline son los adaptadores reales apuntando a los servidores falsos
+type pipeline struct {
+	notion    *notion.NotionAdapter
+	zabbix    *zabbix.ZabbixAdapter
+	ubersmith *ubersmith.UbersmithAdapter
+}
+
+func newPipeline(t *testing.T, up *fakeupstream.Upstreams) pipeline {
+	t.Helper()
+	notionSrv := httptest.NewServer(up.Notion())
+	zabbixSrv := httptest.NewServer(up.Zabbix())
+	ubersmithSrv := httptest.NewServer(up.Ubersmith())
+	t.Cleanup(notionSrv.Close)
+	t.Cleanup(zabbixSrv.Close)
+	t.Cleanup(ubersmithSrv.Close)
+
+	statusKey, err := core.ParseKeyTemplate(core.DefaultStatusKeyTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	powerKey, err := core.ParseKeyTemplate(core.DefaultPowerKeyTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pipeline{
+		notion: notion.NewNotionAdapter("test-key", []string{"db-1"}, notionSrv.Client(), nil,
+			notion.Options{BaseURL: notionSrv.URL + "/v1"}),
+		zabbix: zabbix.NewZabbixAdapter(zabbixSrv.URL+"/api_jsonrpc.php", "user", "pass", zabbixSrv.Client(), nil,
+			zabbix.Options{Keys: zabbix.KeyTemplates{Status: statusKey, Power: powerKey}}),
+		ubersmith: ubersmith.NewUbersmithAdapter(ubersmithSrv.URL+"/api/2.0/", "user", "pass", ubersmithSrv.Client(), nil,
+			ubersmith.Options{}),
+	}
+}
+
+func (p pipeline) pool(workers int) *core.WorkerPool {
+	return core.NewWorkerPool(workers, p.notion, p.zabbix, p.ubersmith)
+}
+
+func mustUpstreams(t *testing.T) *fakeupstream.Upstreams {
+	t.Helper()
+	up, err := fakeupstream.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return up
+}
+
+// runAll ejecuta el pool y devuelve los resultados por CID
+func runAll(t *testing.T, pool *core.WorkerPool, cids ...string) (map[string]core.EnrichedData, *core.RunStats) {
+	t.Helper()
+	circuits := make([]core.Circuit, len(cids))
+	for i, cid := range cids {
+		circuits[i] = core.Circuit{ID: i + 1, CID: cid}
+	}
+	results, stats := pool.Run(context.Background(), circuits)
+	byCID := make(map[string]core.EnrichedData)
+	for res := range results {
+		byCID[res.CircuitID] = res
+	}
+	return byCID, stats
+}
+
+func TestPipelineEnrichesFixtureCircuits(t *testing.T) {
+	p := newPipeline(t, mustUpstreams(t))
+	if err := p.zabbix.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	got, _ := runAll(t, p.pool(3), "157591", "157592", "157593", "1575")
+
+	want := map[string]struct {
+		olt, ont, user, pass, status, rx string
+	}{
+		// Potencia en la key exacta "rx power:1/5"
+		"157591": {"OLT-DEMO-01", "0/1/5", "demo157591@isp", "s3cr3t-157591", "1", "-18.4 dBm"},
+		// La key exacta vale 0 (sin señal): la potencia sale del JSON de ms_item_ont_rx_power (-204 centésimas)
+		"157592": {"OLT-DEMO-01", "0/1/6", "demo157592@isp", "s3cr3t-157592", "1", "-20.4 dBm"},
+		// Sin credenciales en Ubersmith ni potencia en Zabbix: solo el status
+		"157593": {"OLT-DEMO-01", "0/2/1", "", "", "2", ""},
+		// "fx-1575-" no debe confundirse con los CIDs 15759x
+		"1575": {"OLT-DEMO-02", "0/3/2", "demo1575@isp", "s3cr3t-1575", "1", "-22.9 dBm"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("se obtuvieron %d resultados, se esperaban %d", len(got), len(want))
+	}
+	for cid, w := range want {
+		res := got[cid]
+		if res.Error != nil {
+			t.Errorf("CID %s: error inesperado: %v", cid, res.Error)
+			continue
+		}
+		if res.OLT != w.olt || res.ONT != w.ont {
+			t.Errorf("CID %s: OLT/ONT = %s %s, se esperaba %s %s", cid, res.OLT, res.ONT, w.olt, w.ont)
+		}
+		if res.PPPoEUsername != w.user || res.PPPoEPassword != w.pass {
+			t.Errorf("CID %s: PPPoE = %q/%q, se esperaba %q/%q", cid, res.PPPoEUsername, res.PPPoEPassword, w.user, w.pass)
+		}
+		if res.StatusGpon != w.status || res.RxPower != w.rx {
+			t.Errorf("CID %s: status/rx = %q/%q, se esperaba %q/%q", cid, res.StatusGpon, res.RxPower, w.status, w.rx)
+		}
+	}
+
+	// 157593 no tiene credenciales ni potencia: ambas quedan como advertencias, no como error
+	stages := make(map[core.Stage]bool)
+	for _, w := range got["157593"].Warnings {
+		stages[core.ErrorStage(w)] = true
+	}
+	if !stages[core.StageUbersmith] || !stages[core.StageZabbix] {
+		t.Errorf("CID 157593: advertencias = %v, se esperaban de Ubersmith y Zabbix", got["157593"].Warnings)
+	}
+}
+
+func TestPipelineCircuitNotInNotion(t *testing.T) {
+	p := newPipeline(t, mustUpstreams(t))
+	if err := p.zabbix.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	got, _ := runAll(t, p.pool(1), "999999")
+
+	res := got["999999"]
+	if !errors.Is(res.Error, notion.ErrCircuitNotFound) || core.ErrorStage(res.Error) != core.StageNotion {
+		t.Fatalf("error = %v, se esperaba ErrCircuitNotFound de Notion", res.Error)
+	}
+}
+
+func TestPipelineNotionAuthFailure(t *testing.T) {
+	cids := make([]string, 20)
+	for i := range cids {
+		cids[i] = strconv.Itoa(157600 + i)
+	}
+	tests := []struct {
+		name      string
+		fatal     bool
+		wantAbort bool
+	}{
+		{"detiene el ciclo", true, true},
+		{"configurado como no fatal", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := mustUpstreams(t)
+			// El adaptador usa "test-key": Notion responde 401 desde la primera llamada
+			up.NotionKey = "otra-key"
+			p := newPipeline(t, up)
+			if err := p.zabbix.Authenticate(context.Background()); err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			pool := p.pool(1)
+			pool.SetNotionAuthFatal(tt.fatal)
+
+			got, stats := runAll(t, pool, cids...)
+
+			first := got[cids[0]]
+			if !errors.Is(first.Error, core.ErrNotionAuth) || core.ErrorStage(first.Error) != core.StageNotion {
+				t.Fatalf("error = %v, se esperaba ErrNotionAuth de Notion", first.Error)
+			}
+			calls := stats.Calls(core.StageNotion)
+			if tt.wantAbort {
+				if !errors.Is(stats.Aborted(), core.ErrNotionAuth) {
+					t.Errorf("Aborted = %v, se esperaba ErrNotionAuth", stats.Aborted())
+				}
+				// Con un solo worker el ciclo se corta tras el primer 401
+				if calls >= len(cids) {
+					t.Errorf("se hicieron %d llamadas a Notion para %d circuitos, se esperaba cortar antes", calls, len(cids))
+				}
+				return
+			}
+			if err := stats.Aborted(); err != nil {
+				t.Errorf("Aborted = %v, no se esperaba detener el ciclo", err)
+			}
+			if calls != len(cids) {
+				t.Errorf("se hicieron %d llamadas a Notion, se esperaban %d", calls, len(cids))
+			}
+			for _, cid := range cids {
+				if !errors.Is(got[cid].Error, core.ErrNotionAuth) {
+					t.Errorf("CID %s: error = %v, se esperaba ErrNotionAuth", cid, got[cid].Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// aqui implementamos un token bucket simple para limitar las requests a las APIs externas
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter es un token bucket: permite ráfagas de hasta `burst` requests y repone `rps` tokens por segundo.
+// Un *Limiter nil no limita nada, así los adaptadores pueden usarlo sin verificar si está configurado.
+type Limiter struct {
+	mu     sync.Mutex
+	rps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// New crea un limitador. Si rps <= 0 retorna nil (sin límite).
+func New(rps float64, burst int) *Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rps:    rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait bloquea hasta obtener un token o hasta que el contexto se cancele.
+// El lock solo se mantiene para reservar el token, no durante la espera,
+// así varios workers pueden esperar en paralelo su turno.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rps
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// Reservamos el token (puede quedar negativo: los siguientes esperan más)
+	l.tokens--
+	if l.tokens >= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	wait := time.Duration(-l.tokens / l.rps * float64(time.Second))
+	l.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Devolvemos el token reservado para no penalizar a los demás
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
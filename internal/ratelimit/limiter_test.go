@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterRate(t *testing.T) {
+	// 50 rps con ráfaga de 5: 25 requests desde 10 goroutines necesitan (25-5)/50 = 400ms
+	l := New(50, 5)
+	const requests = 25
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	jobs := make(chan struct{}, requests)
+	for i := 0; i < requests; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	for w := 0; w < 10; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				if err := l.Wait(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	if elapsed < 350*time.Millisecond || elapsed > 800*time.Millisecond {
+		t.Errorf("%d requests a 50 rps (ráfaga 5) tardaron %s, se esperaban ~400ms", requests, elapsed)
+	}
+}
+
+func TestLimiterBurstDoesNotWait(t *testing.T) {
+	l := New(1, 3)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("la ráfaga inicial esperó %s", elapsed)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := New(1, 1)
+	_ = l.Wait(context.Background()) // Consume la ráfaga: el próximo token llega en 1s
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, se esperaba DeadlineExceeded", err)
+	}
+}
+
+func TestNilLimiterDoesNotLimit(t *testing.T) {
+	l := New(0, 1)
+	if l != nil {
+		t.Fatal("New con rps <= 0 debe devolver nil")
+	}
+	for i := 0; i < 1000; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
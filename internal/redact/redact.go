@@ -0,0 +1,160 @@
+// aqui centralizamos el enmascarado de credenciales para que nunca lleguen a los logs
+package redact
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const mask = "****"
+
+// sensitiveParams son los parámetros de query string que nunca se deben loguear en claro
+var sensitiveParams = []string{"pass", "password", "token", "auth", "key", "secret"}
+
+// Policy define cómo se enmascaran los datos de clientes en los logs
+type Policy struct {
+	// Enmascarar también los usuarios PPPoE (pueden incluir el ID del cliente)
+	MaskUsernames bool
+	// Caracteres que quedan visibles en cada extremo (0 = se enmascara todo)
+	Reveal int
+}
+
+// DefaultPolicy muestra los usuarios en claro y deja 2 caracteres visibles en cada extremo
+var DefaultPolicy = Policy{Reveal: 2}
+
+var (
+	policyMu sync.RWMutex
+	policy   = DefaultPolicy
+)
+
+// SetPolicy cambia la política de enmascarado de todo el proceso
+func SetPolicy(p Policy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	policy = p
+}
+
+func currentPolicy() Policy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return policy
+}
+
+// Secret enmascara un valor dejando solo los extremos para poder correlacionar logs.
+// Si el valor es demasiado corto para ocultar algo, se enmascara completo.
+func Secret(value string) string {
+	return maskValue(value, currentPolicy().Reveal)
+}
+
+// Username enmascara un usuario PPPoE solo si la política lo pide (MASK_USERNAMES)
+func Username(value string) string {
+	p := currentPolicy()
+	if !p.MaskUsernames {
+		return value
+	}
+	return maskValue(value, p.Reveal)
+}
+
+func maskValue(value string, reveal int) string {
+	if value == "" {
+		return ""
+	}
+	if reveal <= 0 || len(value) <= 2*reveal {
+		return mask
+	}
+	return value[:reveal] + mask + value[len(value)-reveal:]
+}
+
+// URL devuelve la URL sin usuario/contraseña y con los parámetros sensibles enmascarados
+func URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	clean := *u
+	clean.User = nil
+
+	query := clean.Query()
+	changed := false
+	for name := range query {
+		if isSensitive(name) {
+			query.Set(name, mask)
+			changed = true
+		}
+	}
+	if changed {
+		clean.RawQuery = query.Encode()
+	}
+	return clean.String()
+}
+
+// DSN enmascara la contraseña de un DSN de MySQL (user:password@tcp(host:port)/db?params)
+func DSN(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	userInfo := dsn[:at]
+	user, _, hasPass := strings.Cut(userInfo, ":")
+	if !hasPass {
+		return dsn
+	}
+	return user + ":" + mask + dsn[at:]
+}
+
+func isSensitive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, s := range sensitiveParams {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Secretos conocidos ---
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+)
+
+// Register agrega secretos conocidos (API keys, contraseñas) que String y Writer reemplazan por ****.
+// Se ignoran valores muy cortos para no enmascarar texto común por accidente.
+func Register(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, v := range values {
+		if len(v) >= 4 {
+			secrets = append(secrets, v)
+		}
+	}
+}
+
+// String reemplaza cualquier secreto registrado que aparezca en s
+func String(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, mask)
+	}
+	return s
+}
+
+// Writer envuelve un io.Writer (ej: la salida del logger) y enmascara los secretos registrados
+func Writer(w io.Writer) io.Writer {
+	return &writer{w: w}
+}
+
+type writer struct {
+	w io.Writer
+}
+
+func (r *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, String(string(p))); err != nil {
+		return 0, err
+	}
+	// Reportamos el largo original para que el logger no lo considere una escritura corta
+	return len(p), nil
+}
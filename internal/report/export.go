@@ -0,0 +1,43 @@
+package report
+
+import (
+	"encoding/csv"
+	"gpon-sync/internal/core"
+	"io"
+)
+
+// exportHeader son las columnas del export de resultados enriquecidos.
+// La contraseña PPPoE se omite a propósito: el export es para reportes.
+var exportHeader = []string{"cid", "olt", "ont", "vlan", "pppoe_user", "status_gpon", "status_gpon_raw", "rx_power", "error"}
+
+// EnrichedCSV escribe resultados enriquecidos en formato CSV (UTF-8, con encabezado).
+// El paquete encoding/csv se encarga de entrecomillar campos con comas, comillas o saltos de línea.
+type EnrichedCSV struct {
+	w *csv.Writer
+}
+
+// NewEnrichedCSV crea el escritor y escribe el encabezado
+func NewEnrichedCSV(w io.Writer) (*EnrichedCSV, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader); err != nil {
+		return nil, err
+	}
+	return &EnrichedCSV{w: cw}, nil
+}
+
+// Write escribe una fila; el error del circuito se exporta como texto
+func (e *EnrichedCSV) Write(d core.EnrichedData) error {
+	errText := ""
+	if d.Error != nil {
+		errText = d.Error.Error()
+	}
+	return e.w.Write([]string{
+		d.CircuitID, d.OLT, d.ONT, d.VLAN, d.PPPoEUsername, d.StatusGpon, d.StatusGponRaw, d.RxPower, errText,
+	})
+}
+
+// Flush vacía el buffer y retorna cualquier error de escritura pendiente
+func (e *EnrichedCSV) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"gpon-sync/internal/core"
+	"strings"
+	"testing"
+)
+
+func TestEnrichedCSV(t *testing.T) {
+	var buf bytes.Buffer
+	exp, err := NewEnrichedCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []core.EnrichedData{
+		{CircuitID: "157591", OLT: "OLT-Peñalolén", ONT: "0/1/5", VLAN: "210", PPPoEUsername: "demo@isp",
+			PPPoEPassword: "secreta", StatusGpon: "online", StatusGponRaw: "1", RxPower: "-18.4 dBm"},
+		{CircuitID: "157592", OLT: "OLT, con coma", ONT: "0/1/6", Error: &core.StageError{Stage: core.StageZabbix,
+			Err: errors.New(`host "OLT" no encontrado`)}},
+	}
+	for _, r := range rows {
+		if err := exp.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := exp.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "secreta") {
+		t.Error("la contraseña PPPoE no debe exportarse")
+	}
+	if !strings.Contains(out, `"OLT, con coma"`) {
+		t.Errorf("un campo con coma debe ir entre comillas:\n%s", out)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("el CSV no se puede leer: %v", err)
+	}
+	want := [][]string{
+		{"cid", "olt", "ont", "vlan", "pppoe_user", "status_gpon", "status_gpon_raw", "rx_power", "error"},
+		{"157591", "OLT-Peñalolén", "0/1/5", "210", "demo@isp", "online", "1", "-18.4 dBm", ""},
+		{"157592", "OLT, con coma", "0/1/6", "", "", "", "", "", rows[1].Error.Error()},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("se leyeron %d filas, se esperaban %d", len(records), len(want))
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("fila %d = %q, se esperaba %q", i, records[i], want[i])
+		}
+	}
+}
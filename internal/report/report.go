@@ -0,0 +1,108 @@
+// aqui generamos reportes estructurados (CSV / JSON) de los resultados de un ciclo
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Acciones que se registran en el reporte de dry-run
+const (
+	ActionUpdate    = "update"    // Se escribiría en la DB
+	ActionUnchanged = "unchanged" // Se omitiría: ningún campo comparado cambió (CHANGE_FIELDS)
+	ActionInsert    = "insert"    // No tiene fila: se insertaría (INSERT_MISSING)
+	ActionUnmatched = "unmatched" // No tiene fila: no se guardaría
+	ActionError     = "error"     // No se pudo enriquecer (ver la columna error)
+)
+
+// Row es una fila del reporte: lo que se escribiría para un circuito
+type Row struct {
+	CID        string `json:"cid"`
+	RxPower    string `json:"rx_power"`
+	StatusGpon string `json:"status_gpon"`
+	PPPoEUser  string `json:"pppoe_user"`
+	Action     string `json:"action"`
+	Error      string `json:"error,omitempty"`
+}
+
+var csvHeader = []string{"cid", "rx_power", "status_gpon", "pppoe_user", "action", "error"}
+
+func (r Row) csvRecord() []string {
+	return []string{r.CID, r.RxPower, r.StatusGpon, r.PPPoEUser, r.Action, r.Error}
+}
+
+// Writer escribe filas de reporte en un destino
+type Writer interface {
+	Write(row Row) error
+	Close() error
+}
+
+// NewFileWriter crea el archivo de reporte. El formato depende de la extensión:
+// ".json" genera un arreglo JSON; cualquier otra extensión genera CSV con encabezado.
+func NewFileWriter(path string) (Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear el reporte %s: %w", path, err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return &jsonWriter{f: f}, nil
+	}
+
+	w := csv.NewWriter(f)
+	if err := w.Write(csvHeader); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &csvWriter{f: f, w: w}, nil
+}
+
+// --- CSV ---
+
+type csvWriter struct {
+	f *os.File
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(row Row) error {
+	return c.w.Write(row.csvRecord())
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
+
+// --- JSON ---
+
+// jsonWriter acumula las filas y escribe un arreglo JSON al cerrar
+type jsonWriter struct {
+	f    *os.File
+	rows []Row
+}
+
+func (j *jsonWriter) Write(row Row) error {
+	j.rows = append(j.rows, row)
+	return nil
+}
+
+func (j *jsonWriter) Close() error {
+	if j.rows == nil {
+		j.rows = []Row{}
+	}
+	enc := json.NewEncoder(j.f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(j.rows); err != nil {
+		j.f.Close()
+		return err
+	}
+	return j.f.Close()
+}
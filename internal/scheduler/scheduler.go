@@ -0,0 +1,142 @@
+// aqui implementamos el loop de ejecución periódica (antes era el ticker de main)
+package scheduler
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler ejecuta una función cada `interval` más un jitter aleatorio, sin solapar ejecuciones
+type Scheduler struct {
+	interval time.Duration
+	jitter   time.Duration
+
+	running atomic.Bool
+	skipped atomic.Int64 // Turnos omitidos porque la ejecución anterior seguía en curso
+	nextRun atomic.Int64 // Próximo turno (UnixNano; 0 = sin programar)
+
+	// Backoff ante ciclos fallidos seguidos (solo se usa desde el loop de Start)
+	maxBackoff time.Duration
+	failures   int
+}
+
+// New crea un scheduler. jitter = 0 desactiva el desfase aleatorio.
+func New(interval, jitter time.Duration) *Scheduler {
+	return &Scheduler{interval: interval, jitter: jitter}
+}
+
+// SetMaxBackoff habilita el backoff exponencial ante ciclos fallidos, con max como espera máxima.
+// max <= 0 lo deshabilita (siempre se espera interval).
+func (s *Scheduler) SetMaxBackoff(max time.Duration) {
+	s.maxBackoff = max
+}
+
+// Skipped devuelve cuántos turnos se omitieron por solapamiento
+func (s *Scheduler) Skipped() int64 {
+	return s.skipped.Load()
+}
+
+// NextRun devuelve el momento del próximo turno (cero si el scheduler no está corriendo)
+func (s *Scheduler) NextRun() time.Time {
+	n := s.nextRun.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// plan calcula la espera hasta el próximo turno y la registra para NextRun
+func (s *Scheduler) plan() time.Duration {
+	d := s.next()
+	s.nextRun.Store(time.Now().Add(d).UnixNano())
+	return d
+}
+
+// Start ejecuta fn de inmediato y luego en cada turno hasta que ctx se cancele.
+// Si fn sigue ejecutándose cuando llega el siguiente turno, ese turno se omite (y se loguea).
+// Si fn devuelve error (el ciclo completo falló) y hay backoff configurado, el próximo turno
+// se aleja exponencialmente; el primer ciclo exitoso vuelve al intervalo normal.
+// Al cancelar ctx espera a que termine la ejecución en curso antes de retornar.
+func (s *Scheduler) Start(ctx context.Context, fn func() error) {
+	var wg sync.WaitGroup
+	done := make(chan error, 1) // Resultado de la ejecución en curso (nunca hay más de una)
+
+	trigger := func() {
+		if !s.running.CompareAndSwap(false, true) {
+			n := s.skipped.Add(1)
+			log.Printf("[WARN] ⏭️  Turno omitido: la ejecución anterior sigue en curso (omitidos: %d)", n)
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer s.running.Store(false)
+			done <- fn()
+		}()
+	}
+
+	trigger()
+
+	timer := time.NewTimer(s.plan())
+	defer timer.Stop()
+	defer s.nextRun.Store(0)
+
+	for {
+		select {
+		case <-timer.C:
+			trigger()
+			timer.Reset(s.plan())
+		case err := <-done:
+			if !s.recordResult(err) {
+				continue
+			}
+			// Cambió el estado de backoff: se reprograma desde ahora
+			d := s.plan()
+			if s.failures > 0 {
+				log.Printf("[WARN] ⏳ %d ciclos fallidos seguidos (%v). Próximo intento en %s", s.failures, err, d.Round(time.Second))
+			}
+			timer.Reset(d)
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+	}
+}
+
+// recordResult actualiza los fallos consecutivos. Retorna true si cambia la espera del próximo turno.
+func (s *Scheduler) recordResult(err error) bool {
+	if s.maxBackoff <= 0 {
+		return false
+	}
+	if err == nil {
+		if s.failures == 0 {
+			return false
+		}
+		log.Printf("✅ Ciclo exitoso tras %d fallos: se vuelve al intervalo normal", s.failures)
+		s.failures = 0
+		return true
+	}
+	s.failures++
+	return true
+}
+
+// next calcula la espera hasta el próximo turno: interval + [0, jitter].
+// Con fallos consecutivos: interval * 2^fallos, como máximo maxBackoff.
+func (s *Scheduler) next() time.Duration {
+	d := s.interval
+	if s.failures > 0 {
+		limit := max(s.maxBackoff, s.interval)
+		for i := 0; i < s.failures && d < limit; i++ {
+			d *= 2
+		}
+		d = min(d, limit)
+	}
+	if s.jitter <= 0 {
+		return d
+	}
+	return d + rand.N(s.jitter+1)
+}
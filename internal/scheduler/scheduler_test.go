@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartSkipsOverlappingTicks(t *testing.T) {
+	s := New(20*time.Millisecond, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	var calls, active, maxActive atomic.Int32
+	s.Start(ctx, func() error {
+		calls.Add(1)
+		n := active.Add(1)
+		defer active.Add(-1)
+		if n > maxActive.Load() {
+			maxActive.Store(n)
+		}
+		// Una ejecución larga: varios turnos llegan mientras sigue en curso
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+
+	if maxActive.Load() != 1 {
+		t.Errorf("hubo %d ejecuciones simultáneas, se esperaba una sola", maxActive.Load())
+	}
+	if s.Skipped() == 0 {
+		t.Error("los turnos que llegaron durante la ejecución deberían omitirse")
+	}
+	// 150ms con ejecuciones de 100ms: la primera y a lo sumo una más
+	if c := calls.Load(); c < 1 || c > 2 {
+		t.Errorf("se ejecutó %d veces, se esperaban 1 o 2", c)
+	}
+	if active.Load() != 0 {
+		t.Error("Start debería esperar a que termine la ejecución en curso antes de retornar")
+	}
+}
+
+func TestStartRunsEveryTickWhenFast(t *testing.T) {
+	s := New(10*time.Millisecond, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var calls atomic.Int32
+	s.Start(ctx, func() error {
+		calls.Add(1)
+		return nil
+	})
+
+	if calls.Load() < 3 {
+		t.Errorf("se ejecutó %d veces, se esperaban varias", calls.Load())
+	}
+	if s.Skipped() != 0 {
+		t.Errorf("se omitieron %d turnos sin solapamiento", s.Skipped())
+	}
+}
@@ -0,0 +1,153 @@
+// aqui guardamos el estado del último ciclo y lo exponemos en /status
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RunState es el registro del último ciclo (historial operativo para dashboards)
+type RunState struct {
+	LastStart   time.Time `json:"last_start"`
+	LastEnd     time.Time `json:"last_end"`
+	Running     bool      `json:"running"`
+	Processed   int       `json:"processed"`
+	Success     int       `json:"success"`
+	Errors      int       `json:"errors"`
+	Interrupted bool      `json:"interrupted"`
+	LastError   string    `json:"last_error,omitempty"` // Error fatal del ciclo, si lo hubo
+
+	// Fin del último ciclo sin error fatal
+	LastSuccess time.Time `json:"last_success"`
+}
+
+// Result es lo que se registra al terminar un ciclo
+type Result struct {
+	Processed   int
+	Success     int
+	Errors      int
+	Interrupted bool
+	Err         error
+}
+
+// Tracker mantiene el RunState y lo persiste en un archivo JSON (si path no está vacío)
+// para conservarlo entre reinicios
+type Tracker struct {
+	path string
+
+	mu    sync.Mutex
+	state RunState
+}
+
+// Open carga el estado guardado (si el archivo no existe se empieza vacío)
+func Open(path string) (*Tracker, error) {
+	t := &Tracker{path: path}
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer el archivo de estado %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &t.state); err != nil {
+		return nil, fmt.Errorf("archivo de estado %s inválido: %w", path, err)
+	}
+	// Si el proceso murió a mitad de un ciclo, ese ciclo ya no está en curso
+	t.state.Running = false
+	return t, nil
+}
+
+// Begin registra el inicio de un ciclo
+func (t *Tracker) Begin() error {
+	t.mu.Lock()
+	t.state.LastStart = time.Now()
+	t.state.Running = true
+	t.mu.Unlock()
+	return t.save()
+}
+
+// Finish registra el resultado de un ciclo
+func (t *Tracker) Finish(r Result) error {
+	t.mu.Lock()
+	now := time.Now()
+	t.state.LastEnd = now
+	t.state.Running = false
+	t.state.Processed = r.Processed
+	t.state.Success = r.Success
+	t.state.Errors = r.Errors
+	t.state.Interrupted = r.Interrupted
+	t.state.LastError = ""
+	if r.Err != nil {
+		t.state.LastError = r.Err.Error()
+	} else {
+		t.state.LastSuccess = now
+	}
+	t.mu.Unlock()
+	return t.save()
+}
+
+// State devuelve una copia del estado actual
+func (t *Tracker) State() RunState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// save escribe el estado a un temporal y luego hace rename, para no dejar el archivo a medias
+func (t *Tracker) save() error {
+	if t.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(t.State(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(t.path), ".status-*")
+	if err != nil {
+		return fmt.Errorf("no se pudo guardar el estado: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("no se pudo guardar el estado: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("no se pudo guardar el estado: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), t.path); err != nil {
+		return fmt.Errorf("no se pudo guardar el estado: %w", err)
+	}
+	return nil
+}
+
+// Handler responde el estado en JSON. nextRun devuelve el próximo ciclo programado
+// (cero si no hay, ej: en ejecución única).
+func (t *Tracker) Handler(nextRun func() time.Time) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			RunState
+			NextRun *time.Time `json:"next_run,omitempty"`
+		}{RunState: t.State()}
+		if nextRun != nil {
+			if next := nextRun(); !next.IsZero() {
+				body.NextRun = &next
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,27 @@
+// aqui guardamos la versión del binario, fijada al compilar
+package version
+
+import "fmt"
+
+// Datos del build, definidos con -ldflags -X (ver dockerfile):
+//
+//	go build -ldflags "-X gpon-sync/internal/version.Version=v1.2.3 \
+//	  -X gpon-sync/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X gpon-sync/internal/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/worker
+//
+// Sin ldflags quedan los valores de desarrollo.
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+// UserAgent es el User-Agent por defecto de los requests salientes (ej: "gpon-sync/v1.2.3")
+func UserAgent() string {
+	return "gpon-sync/" + Version
+}
+
+// String describe el build completo (ej: "v1.2.3 (commit abc1234, 2025-01-31T12:00:00Z)")
+func String() string {
+	return fmt.Sprintf("%s (commit %s, %s)", Version, Commit, Date)
+}